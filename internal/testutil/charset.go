@@ -0,0 +1,8 @@
+//go:build !oksvg_nocharset
+// +build !oksvg_nocharset
+
+package testutil
+
+// hasCharset mirrors the build tag selecting
+// svgicon.DefaultCharsetReader
+const hasCharset = true
@@ -0,0 +1,6 @@
+//go:build oksvg_nocharset
+// +build oksvg_nocharset
+
+package testutil
+
+const hasCharset = false
@@ -0,0 +1,14 @@
+// Package testutil provides helpers shared by the tests
+// of the oksvg packages.
+package testutil
+
+import "testing"
+
+// SkipWithoutCharset skips the tests using the ISO-8859-1 encoded
+// test files, when the module is built with the oksvg_nocharset tag.
+func SkipWithoutCharset(t testing.TB) {
+	t.Helper()
+	if !hasCharset {
+		t.Skip("non UTF-8 test files require a charset reader")
+	}
+}
@@ -0,0 +1,196 @@
+// Package svggio implements a Gio backend to render SVG icons,
+// by translating the paths into gioui.org/op/clip operations,
+// without rasterizing them first.
+//
+// It is a separate module, so that the other packages do not
+// depend on gioui.org.
+//
+// The renderer has the following limitations:
+//   - paths are always filled with the non-zero winding rule
+//   - strokes only use the line width: the joins, caps and dashes are ignored
+//   - linear gradients are approximated by their first and last stops,
+//     and the other gradients by their first stop color
+//   - the optional drivers (clipping, blending, layers, filters,
+//     texts and images) are not implemented, so that these features
+//     are ignored when drawing
+package svggio
+
+import (
+	"image/color"
+	"math"
+
+	"gioui.org/f32"
+	"gioui.org/op"
+	"gioui.org/op/clip"
+	"gioui.org/op/paint"
+	"github.com/benoitkugler/oksvg/svgicon"
+	"golang.org/x/image/math/fixed"
+)
+
+var _ svgicon.Driver = Renderer{}
+
+// Renderer implements svgicon.Driver, by adding
+// the drawing operations to a Gio operation list.
+type Renderer struct {
+	ops *op.Ops
+}
+
+// NewRenderer returns a renderer adding its operations to `ops`.
+// The icon is drawn in the current Gio coordinates: use the
+// icon Transform (see SetTarget) or an op.TransformOp to position it.
+func NewRenderer(ops *op.Ops) Renderer { return Renderer{ops: ops} }
+
+func (r Renderer) SetupDrawers(willFill, willStroke bool) (f svgicon.Filler, s svgicon.Stroker) {
+	if willFill {
+		f = &filler{pather: pather{ops: r.ops}}
+	}
+	if willStroke {
+		s = &stroker{pather: pather{ops: r.ops}}
+	}
+	return f, s
+}
+
+// pather builds a Gio path, and its bounding box,
+// needed when using gradient with objectBoudingBox
+type pather struct {
+	ops  *op.Ops
+	path clip.Path
+	bbox svgicon.BoundingBox
+}
+
+// implements the filling operation
+type filler struct {
+	pather
+}
+
+// implements the stroking operation
+type stroker struct {
+	pather
+	width float32
+}
+
+func toPoint(a fixed.Point26_6) f32.Point {
+	return f32.Point{X: float32(a.X) / 64, Y: float32(a.Y) / 64}
+}
+
+func (p *pather) Clear() {
+	p.path.Begin(p.ops)
+	p.bbox = svgicon.BoundingBox{}
+}
+
+func (p *pather) Start(a fixed.Point26_6) {
+	p.path.MoveTo(toPoint(a))
+	p.bbox.Start(a)
+}
+
+func (p *pather) Line(b fixed.Point26_6) {
+	p.path.LineTo(toPoint(b))
+	p.bbox.Line(b)
+}
+
+func (p *pather) QuadBezier(b, c fixed.Point26_6) {
+	p.path.QuadTo(toPoint(b), toPoint(c))
+	p.bbox.QuadBezier(b, c)
+}
+
+func (p *pather) CubeBezier(b, c, d fixed.Point26_6) {
+	p.path.CubeTo(toPoint(b), toPoint(c), toPoint(d))
+	p.bbox.CubeBezier(b, c, d)
+}
+
+func (p *pather) Stop(closeLoop bool) {
+	if closeLoop {
+		p.path.Close()
+	}
+}
+
+// SetWinding is a no-op: Gio always uses the non-zero winding rule.
+func (f *filler) SetWinding(useNonZeroWinding bool) {}
+
+func (f *filler) Draw(pattern svgicon.Pattern, opacity float64) {
+	spec := f.path.End()
+	if f.bbox.IsEmpty() {
+		return
+	}
+	defer clip.Outline{Path: spec}.Op().Push(f.ops).Pop()
+	f.paint(pattern, opacity)
+}
+
+// SetStrokeOptions only uses the line width, already scaled by the
+// icon transform: Gio has no support for joins, caps or dashes.
+func (s *stroker) SetStrokeOptions(options svgicon.StrokeOptions) {
+	s.width = float32(options.LineWidth) / 64
+}
+
+func (s *stroker) Draw(pattern svgicon.Pattern, opacity float64) {
+	spec := s.path.End()
+	if s.bbox.IsEmpty() || s.width <= 0 {
+		return
+	}
+	defer clip.Stroke{Path: spec, Width: s.width}.Op().Push(s.ops).Pop()
+	s.paint(pattern, opacity)
+}
+
+// paint fills the current clip area
+func (p *pather) paint(pattern svgicon.Pattern, opacity float64) {
+	b, ok := brush(pattern, opacity, p.bbox.BBox)
+	if !ok {
+		return
+	}
+	b.Add(p.ops)
+	paint.PaintOp{}.Add(p.ops)
+}
+
+// brush returns the Gio material for `pattern`, painting a path
+// with bounding box `bbox`, or false for unsupported patterns.
+// Linear gradients are approximated by a gradient between their first
+// and last stops, and radial gradients by their first stop color.
+func brush(pattern svgicon.Pattern, opacity float64, bbox fixed.Rectangle26_6) (interface{ Add(*op.Ops) }, bool) {
+	switch pattern := pattern.(type) {
+	case svgicon.PlainColor:
+		return paint.ColorOp{Color: applyOpacity(pattern, opacity)}, true
+	case svgicon.Gradient:
+		return gradientBrush(pattern, opacity, bbox), true
+	default:
+		return nil, false
+	}
+}
+
+func gradientBrush(grad svgicon.Gradient, opacity float64, bbox fixed.Rectangle26_6) interface{ Add(*op.Ops) } {
+	if len(grad.Stops) == 0 { // nothing is painted
+		return paint.ColorOp{}
+	}
+	first, last := grad.Stops[0], grad.Stops[len(grad.Stops)-1]
+	color1 := applyOpacity(first.StopColor, first.Opacity*opacity)
+	dir, isLinear := grad.Direction.(svgicon.Linear)
+	if !isLinear || first.Offset >= last.Offset {
+		return paint.ColorOp{Color: color1}
+	}
+
+	// mapping from the gradient space to the path space,
+	// as done by the raster backend
+	mat := grad.Matrix
+	if grad.Units == svgicon.ObjectBoundingBox {
+		x, y := float64(bbox.Min.X)/64, float64(bbox.Min.Y)/64
+		w, h := float64(bbox.Max.X-bbox.Min.X)/64, float64(bbox.Max.Y-bbox.Min.Y)/64
+		mat = svgicon.Identity.Translate(x, y).Scale(w, h).Mult(grad.Matrix)
+	}
+	point := func(t float64) f32.Point {
+		x, y := mat.Transform(dir[0]+t*(dir[2]-dir[0]), dir[1]+t*(dir[3]-dir[1]))
+		return f32.Point{X: float32(x), Y: float32(y)}
+	}
+	return paint.LinearGradientOp{
+		Stop1:  point(first.Offset),
+		Color1: color1,
+		Stop2:  point(last.Offset),
+		Color2: applyOpacity(last.StopColor, last.Opacity*opacity),
+	}
+}
+
+// applyOpacity returns `c` as a non premultiplied color,
+// with its alpha multiplied by `opacity`
+func applyOpacity(c color.Color, opacity float64) color.NRGBA {
+	nc := color.NRGBAModel.Convert(c).(color.NRGBA)
+	nc.A = uint8(math.Round(float64(nc.A) * opacity))
+	return nc
+}
@@ -0,0 +1,100 @@
+package svggio
+
+import (
+	"image/color"
+	"strings"
+	"testing"
+
+	"gioui.org/f32"
+	"gioui.org/op"
+	"gioui.org/op/paint"
+	"github.com/benoitkugler/oksvg/svgicon"
+	"golang.org/x/image/math/fixed"
+)
+
+func TestDrawIcon(t *testing.T) {
+	const src = `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 20 20">
+	<linearGradient id="l"><stop offset="0" stop-color="red"/><stop offset="1" stop-color="blue"/></linearGradient>
+	<radialGradient id="r"><stop offset="0" stop-color="red"/><stop offset="1" stop-color="blue"/></radialGradient>
+	<rect width="10" height="10" fill="green" fill-rule="evenodd"/>
+	<circle cx="10" cy="10" r="5" fill="url(#l)" stroke="url(#r)" stroke-width="2" stroke-dasharray="1 1"/>
+	<path d="M0 0 Q 5 10 10 0 C 12 5 15 5 20 0" fill="none" stroke="black"/>
+	<path d="M0 0"/>
+	</svg>`
+	icon, err := svgicon.ReadIconStream(strings.NewReader(src), svgicon.StrictErrorMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	icon.SetTarget(0, 0, 100, 100)
+	var ops op.Ops
+	icon.Draw(NewRenderer(&ops), 1)
+	// the operation list may be reused
+	ops.Reset()
+	icon.Draw(NewRenderer(&ops), 0.5)
+}
+
+// strokeRecorder records the strokers of a renderer
+type strokeRecorder struct {
+	Renderer
+	strokers []*stroker
+}
+
+func (r *strokeRecorder) SetupDrawers(willFill, willStroke bool) (svgicon.Filler, svgicon.Stroker) {
+	f, s := r.Renderer.SetupDrawers(willFill, willStroke)
+	if s != nil {
+		r.strokers = append(r.strokers, s.(*stroker))
+	}
+	return f, s
+}
+
+func TestStrokeWidth(t *testing.T) {
+	const src = `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 20 20">
+	<path d="M0 10 L20 10" stroke="black" stroke-width="2"/>
+	</svg>`
+	icon, err := svgicon.ReadIconStream(strings.NewReader(src), svgicon.StrictErrorMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	icon.SetTarget(0, 0, 100, 100)
+	var ops op.Ops
+	rec := strokeRecorder{Renderer: NewRenderer(&ops)}
+	icon.Draw(&rec, 1)
+	if len(rec.strokers) != 1 {
+		t.Fatalf("expected one stroke, got %d", len(rec.strokers))
+	}
+	if w := rec.strokers[0].width; w != 10 {
+		t.Errorf("expected a width scaled by the icon transform, got %g", w)
+	}
+}
+
+func TestBrush(t *testing.T) {
+	red, blue := color.NRGBA{R: 0xff, A: 0xff}, color.NRGBA{B: 0xff, A: 0xff}
+	stops := []svgicon.GradStop{{StopColor: red, Offset: 0.25, Opacity: 1}, {StopColor: blue, Offset: 1, Opacity: 0.5}}
+	bbox := fixed.R(10, 10, 30, 20)
+
+	b, _ := brush(svgicon.NewPlainColor(0, 0, 0xff, 0xff), 0.5, bbox)
+	if exp := (paint.ColorOp{Color: color.NRGBA{B: 0xff, A: 128}}); b != exp {
+		t.Errorf("expected %v, got %v", exp, b)
+	}
+
+	linear := svgicon.Gradient{Direction: svgicon.Linear{0, 0, 1, 0}, Stops: stops, Matrix: svgicon.Identity, Units: svgicon.ObjectBoundingBox}
+	b, _ = brush(linear, 1, bbox)
+	exp := paint.LinearGradientOp{Stop1: f32.Pt(15, 10), Color1: red, Stop2: f32.Pt(30, 10), Color2: color.NRGBA{B: 0xff, A: 128}}
+	if b != exp {
+		t.Errorf("expected %v, got %v", exp, b)
+	}
+
+	linear.Units = svgicon.UserSpaceOnUse
+	linear.Direction = svgicon.Linear{0, 0, 0, 40}
+	b, _ = brush(linear, 1, bbox)
+	if g := b.(paint.LinearGradientOp); g.Stop1 != f32.Pt(0, 10) || g.Stop2 != f32.Pt(0, 40) {
+		t.Errorf("unexpected gradient %v", g)
+	}
+
+	// radial gradients fall back to their first stop color
+	radial := svgicon.Gradient{Direction: svgicon.Radial{0.5, 0.5, 0.5, 0.5, 0.5, 0}, Stops: stops, Matrix: svgicon.Identity}
+	b, _ = brush(radial, 1, bbox)
+	if exp := (paint.ColorOp{Color: red}); b != exp {
+		t.Errorf("expected %v, got %v", exp, b)
+	}
+}
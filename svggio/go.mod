@@ -0,0 +1,17 @@
+module github.com/benoitkugler/oksvg/svggio
+
+go 1.23.8
+
+require (
+	gioui.org v0.9.0
+	github.com/benoitkugler/oksvg v0.0.0-20261016234157-539b353aae7b
+	golang.org/x/image v0.26.0
+)
+
+require (
+	golang.org/x/net v0.14.0 // indirect
+	golang.org/x/text v0.24.0 // indirect
+)
+
+// the renderer is developed alongside the parent module
+replace github.com/benoitkugler/oksvg => ../
@@ -0,0 +1,112 @@
+package svgicon
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Alignment is the alignment of the view box along
+// one axis, as specified by the preserveAspectRatio attribute.
+type Alignment uint8
+
+const (
+	AlignMid Alignment = iota // default
+	AlignMin
+	AlignMax
+)
+
+// PreserveAspectRatio describes how a view box fits into a viewport
+// with a different aspect ratio.
+// The zero value is the SVG default, "xMidYMid meet".
+type PreserveAspectRatio struct {
+	// None disables the uniform scaling: the view box is
+	// stretched to fill the viewport, and X and Y are ignored.
+	None bool
+	X, Y Alignment
+	// Slice is true to cover the whole viewport (the view box is
+	// then clipped), false to fit the view box inside the viewport ("meet").
+	Slice bool
+}
+
+// ParsePreserveAspectRatio parses the value of a preserveAspectRatio
+// attribute, whose syntax is [defer] <align> [meet | slice].
+// The 'defer' keyword is accepted but has no effect.
+func ParsePreserveAspectRatio(s string) (PreserveAspectRatio, error) {
+	var out PreserveAspectRatio
+	fields := strings.Fields(s)
+	if len(fields) != 0 && fields[0] == "defer" {
+		fields = fields[1:]
+	}
+	if len(fields) == 0 || len(fields) > 2 {
+		return out, fmt.Errorf("invalid preserveAspectRatio value: '%s'", s)
+	}
+
+	align := fields[0]
+	if align == "none" {
+		out.None = true
+	} else {
+		// expected form is x(Min|Mid|Max)Y(Min|Mid|Max)
+		var ok1, ok2 bool
+		if len(align) == 8 && align[0] == 'x' && align[4] == 'Y' {
+			out.X, ok1 = parseAlignment(align[1:4])
+			out.Y, ok2 = parseAlignment(align[5:8])
+		}
+		if !ok1 || !ok2 {
+			return out, fmt.Errorf("invalid preserveAspectRatio alignment: '%s'", align)
+		}
+	}
+
+	if len(fields) == 2 {
+		switch fields[1] {
+		case "meet":
+		case "slice":
+			out.Slice = true
+		default:
+			return out, fmt.Errorf("invalid preserveAspectRatio value: '%s'", s)
+		}
+	}
+	return out, nil
+}
+
+func parseAlignment(s string) (Alignment, bool) {
+	switch s {
+	case "Min":
+		return AlignMin, true
+	case "Mid":
+		return AlignMid, true
+	case "Max":
+		return AlignMax, true
+	default:
+		return 0, false
+	}
+}
+
+// fit returns the matrix mapping `viewBox` into `viewport`,
+// according to `p`.
+func (p PreserveAspectRatio) fit(viewBox, viewport Bounds) Matrix2D {
+	sx, sy := viewport.W/viewBox.W, viewport.H/viewBox.H
+	if p.None {
+		return Identity.Translate(viewport.X, viewport.Y).Scale(sx, sy).Translate(-viewBox.X, -viewBox.Y)
+	}
+	scale := math.Min(sx, sy)
+	if p.Slice {
+		scale = math.Max(sx, sy)
+	}
+	tx := viewport.X + p.X.offset(viewport.W-viewBox.W*scale)
+	ty := viewport.Y + p.Y.offset(viewport.H-viewBox.H*scale)
+	return Identity.Translate(tx, ty).Scale(scale, scale).Translate(-viewBox.X, -viewBox.Y)
+}
+
+// offset returns the translation aligning a box in
+// a space `free` units larger
+func (a Alignment) offset(free float64) float64 {
+	switch a {
+	case AlignMin:
+		return 0
+	case AlignMax:
+		return free
+	default:
+		return free / 2
+	}
+}
@@ -0,0 +1,166 @@
+package svgicon
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParsePreserveAspectRatio(t *testing.T) {
+	for _, test := range []struct {
+		s        string
+		expected PreserveAspectRatio
+	}{
+		{"xMinYMin", PreserveAspectRatio{X: AlignMin, Y: AlignMin}},
+		{"xMidYMin", PreserveAspectRatio{X: AlignMid, Y: AlignMin}},
+		{"xMaxYMin", PreserveAspectRatio{X: AlignMax, Y: AlignMin}},
+		{"xMinYMid", PreserveAspectRatio{X: AlignMin, Y: AlignMid}},
+		{"xMidYMid", PreserveAspectRatio{X: AlignMid, Y: AlignMid}},
+		{"xMaxYMid", PreserveAspectRatio{X: AlignMax, Y: AlignMid}},
+		{"xMinYMax", PreserveAspectRatio{X: AlignMin, Y: AlignMax}},
+		{"xMidYMax", PreserveAspectRatio{X: AlignMid, Y: AlignMax}},
+		{"xMaxYMax", PreserveAspectRatio{X: AlignMax, Y: AlignMax}},
+		{"none", PreserveAspectRatio{None: true}},
+		{"xMaxYMin meet", PreserveAspectRatio{X: AlignMax, Y: AlignMin}},
+		{"xMaxYMin slice", PreserveAspectRatio{X: AlignMax, Y: AlignMin, Slice: true}},
+		{"defer xMinYMax slice", PreserveAspectRatio{X: AlignMin, Y: AlignMax, Slice: true}},
+		{"  defer   none  ", PreserveAspectRatio{None: true}},
+	} {
+		got, err := ParsePreserveAspectRatio(test.s)
+		if err != nil {
+			t.Errorf("%s: %s", test.s, err)
+			continue
+		}
+		if got != test.expected {
+			t.Errorf("%s: expected %v, got %v", test.s, test.expected, got)
+		}
+	}
+
+	if (PreserveAspectRatio{}) != (PreserveAspectRatio{X: AlignMid, Y: AlignMid}) {
+		t.Error("the zero value should be xMidYMid meet")
+	}
+
+	for _, s := range []string{
+		"",
+		"defer",
+		"xmidymid",
+		"xMidYMed",
+		"yMidXMid",
+		"xMidYMid cover",
+		"xMidYMid meet slice",
+		"meet",
+		"slice xMidYMid",
+	} {
+		if _, err := ParsePreserveAspectRatio(s); err == nil {
+			t.Errorf("expected an error for '%s'", s)
+		}
+	}
+}
+
+func TestIntrinsicSize(t *testing.T) {
+	for _, test := range []struct {
+		attrs         string
+		width, height float64
+	}{
+		{`viewBox="0 0 100 50"`, 100, 50},
+		{`width="200" height="100" viewBox="0 0 100 100"`, 200, 100},
+		{`width="200" viewBox="0 0 100 50"`, 200, 100},
+		{`height="1in" viewBox="0 0 100 50"`, 192, 96},
+		{`width="100%" height="100%" viewBox="0 0 100 50"`, 100, 50},
+		{`width="30" height="20"`, 30, 20},
+	} {
+		icon, err := ReadIconStream(strings.NewReader(`<svg xmlns="http://www.w3.org/2000/svg" `+test.attrs+`></svg>`), StrictErrorMode)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w, h, ok := icon.IntrinsicSize()
+		if !ok || w != test.width || h != test.height {
+			t.Errorf("%s: expected %gx%g, got %gx%g (%v)", test.attrs, test.width, test.height, w, h, ok)
+		}
+		if r := icon.AspectRatio(); r != test.width/test.height {
+			t.Errorf("%s: unexpected aspect ratio %g", test.attrs, r)
+		}
+	}
+}
+
+func TestSetTargetViewport(t *testing.T) {
+	const src = `<svg xmlns="http://www.w3.org/2000/svg" width="200" height="100" viewBox="0 0 100 100">
+	<rect width="100" height="100"/></svg>`
+	icon, err := ReadIconStream(strings.NewReader(src), StrictErrorMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the square view box is centered in the 2:1 viewport, without distortion
+	icon.SetTarget(0, 0, 200, 100)
+	if exp := Identity.Translate(50, 0); !matrixAlmostEqual(icon.Transform, exp) {
+		t.Errorf("expected %v, got %v", exp, icon.Transform)
+	}
+	// the viewport itself is stretched to the target
+	icon.SetTarget(10, 10, 400, 400)
+	if exp := Identity.Translate(10, 10).Scale(2, 4).Translate(50, 0); !matrixAlmostEqual(icon.Transform, exp) {
+		t.Errorf("expected %v, got %v", exp, icon.Transform)
+	}
+	// consistent view box and viewport
+	icon.Width, icon.Height = "100", "100"
+	icon.SetTarget(0, 0, 50, 50)
+	if exp := Identity.Scale(0.5, 0.5); !matrixAlmostEqual(icon.Transform, exp) {
+		t.Errorf("expected %v, got %v", exp, icon.Transform)
+	}
+}
+
+func TestRootPreserveAspectRatio(t *testing.T) {
+	for _, test := range []struct {
+		aspect string
+		exp    PreserveAspectRatio
+		target Matrix2D // for a 200x100 target
+	}{
+		{"", PreserveAspectRatio{}, Identity.Translate(50, 0)},
+		{"xMidYMid meet", PreserveAspectRatio{}, Identity.Translate(50, 0)},
+		{"xMinYMin meet", PreserveAspectRatio{X: AlignMin, Y: AlignMin}, Identity},
+		{"xMaxYMax", PreserveAspectRatio{X: AlignMax, Y: AlignMax}, Identity.Translate(100, 0)},
+		{"xMinYMin slice", PreserveAspectRatio{X: AlignMin, Y: AlignMin, Slice: true}, Identity.Scale(2, 2)},
+		{"xMidYMax slice", PreserveAspectRatio{Y: AlignMax, Slice: true}, Identity.Translate(0, -100).Scale(2, 2)},
+		{"none", PreserveAspectRatio{None: true}, Identity.Scale(2, 1)},
+	} {
+		attr := ""
+		if test.aspect != "" {
+			attr = `preserveAspectRatio="` + test.aspect + `"`
+		}
+		icon, err := ReadIconStream(strings.NewReader(`<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 100 100" `+attr+`></svg>`), StrictErrorMode)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if icon.PreserveAspectRatio != test.exp {
+			t.Errorf("%s: expected %v, got %v", test.aspect, test.exp, icon.PreserveAspectRatio)
+		}
+		icon.SetTargetPreserveAspect(0, 0, 200, 100)
+		if !matrixAlmostEqual(icon.Transform, test.target) {
+			t.Errorf("%s: expected %v, got %v", test.aspect, test.target, icon.Transform)
+		}
+		// SetTarget still stretches the viewport
+		icon.SetTarget(0, 0, 200, 100)
+		if exp := Identity.Scale(2, 1); !matrixAlmostEqual(icon.Transform, exp) {
+			t.Errorf("%s: expected %v, got %v", test.aspect, exp, icon.Transform)
+		}
+	}
+
+	// the attribute is also used to fit the view box into the viewport
+	icon, err := ReadIconStream(strings.NewReader(`<svg xmlns="http://www.w3.org/2000/svg" width="200" height="100" viewBox="0 0 100 100" preserveAspectRatio="xMaxYMid"></svg>`), StrictErrorMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	icon.SetTarget(0, 0, 200, 100)
+	if exp := Identity.Translate(100, 0); !matrixAlmostEqual(icon.Transform, exp) {
+		t.Errorf("expected %v, got %v", exp, icon.Transform)
+	}
+
+	if _, err = ReadIconStream(strings.NewReader(`<svg viewBox="0 0 10 10" preserveAspectRatio="xMidYMid cover"></svg>`), StrictErrorMode); err == nil {
+		t.Error("expected an error for an invalid preserveAspectRatio")
+	}
+	icon, err = ReadIconStream(strings.NewReader(`<svg viewBox="0 0 10 10" preserveAspectRatio="center"></svg>`), IgnoreErrorMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if icon.PreserveAspectRatio != (PreserveAspectRatio{}) {
+		t.Errorf("expected the default value for an invalid attribute, got %v", icon.PreserveAspectRatio)
+	}
+}
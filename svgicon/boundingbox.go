@@ -0,0 +1,257 @@
+package svgicon
+
+import (
+	"math"
+
+	"golang.org/x/image/math/fixed"
+)
+
+// compute the tight bounding box of a path, using the extrema
+// of the Bezier curves instead of their control points
+
+type line [2]fixed.Point26_6
+
+func (l line) criticalPoints() (tX, tY []float64) {
+	return nil, nil
+}
+
+func (l line) evaluateCurve(t float64) (x, y float64) {
+	p0, p1 := fromFixed(l[0]), fromFixed(l[1])
+	return bezierLine(p0[0], p1[0], t), bezierLine(p0[1], p1[1], t)
+}
+
+func bezierLine(p0, p1, t float64) float64 {
+	return (p1-p0)*t + p0
+}
+
+type quadBezier [3]fixed.Point26_6
+
+// quadratic polinomial
+// x = At^2 + Bt + C
+// where
+// A = p0 + p2 - 2p1
+// B = 2(p1 - p0)
+// C = p0
+func bezierQuad(p0, p1, p2, t float64) float64 {
+	return (p0+p2-2*p1)*t*t + 2*(p1-p0)*t + p0
+}
+
+// derivative as at + b where a,b :
+func quadraticDerivative(p0, p1, p2 float64) (a, b float64) {
+	return 2 * (p2 - p1 - (p1 - p0)), 2 * (p1 - p0)
+}
+
+// handle the case where a = 0
+func linearRoots(a, b float64) []float64 {
+	if a == 0 {
+		return nil
+	}
+	return []float64{-b / a}
+}
+
+func (cu quadBezier) criticalPoints() (tX, tY []float64) {
+	p0, p1, p2 := fromFixed(cu[0]), fromFixed(cu[1]), fromFixed(cu[2])
+
+	aX, bX := quadraticDerivative(p0[0], p1[0], p2[0])
+	aY, bY := quadraticDerivative(p0[1], p1[1], p2[1])
+
+	return linearRoots(aX, bX), linearRoots(aY, bY)
+}
+
+func (cu quadBezier) evaluateCurve(t float64) (x, y float64) {
+	p0, p1, p2 := fromFixed(cu[0]), fromFixed(cu[1]), fromFixed(cu[2])
+	return bezierQuad(p0[0], p1[0], p2[0], t), bezierQuad(p0[1], p1[1], p2[1], t)
+}
+
+type cubicBezier [4]fixed.Point26_6
+
+func (cu cubicBezier) criticalPoints() (tX, tY []float64) {
+	p1, c1, c2, p2 := fromFixed(cu[0]), fromFixed(cu[1]), fromFixed(cu[2]), fromFixed(cu[3])
+
+	aX, bX, cX := cubicDerivative(p1[0], c1[0], c2[0], p2[0])
+	aY, bY, cY := cubicDerivative(p1[1], c1[1], c2[1], p2[1])
+
+	return quadraticRoots(aX, bX, cX), quadraticRoots(aY, bY, cY)
+}
+
+func (cu cubicBezier) evaluateCurve(t float64) (x, y float64) {
+	p0, p1, p2, p3 := fromFixed(cu[0]), fromFixed(cu[1]), fromFixed(cu[2]), fromFixed(cu[3])
+	return bezierSpline(p0[0], p1[0], p2[0], p3[0], t), bezierSpline(p0[1], p1[1], p2[1], p3[1], t)
+}
+
+// cubic polinomial
+// x = At^3 + Bt^2 + Ct + D
+// where A,B,C,D:
+// A = p3 -3 * p2 + 3 * p1 - p0
+// B = 3 * p2 - 6 * p1 +3 * p0
+// C = 3 * p1 - 3 * p0
+// D = p0
+func bezierSpline(p0, p1, p2, p3, t float64) float64 {
+	return (p3-3*p2+3*p1-p0)*t*t*t +
+		(3*p2-6*p1+3*p0)*t*t +
+		(3*p1-3*p0)*t +
+		(p0)
+}
+
+// We would like to know the values of t where X = 0
+// X  = (p3-3*p2+3*p1-p0)t^3 + (3*p2-6*p1+3*p0)t^2 + (3*p1-3*p0)t + (p0)
+// Derivative :
+// X' = 3(p3-3*p2+3*p1-p0)t^(3-1) + 2(6*p2-12*p1+6*p0)t^(2-1) + 1(3*p1-3*p0)t^(1-1)
+// simplified:
+// X' = (3*p3-9*p2+9*p1-3*p0)t^2 + (6*p2-12*p1+6*p0)t + (3*p1-3*p0)
+// taken as aX^2 + bX + c  a,b and c are:
+func cubicDerivative(p0, p1, p2, p3 float64) (a, b, c float64) {
+	return 3*p3 - 9*p2 + 9*p1 - 3*p0, 6*p2 - 12*p1 + 6*p0, 3*p1 - 3*p0
+}
+
+// b^2 - 4ac = Determinant
+func determinant(a, b, c float64) float64 { return b*b - 4*a*c }
+
+func solve(a, b, c float64, s bool) float64 {
+	sign := 1.
+	if !s {
+		sign = -1.
+	}
+	return (-b + math.Sqrt((b*b)-(4*a*c))*sign) / (2 * a)
+}
+
+func quadraticRoots(a, b, c float64) []float64 {
+	if a == 0 {
+		// aX^2 + bX + c well then then this is a simple line
+		// x= -c / b
+		return linearRoots(b, c)
+	}
+
+	d := determinant(a, b, c)
+	if d < 0 {
+		return nil
+	}
+
+	if d == 0 {
+		return []float64{solve(a, b, c, true)}
+	}
+	return []float64{
+		solve(a, b, c, true),
+		solve(a, b, c, false),
+	}
+}
+
+type bezier interface {
+	// compute the t zeroing the derivative
+	criticalPoints() (tX, tY []float64)
+	// compute the point a time t
+	evaluateCurve(t float64) (x, y float64)
+}
+
+func computeBoundingBox(curve bezier) fixed.Rectangle26_6 {
+	resX, resY := curve.criticalPoints()
+
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+
+	// add begin and end point
+	for _, t := range append(append(resX, 0, 1), resY...) {
+		// filter invalid value
+		if !(0 <= t && t <= 1) {
+			continue
+		}
+		x, y := curve.evaluateCurve(t)
+
+		minX, maxX = math.Min(x, minX), math.Max(x, maxX)
+		minY, maxY = math.Min(y, minY), math.Max(y, maxY)
+	}
+	return fixed.Rectangle26_6{
+		Min: fixed.Point26_6{X: fToFixed(minX), Y: fToFixed(minY)},
+		Max: fixed.Point26_6{X: fToFixed(maxX), Y: fToFixed(maxY)},
+	}
+}
+
+// BoundingBox stores the current bounding box
+// and exposes method to update it.
+// Contrary to the bounds of the control points,
+// the box is tight around Bezier curves.
+type BoundingBox struct {
+	BBox    fixed.Rectangle26_6
+	a       fixed.Point26_6 // current point, used to compute the next boundingBox
+	started bool            // false before the first point
+}
+
+// union returns the smallest rectangle containing r and s.
+// Contrary to fixed.Rectangle26_6.Union, degenerate rectangles
+// (such as the bounding box of an horizontal line) are not ignored.
+func union(r, s fixed.Rectangle26_6) fixed.Rectangle26_6 {
+	if s.Min.X < r.Min.X {
+		r.Min.X = s.Min.X
+	}
+	if s.Min.Y < r.Min.Y {
+		r.Min.Y = s.Min.Y
+	}
+	if s.Max.X > r.Max.X {
+		r.Max.X = s.Max.X
+	}
+	if s.Max.Y > r.Max.Y {
+		r.Max.Y = s.Max.Y
+	}
+	return r
+}
+
+func (p *BoundingBox) add(box fixed.Rectangle26_6) {
+	if !p.started {
+		p.BBox = box
+		p.started = true
+		return
+	}
+	p.BBox = union(p.BBox, box)
+}
+
+// IsEmpty returns true if no point has been added.
+func (p *BoundingBox) IsEmpty() bool { return !p.started }
+
+// Start starts a new sub-path at `a`.
+func (p *BoundingBox) Start(a fixed.Point26_6) {
+	p.a = a
+	p.add(fixed.Rectangle26_6{Min: a, Max: a}) // degenerate case
+}
+
+// Line extends the box with the segment from the current point to `b`.
+func (p *BoundingBox) Line(b fixed.Point26_6) {
+	p.add(computeBoundingBox(line{p.a, b}))
+	p.a = b
+}
+
+// QuadBezier extends the box with the quadratic curve from the current point
+// to `c`, with control point `b`.
+func (p *BoundingBox) QuadBezier(b fixed.Point26_6, c fixed.Point26_6) {
+	p.add(computeBoundingBox(quadBezier{p.a, b, c}))
+	p.a = c
+}
+
+// CubeBezier extends the box with the cubic curve from the current point
+// to `d`, with control points `b` and `c`.
+func (p *BoundingBox) CubeBezier(b fixed.Point26_6, c fixed.Point26_6, d fixed.Point26_6) {
+	p.add(computeBoundingBox(cubicBezier{p.a, b, c, d}))
+	p.a = d
+}
+
+// tightBounds returns the bounding box of the path transformed by `m`,
+// using the extrema of the curves, or false for an empty path.
+func (p Path) tightBounds(m Matrix2D) (Bounds, bool) {
+	var bb BoundingBox
+	for _, op := range p.transform(m) {
+		switch op := op.(type) {
+		case OpMoveTo:
+			bb.Start(fixed.Point26_6(op))
+		case OpLineTo:
+			bb.Line(fixed.Point26_6(op))
+		case OpQuadTo:
+			bb.QuadBezier(op[0], op[1])
+		case OpCubicTo:
+			bb.CubeBezier(op[0], op[1], op[2])
+		}
+	}
+	if bb.IsEmpty() {
+		return Bounds{}, false
+	}
+	min, max := fromFixed(bb.BBox.Min), fromFixed(bb.BBox.Max)
+	return Bounds{X: min[0], Y: min[1], W: max[0] - min[0], H: max[1] - min[1]}, true
+}
@@ -0,0 +1,25 @@
+package svgicon
+
+import "math"
+
+// Bounds are considered as closed rectangles: points on their
+// edges are inside, and rectangles with a zero width or height
+// (such as the extent of a horizontal line) are valid.
+
+// Contains returns true if the point (x, y) is inside `b`,
+// including its edges.
+func (b Bounds) Contains(x, y float64) bool {
+	return b.X <= x && x <= b.X+b.W && b.Y <= y && y <= b.Y+b.H
+}
+
+// Intersects returns true if `b` and `o` share at least one point.
+func (b Bounds) Intersects(o Bounds) bool {
+	return o.X <= b.X+b.W && b.X <= o.X+o.W && o.Y <= b.Y+b.H && b.Y <= o.Y+o.H
+}
+
+// Union returns the smallest rectangle containing both `b` and `o`.
+func (b Bounds) Union(o Bounds) Bounds {
+	minX, minY := math.Min(b.X, o.X), math.Min(b.Y, o.Y)
+	maxX, maxY := math.Max(b.X+b.W, o.X+o.W), math.Max(b.Y+b.H, o.Y+o.H)
+	return Bounds{X: minX, Y: minY, W: maxX - minX, H: maxY - minY}
+}
@@ -0,0 +1,59 @@
+package svgicon
+
+import "testing"
+
+func TestBoundsContains(t *testing.T) {
+	b := Bounds{X: 10, Y: 20, W: 5, H: 10}
+	for _, test := range []struct {
+		x, y     float64
+		expected bool
+	}{
+		{12, 25, true},
+		{10, 20, true}, // edges are included
+		{15, 30, true},
+		{9, 25, false},
+		{12, 31, false},
+	} {
+		if got := b.Contains(test.x, test.y); got != test.expected {
+			t.Errorf("Contains(%g, %g): expected %v, got %v", test.x, test.y, test.expected, got)
+		}
+	}
+}
+
+func TestBoundsIntersects(t *testing.T) {
+	b := Bounds{X: 0, Y: 0, W: 10, H: 10}
+	for _, test := range []struct {
+		o        Bounds
+		expected bool
+	}{
+		{Bounds{X: 5, Y: 5, W: 10, H: 10}, true},
+		{Bounds{X: 2, Y: 2, W: 1, H: 1}, true},
+		{Bounds{X: -5, Y: -5, W: 20, H: 20}, true},
+		{Bounds{X: 10, Y: 0, W: 5, H: 5}, true}, // shared edge
+		{Bounds{X: 2, Y: 5, W: 5, H: 0}, true},  // horizontal line
+		{Bounds{X: 11, Y: 0, W: 5, H: 5}, false},
+		{Bounds{X: 0, Y: -6, W: 5, H: 5}, false},
+	} {
+		if got := b.Intersects(test.o); got != test.expected {
+			t.Errorf("Intersects(%v): expected %v, got %v", test.o, test.expected, got)
+		}
+		if got := test.o.Intersects(b); got != test.expected {
+			t.Errorf("Intersects is not symmetric for %v", test.o)
+		}
+	}
+}
+
+func TestBoundsUnion(t *testing.T) {
+	for _, test := range []struct {
+		b, o, expected Bounds
+	}{
+		{Bounds{0, 0, 10, 10}, Bounds{5, 5, 10, 10}, Bounds{0, 0, 15, 15}},
+		{Bounds{0, 0, 10, 10}, Bounds{2, 2, 1, 1}, Bounds{0, 0, 10, 10}},
+		{Bounds{-5, 2, 1, 1}, Bounds{3, -4, 2, 0}, Bounds{-5, -4, 10, 7}},
+		{Bounds{0, 5, 10, 0}, Bounds{5, 0, 0, 10}, Bounds{0, 0, 10, 10}}, // lines
+	} {
+		if got := test.b.Union(test.o); got != test.expected {
+			t.Errorf("Union(%v, %v): expected %v, got %v", test.b, test.o, test.expected, got)
+		}
+	}
+}
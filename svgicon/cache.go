@@ -0,0 +1,86 @@
+package svgicon
+
+import (
+	"bytes"
+	"container/list"
+	"crypto/sha256"
+	"sync"
+)
+
+// IconCache stores the most recently parsed icons, indexed by
+// the hash of their content. It is safe for concurrent use.
+//
+// The returned icons are shared between callers, and must be
+// treated as read-only : in particular, SetTarget must not be called.
+type IconCache struct {
+	options    ParseOptions // used to parse new icons, fixed at creation
+	maxEntries int
+
+	lock    sync.Mutex
+	entries map[[sha256.Size]byte]*list.Element
+	order   *list.List // most recently used first
+}
+
+type cacheEntry struct {
+	key  [sha256.Size]byte
+	icon *SvgIcon
+}
+
+// NewIconCache returns an empty cache, storing at most `maxEntries` icons.
+// A non positive `maxEntries` means no limit.
+// Icons are parsed with the options of ReadIconStream, with IgnoreErrorMode.
+func NewIconCache(maxEntries int) *IconCache {
+	return NewIconCacheOptions(maxEntries, ParseOptions{ErrorMode: IgnoreErrorMode, CharsetReader: DefaultCharsetReader})
+}
+
+// NewIconCacheOptions is the same as NewIconCache, but parses
+// the icons with `options`, which may not be changed afterwards.
+func NewIconCacheOptions(maxEntries int, options ParseOptions) *IconCache {
+	return &IconCache{
+		options:    options,
+		maxEntries: maxEntries,
+		entries:    make(map[[sha256.Size]byte]*list.Element),
+		order:      list.New(),
+	}
+}
+
+// Get returns the icon parsed from `data`, using the cached version if any.
+// Parsing errors are not cached.
+func (ic *IconCache) Get(data []byte) (*SvgIcon, error) {
+	key := sha256.Sum256(data)
+
+	ic.lock.Lock()
+	if elem, ok := ic.entries[key]; ok {
+		ic.order.MoveToFront(elem)
+		ic.lock.Unlock()
+		return elem.Value.(cacheEntry).icon, nil
+	}
+	ic.lock.Unlock()
+
+	// do not block the other callers while parsing
+	icon, err := ReadIconStreamOptions(bytes.NewReader(data), ic.options)
+	if err != nil {
+		return nil, err
+	}
+
+	ic.lock.Lock()
+	defer ic.lock.Unlock()
+	if elem, ok := ic.entries[key]; ok { // parsed concurrently
+		ic.order.MoveToFront(elem)
+		return elem.Value.(cacheEntry).icon, nil
+	}
+	ic.entries[key] = ic.order.PushFront(cacheEntry{key: key, icon: icon})
+	if ic.maxEntries > 0 && ic.order.Len() > ic.maxEntries {
+		oldest := ic.order.Back()
+		ic.order.Remove(oldest)
+		delete(ic.entries, oldest.Value.(cacheEntry).key)
+	}
+	return icon, nil
+}
+
+// Len returns the number of cached icons.
+func (ic *IconCache) Len() int {
+	ic.lock.Lock()
+	defer ic.lock.Unlock()
+	return ic.order.Len()
+}
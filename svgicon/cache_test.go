@@ -0,0 +1,61 @@
+package svgicon
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestIconCache(t *testing.T) {
+	icon := func(size int) []byte {
+		return []byte(fmt.Sprintf(`<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 %d %d"><rect width="4" height="4"/></svg>`, size, size))
+	}
+	cache := NewIconCache(2)
+	ic1, err := cache.Get(icon(10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ic1.ViewBox.W != 10 {
+		t.Errorf("unexpected view box %v", ic1.ViewBox)
+	}
+	// a cache hit returns the same icon, without parsing
+	ic1bis, err := cache.Get(icon(10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ic1bis != ic1 {
+		t.Error("expected a cache hit")
+	}
+
+	ic2, _ := cache.Get(icon(20))
+	if ic2 == ic1 {
+		t.Error("unexpected cache hit")
+	}
+	_, _ = cache.Get(icon(10)) // icon(20) is now the least recently used
+	_, _ = cache.Get(icon(30))
+	if cache.Len() != 2 {
+		t.Errorf("expected 2 entries, got %d", cache.Len())
+	}
+	if ic, _ := cache.Get(icon(10)); ic != ic1 {
+		t.Error("expected a cache hit")
+	}
+	if ic, _ := cache.Get(icon(20)); ic == ic2 {
+		t.Error("expected the least recently used icon to be evicted")
+	}
+
+	if _, err = cache.Get([]byte("<svg")); err == nil {
+		t.Error("expected error for invalid input")
+	}
+	if cache.Len() != 2 {
+		t.Errorf("errors should not be cached")
+	}
+}
+
+func TestIconCacheOptions(t *testing.T) {
+	const src = `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 10 10"><mask/><rect width="4" height="4"/></svg>`
+	if _, err := NewIconCache(0).Get([]byte(src)); err != nil {
+		t.Errorf("expected the error to be ignored, got %v", err)
+	}
+	if _, err := NewIconCacheOptions(0, ParseOptions{ErrorMode: StrictErrorMode}).Get([]byte(src)); err == nil {
+		t.Error("expected an error in strict mode")
+	}
+}
@@ -0,0 +1,14 @@
+//go:build !oksvg_nocharset
+// +build !oksvg_nocharset
+
+package svgicon
+
+import "golang.org/x/net/html/charset"
+
+// DefaultCharsetReader is used by ReadIconStream to decode
+// SVG files which are not UTF-8 encoded. It supports
+// the encodings defined in golang.org/x/net/html/charset.
+// Build with the 'oksvg_nocharset' tag to remove this dependency
+// (and reduce the binary size) : DefaultCharsetReader is then nil, and
+// only UTF-8 (and US-ASCII) files are accepted.
+var DefaultCharsetReader = charset.NewReaderLabel
@@ -0,0 +1,10 @@
+//go:build oksvg_nocharset
+// +build oksvg_nocharset
+
+package svgicon
+
+import "io"
+
+// DefaultCharsetReader is nil when building with the
+// 'oksvg_nocharset' tag : only UTF-8 (and US-ASCII) files are accepted.
+var DefaultCharsetReader func(label string, input io.Reader) (io.Reader, error)
@@ -0,0 +1,240 @@
+package svgicon
+
+import (
+	"math"
+	"strings"
+)
+
+// This file implements the CSS basic shapes used as clip-path:
+// inset(), circle() and polygon(). The reference box is the bounding
+// box of the element, which is only known once all its descendants are parsed.
+
+// clipShape is a pending clip-path shape
+type clipShape struct {
+	function, args string
+	transform      Matrix2D // user space of the element
+	firstPath      int      // index of the first path of the element
+	depth          int      // size of the style stack for the element
+}
+
+// readClipPath registers the clip-path property of the element
+// whose style is on top of the stack.
+// Only the basic shapes are supported: references to <clipPath>
+// elements are ignored.
+func (c *iconCursor) readClipPath(v string) error {
+	if v == "none" || strings.HasPrefix(v, "url(") {
+		return nil
+	}
+	start, end := strings.IndexByte(v, '('), strings.LastIndexByte(v, ')')
+	if start == -1 || end < start {
+		return c.handleError("invalid value '%s' for <clip-path>", v)
+	}
+	// an eventual reference box keyword is ignored
+	function := strings.ToLower(strings.TrimSpace(v[:start]))
+	switch function {
+	case "inset", "circle", "polygon":
+	default:
+		return c.handleError("unsupported value '%s' for <clip-path>", v)
+	}
+	c.clipShapes = append(c.clipShapes, clipShape{
+		function:  function,
+		args:      v[start+1 : end],
+		transform: c.styleStack[len(c.styleStack)-1].transform,
+		firstPath: len(c.icon.SVGPaths),
+		depth:     len(c.styleStack),
+	})
+	return nil
+}
+
+// popStyle pops the style stack, applying the clip-path
+// shape of the closed element, if any.
+func (c *iconCursor) popStyle() error {
+	depth := len(c.styleStack)
+	if L := len(c.clipShapes); L != 0 && c.clipShapes[L-1].depth == depth {
+		shape := c.clipShapes[L-1]
+		c.clipShapes = c.clipShapes[:L-1]
+		if err := c.applyClipShape(shape); err != nil {
+			return err
+		}
+	}
+	c.styleStack = c.styleStack[:depth-1]
+	return nil
+}
+
+// applyClipShape clips the paths of the element
+func (c *iconCursor) applyClipShape(shape clipShape) error {
+	paths := c.icon.SVGPaths[shape.firstPath:]
+	if len(paths) == 0 {
+		return nil
+	}
+	// compute the bounding box of the element, in its user space
+	box, _ := pathsBounds(paths, shape.transform.Invert(), false)
+
+	clip, err := c.shapePath(shape, box)
+	if err != nil {
+		return c.handleError("invalid value '%s(%s)' for <clip-path>: %s", shape.function, shape.args, err)
+	}
+	clip = clip.transform(shape.transform) // clips are expressed in icon coordinates
+	for i := range paths {
+		style := &paths[i].Style
+		// do not share the backing array with the other paths
+		style.clips = append(append([]Path(nil), style.clips...), clip)
+	}
+	return nil
+}
+
+// shapePath returns the path of the shape, relative to the reference box `box`
+func (c *iconCursor) shapePath(shape clipShape, box Bounds) (Path, error) {
+	var out Path
+	switch shape.function {
+	case "inset":
+		args := strings.Fields(shape.args)
+		var radius []string
+		for i, arg := range args {
+			if arg == "round" {
+				args, radius = args[:i], args[i+1:]
+				break
+			}
+		}
+		if len(args) == 0 || len(args) > 4 {
+			return nil, errPathParamMismatch
+		}
+		// top, right, bottom, left
+		var offsets [4]float64
+		for i := range offsets {
+			// missing values are copied from the opposite side
+			arg := args[0]
+			if i < len(args) {
+				arg = args[i]
+			} else if i == 3 && len(args) >= 2 {
+				arg = args[1]
+			}
+			ref := box.H
+			if i%2 == 1 {
+				ref = box.W
+			}
+			var err error
+			if offsets[i], err = c.resolveShapeLength(arg, ref); err != nil {
+				return nil, err
+			}
+		}
+		minX, minY := box.X+offsets[3], box.Y+offsets[0]
+		maxX, maxY := math.Max(minX, box.X+box.W-offsets[1]), math.Max(minY, box.Y+box.H-offsets[2])
+		var rx, ry float64
+		if len(radius) != 0 {
+			var err error
+			if rx, err = c.resolveShapeLength(radius[0], box.W); err != nil {
+				return nil, err
+			}
+			if ry, err = c.resolveShapeLength(radius[0], box.H); err != nil {
+				return nil, err
+			}
+		}
+		out.addRoundRect(minX, minY, maxX, maxY, rx, ry, 0)
+	case "circle":
+		args := strings.Fields(shape.args)
+		var position []string
+		for i, arg := range args {
+			if arg == "at" {
+				args, position = args[:i], args[i+1:]
+				break
+			}
+		}
+		if len(args) > 1 {
+			return nil, errPathParamMismatch
+		}
+		cx, cy, err := c.resolvePosition(position, box)
+		if err != nil {
+			return nil, err
+		}
+		r := math.Min(math.Min(cx-box.X, box.X+box.W-cx), math.Min(cy-box.Y, box.Y+box.H-cy)) // closest-side
+		if len(args) == 1 {
+			switch args[0] {
+			case "closest-side":
+			case "farthest-side":
+				r = math.Max(math.Max(cx-box.X, box.X+box.W-cx), math.Max(cy-box.Y, box.Y+box.H-cy))
+			default:
+				// percentages refer to the normalized diagonal
+				if r, err = c.resolveShapeLength(args[0], math.Hypot(box.W, box.H)/math.Sqrt2); err != nil {
+					return nil, err
+				}
+			}
+		}
+		var pc pathCursor
+		if r > 0 {
+			pc.ellipseAt(cx, cy, r, r)
+		}
+		out = pc.path
+	case "polygon":
+		args := strings.Split(shape.args, ",")
+		if first := strings.TrimSpace(args[0]); first == "nonzero" || first == "evenodd" {
+			args = args[1:] // the fill rule of clip paths is not supported
+		}
+		for i, arg := range args {
+			coords := strings.Fields(arg)
+			if len(coords) != 2 {
+				return nil, errPathParamMismatch
+			}
+			x, err := c.resolveShapeLength(coords[0], box.W)
+			if err != nil {
+				return nil, err
+			}
+			y, err := c.resolveShapeLength(coords[1], box.H)
+			if err != nil {
+				return nil, err
+			}
+			if i == 0 {
+				out.Start(toFixedP(box.X+x, box.Y+y))
+			} else {
+				out.Line(toFixedP(box.X+x, box.Y+y))
+			}
+		}
+		out.Stop(true)
+	}
+	return out, nil
+}
+
+// resolveShapeLength resolves a length, where percentages refer to `ref`
+func (c *iconCursor) resolveShapeLength(s string, ref float64) (float64, error) {
+	if strings.HasSuffix(s, "%") {
+		v, err := parseBasicFloat(strings.TrimSuffix(s, "%"))
+		return v * ref / 100, err
+	}
+	return c.parseUnit(s, widthPercentage)
+}
+
+// resolvePosition resolves a CSS <position> with one or two values,
+// defaulting to the center of the box
+func (c *iconCursor) resolvePosition(args []string, box Bounds) (x, y float64, err error) {
+	if len(args) > 2 {
+		return 0, 0, errPathParamMismatch
+	}
+	keywords := map[string]string{"left": "0%", "center": "50%", "right": "100%", "top": "0%", "bottom": "100%"}
+	xs, ys := "50%", "50%"
+	switch len(args) {
+	case 1:
+		if args[0] == "top" || args[0] == "bottom" {
+			ys = args[0]
+		} else {
+			xs = args[0]
+		}
+	case 2:
+		xs, ys = args[0], args[1]
+		if xs == "top" || xs == "bottom" || ys == "left" || ys == "right" {
+			xs, ys = ys, xs
+		}
+	}
+	if kw, ok := keywords[xs]; ok {
+		xs = kw
+	}
+	if kw, ok := keywords[ys]; ok {
+		ys = kw
+	}
+	if x, err = c.resolveShapeLength(xs, box.W); err != nil {
+		return 0, 0, err
+	}
+	if y, err = c.resolveShapeLength(ys, box.H); err != nil {
+		return 0, 0, err
+	}
+	return box.X + x, box.Y + y, nil
+}
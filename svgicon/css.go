@@ -0,0 +1,324 @@
+package svgicon
+
+// this file implements a minimal support for CSS
+// style sheets, given in <style> elements, and for
+// the CSS syntax of the transform properties
+
+import (
+	"encoding/xml"
+	"errors"
+	"math"
+	"strings"
+)
+
+// cssRule applies its declarations to the elements
+// matching its selector
+type cssRule struct {
+	selector     string   // ".class", "#id" or "element"
+	declarations []string // "property:value" pairs
+}
+
+// specificity returns 0 for element selectors, 1 for class
+// selectors and 2 for id selectors
+func (rule cssRule) specificity() int {
+	switch rule.selector[0] {
+	case '.':
+		return 1
+	case '#':
+		return 2
+	default:
+		return 0
+	}
+}
+
+func (rule cssRule) matches(tag, id string, classes []string) bool {
+	switch rule.specificity() {
+	case 1:
+		for _, class := range classes {
+			if rule.selector[1:] == class {
+				return true
+			}
+		}
+		return false
+	case 2:
+		return id != "" && rule.selector[1:] == id
+	default:
+		return rule.selector == tag
+	}
+}
+
+// parseStyleSheet parses the content of a <style> element.
+// Only simple class, id and element selectors (possibly grouped,
+// as in ".a, #b, rect") are supported; other rules are ignored.
+func parseStyleSheet(css string) []cssRule {
+	css = stripComments(css)
+	var rules []cssRule
+	for {
+		start := strings.IndexByte(css, '{')
+		if start == -1 {
+			break
+		}
+		end := strings.IndexByte(css[start:], '}')
+		if end == -1 {
+			break
+		}
+		end += start
+		selectors, block := css[:start], css[start+1:end]
+		css = css[end+1:]
+
+		declarations := strings.Split(block, ";")
+		for _, selector := range strings.Split(selectors, ",") {
+			selector = strings.TrimSpace(selector)
+			if len(selector) == 0 || strings.ContainsAny(selector[1:], ".#:[ >+~*") ||
+				(selector[0] != '.' && selector[0] != '#' && !isSelectorName(selector)) {
+				continue // not a simple selector
+			}
+			rules = append(rules, cssRule{selector: selector, declarations: declarations})
+		}
+	}
+	return rules
+}
+
+// stripComments removes the /* comments */ from `css`,
+// as well as the <!-- and --> tokens, which may be used to
+// hide the style sheet content from old browsers
+func stripComments(css string) string {
+	var b strings.Builder
+	for {
+		start := strings.Index(css, "/*")
+		if start == -1 {
+			break
+		}
+		b.WriteString(css[:start])
+		end := strings.Index(css[start+2:], "*/")
+		if end == -1 { // unterminated comment, extending to the end
+			css = ""
+			break
+		}
+		css = css[start+2+end+2:]
+	}
+	b.WriteString(css)
+	return strings.NewReplacer("<!--", "", "-->", "").Replace(b.String())
+}
+
+// isSelectorName returns true if `s` is a valid element name
+func isSelectorName(s string) bool {
+	for i, r := range s {
+		isLetter := ('a' <= r && r <= 'z') || ('A' <= r && r <= 'Z')
+		if !isLetter && (i == 0 || !('0' <= r && r <= '9' || r == '-' || r == '_')) {
+			return false
+		}
+	}
+	return true
+}
+
+// matchingDeclarations returns the declarations of the rules
+// matching the element, sorted by specificity (element, class then id
+// selectors) and then in style sheet order, so that the last ones win.
+func (c *iconCursor) matchingDeclarations(tag, id string, classes []string) []string {
+	var out []string
+	for specificity := 0; specificity <= 2; specificity++ {
+		for _, rule := range c.cssRules {
+			if rule.specificity() == specificity && rule.matches(tag, id, classes) {
+				out = append(out, rule.declarations...)
+			}
+		}
+	}
+	return out
+}
+
+// elementTransform stores the (unparsed) transform properties of an element
+type elementTransform struct {
+	attribute string // transform presentation attribute
+	css       string // CSS transform property, which takes precedence
+	origin    string // CSS transform-origin property
+	box       string // CSS transform-box property
+}
+
+// shapeFuncs are the draw functions of the elements
+// whose geometry is known from their own attributes
+var shapeFuncs = map[string]svgFunc{
+	"line":     lineF,
+	"rect":     rectF,
+	"circle":   circleF,
+	"ellipse":  circleF,
+	"polyline": polylineF,
+	"polygon":  polygonF,
+	"path":     pathF,
+}
+
+// referenceBox returns the box used to resolve the transform-origin of
+// the element `tag`, according to its transform-box property,
+// or nil for the view box.
+// Only the basic shapes support the fill-box (and stroke-box) values:
+// the other elements always use the view box.
+func (c *iconCursor) referenceBox(tag string, attrs []xml.Attr, box string, style PathStyle) *Bounds {
+	var withStroke bool
+	switch box {
+	case "fill-box", "content-box", "padding-box":
+	case "stroke-box", "border-box":
+		withStroke = true
+	default: // view-box is the initial value
+		return nil
+	}
+	ref, ok := c.shapeBounds(tag, attrs, style, withStroke)
+	if !ok {
+		return nil
+	}
+	return &ref
+}
+
+// shapeBounds returns the (untransformed) bounding box of the basic shape `tag`,
+// or false if the element is not a basic shape or is empty.
+// The geometry is built on a scratch cursor, so that neither the path
+// being built nor the style stack of `c` are modified; the errors are
+// ignored, since they are reported when the element is drawn.
+func (c *iconCursor) shapeBounds(tag string, attrs []xml.Attr, style PathStyle, withStroke bool) (Bounds, bool) {
+	df, ok := shapeFuncs[tag]
+	if !ok {
+		return Bounds{}, false
+	}
+	style.transform = Identity
+	scratch := iconCursor{
+		pathCursor: pathCursor{errorMode: IgnoreErrorMode, scale: c.scale},
+		icon:       c.icon,
+		styleStack: []PathStyle{style},
+	}
+	_ = df(&scratch, attrs)
+	svgp := SvgPath{Path: scratch.path, Style: scratch.styleStack[0]}
+	return pathsBounds([]SvgPath{svgp}, Identity, withStroke)
+}
+
+// resolveTransform applies the transform properties of an element to
+// `parent`. Percentages (in the CSS syntax only) refer to `ref`, or,
+// if it is nil, to the viewport, which is the default reference box for SVG elements.
+func (c *iconCursor) resolveTransform(parent Matrix2D, tr elementTransform, ref *Bounds) (Matrix2D, error) {
+	var ox, oy float64
+	if tr.origin != "" {
+		var err error
+		ox, oy, err = c.parseTransformOrigin(tr.origin, ref)
+		if err != nil {
+			return parent, err
+		}
+	}
+	m := parent.Translate(ox, oy)
+	var err error
+	if tr.css != "" {
+		m, err = c.parseTransformList(m, tr.css, c.getCSSTransformArgs)
+	} else if tr.attribute != "" {
+		m, err = c.parseTransformList(m, tr.attribute, c.getTransformArgs)
+	}
+	if err != nil {
+		return parent, err
+	}
+	return m.Translate(-ox, -oy), nil
+}
+
+// getCSSTransformArgs parses the arguments of the CSS transform function `name`
+// into c.points. Contrary to the presentation attribute, angles may have
+// units, and translations and rotation centers may be percentages.
+func (c *iconCursor) getCSSTransformArgs(name, args string) error {
+	c.points = c.points[:0]
+	for i, arg := range splitOnCommaOrSpace(args) {
+		var (
+			value float64
+			err   error
+		)
+		switch {
+		case name == "rotate" && i == 0, name == "skewx", name == "skewy":
+			value, err = parseAngle(arg)
+		case name == "rotate": // center
+			value, err = c.parseUnit(arg, [2]percentageReference{widthPercentage, heightPercentage}[(i-1)%2])
+		case name == "translate":
+			value, err = c.parseUnit(arg, [2]percentageReference{widthPercentage, heightPercentage}[i%2])
+		default:
+			value, err = parseBasicFloat(arg)
+		}
+		if err != nil {
+			return err
+		}
+		c.points = append(c.points, value)
+	}
+	if name == "matrix" { // the other translations are lengths, already scaled
+		c.scaleTransformArgs(name)
+	}
+	return nil
+}
+
+// parseAngle parses a CSS angle, returning its value in degrees.
+// A number without unit is interpreted as degrees.
+func parseAngle(s string) (float64, error) {
+	s = strings.TrimSpace(s)
+	for _, unit := range [...]struct {
+		suffix string
+		scale  float64
+	}{
+		{"deg", 1}, {"grad", 0.9}, {"rad", 180 / math.Pi}, {"turn", 360},
+	} {
+		if strings.HasSuffix(s, unit.suffix) {
+			value, err := parseBasicFloat(strings.TrimSuffix(s, unit.suffix))
+			return value * unit.scale, err
+		}
+	}
+	return parseBasicFloat(s)
+}
+
+// parseTransformOrigin parses the CSS transform-origin property,
+// resolving percentages against the viewport, or, if it is not nil, against `ref`,
+// whose origin is then the origin of the lengths. The z offset, if any, is ignored.
+func (c *iconCursor) parseTransformOrigin(v string, ref *Bounds) (x, y float64, err error) {
+	values := strings.Fields(v)
+	if len(values) == 0 || len(values) > 3 {
+		return 0, 0, errors.New("invalid transform-origin: " + v)
+	}
+	horizontal, vertical := "center", "center"
+	if len(values) == 1 {
+		if values[0] == "top" || values[0] == "bottom" {
+			vertical = values[0]
+		} else {
+			horizontal = values[0]
+		}
+	} else {
+		horizontal, vertical = values[0], values[1]
+		// keywords may be given in any order
+		if horizontal == "top" || horizontal == "bottom" || vertical == "left" || vertical == "right" {
+			horizontal, vertical = vertical, horizontal
+		}
+	}
+	keywords := map[string]string{"left": "0%", "top": "0%", "center": "50%", "right": "100%", "bottom": "100%"}
+	if kw, ok := keywords[horizontal]; ok {
+		horizontal = kw
+	}
+	if kw, ok := keywords[vertical]; ok {
+		vertical = kw
+	}
+	if ref == nil {
+		if x, err = c.parseUnit(horizontal, widthPercentage); err != nil {
+			return 0, 0, err
+		}
+		if y, err = c.parseUnit(vertical, heightPercentage); err != nil {
+			return 0, 0, err
+		}
+		return x, y, nil
+	}
+	if x, err = c.parseBoxUnit(horizontal, ref.W); err != nil {
+		return 0, 0, err
+	}
+	if y, err = c.parseBoxUnit(vertical, ref.H); err != nil {
+		return 0, 0, err
+	}
+	return ref.X + x, ref.Y + y, nil
+}
+
+// parseBoxUnit is the same as parseUnit, but resolves
+// percentages against `length`.
+func (c *iconCursor) parseBoxUnit(s string, length float64) (float64, error) {
+	value, isPercentage, err := parseUnit(s)
+	if err != nil {
+		return 0, err
+	}
+	if isPercentage {
+		return value / 100 * length, nil
+	}
+	return c.parseUnit(s, widthPercentage)
+}
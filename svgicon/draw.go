@@ -1,6 +1,8 @@
 package svgicon
 
 import (
+	"math"
+
 	"golang.org/x/image/math/fixed"
 )
 
@@ -61,11 +63,111 @@ type Driver interface {
 	SetupDrawers(willFill, willStroke bool) (Filler, Stroker)
 }
 
+// ClipDriver is an optional interface which may be implemented
+// by drivers supporting clipping.
+type ClipDriver interface {
+	Driver
+
+	// SetClip restricts the painting of the following paths to the
+	// intersection of the interiors of the given paths, whose transformation
+	// matrix are already applied.
+	// An empty slice removes the restriction.
+	SetClip(clips []Path)
+}
+
+// BlendDriver is an optional interface which may be implemented
+// by drivers supporting blend modes. Other drivers
+// always paint with NormalBlend.
+type BlendDriver interface {
+	Driver
+
+	// SetBlendMode sets the mode used to composite the
+	// following paths with the content already painted.
+	SetBlendMode(mode BlendMode)
+}
+
+// LayerDriver is an optional interface which may be implemented
+// by drivers supporting isolated groups (see the CSS isolation property).
+// Other drivers paint isolated groups as regular groups.
+type LayerDriver interface {
+	Driver
+
+	// BeginLayer starts a new, transparent compositing layer:
+	// the following paths are blended with the layer content only.
+	// Layers may be nested.
+	BeginLayer()
+
+	// EndLayer composites the current layer onto the previous one.
+	EndLayer()
+}
+
+// IconDriver is an optional interface which may be implemented
+// by drivers needing to setup (and tear down) some state around
+// the drawing of a whole icon, such as a clip to the viewport.
+type IconDriver interface {
+	Driver
+
+	// BeginIcon is called before the first path of the icon is drawn.
+	// `viewBox` is the view box of the icon, expressed in the driver
+	// coordinates (that is, transformed by the icon Transform matrix).
+	BeginIcon(viewBox Bounds)
+
+	// EndIcon is called after the last path of the icon is drawn.
+	EndIcon()
+}
+
+// ErrorDriver is an optional interface which may be implemented
+// by drivers reporting the failures (such as unsupported features)
+// met while drawing. See DrawWithError.
+type ErrorDriver interface {
+	Driver
+
+	// Err returns the first error encountered while drawing,
+	// or nil.
+	Err() error
+}
+
+// TextDriver is an optional interface which may be implemented
+// by drivers able to lay out text. Other drivers ignore
+// the texts (see SvgPath.Text).
+type TextDriver interface {
+	Driver
+
+	// TextPath returns the outlines of the glyphs of `text`,
+	// in the user space of the text element.
+	TextPath(text Text) Path
+}
+
+// ImageDriver is an optional interface which may be implemented
+// by drivers supporting raster images. Other drivers ignore
+// the images (see SvgPath.Image).
+type ImageDriver interface {
+	Driver
+
+	// DrawImage draws `img.Image`, scaled to cover `img.Bounds`,
+	// transformed by `m`, with the given opacity.
+	DrawImage(img Image, m Matrix2D, opacity float64)
+}
+
 type DashOptions struct {
 	Dash       []float64 // values for the dash pattern (nil or an empty slice for no dashes)
 	DashOffset float64   // starting offset into the dash array
 }
 
+// transform returns the dash options scaled by the (mean) scaling
+// factor of `m`, since the dashes are applied to transformed paths.
+func (d DashOptions) transform(m Matrix2D) DashOptions {
+	scale := math.Sqrt(math.Abs(m.A*m.D - m.B*m.C))
+	if scale == 1 || len(d.Dash) == 0 {
+		return d
+	}
+	out := DashOptions{Dash: make([]float64, len(d.Dash)), DashOffset: d.DashOffset * scale}
+	for i, v := range d.Dash {
+		out.Dash[i] = v * scale
+	}
+	return out
+}
+
 // JoinMode type to specify how segments join.
 type JoinMode uint8
 
@@ -160,8 +262,61 @@ func (g GapMode) String() string {
 	}
 }
 
+// BlendMode defines how a path is composited with the content
+// beneath it, as specified by the mix-blend-mode property.
+// Only the separable blend modes are supported.
+type BlendMode uint8
+
+const (
+	NormalBlend BlendMode = iota // default value
+	MultiplyBlend
+	ScreenBlend
+	OverlayBlend
+	DarkenBlend
+	LightenBlend
+	ColorDodgeBlend
+	ColorBurnBlend
+	HardLightBlend
+	SoftLightBlend
+	DifferenceBlend
+	ExclusionBlend
+)
+
+// blendModeNames stores the CSS keywords of the blend modes
+var blendModeNames = [...]string{
+	NormalBlend:     "normal",
+	MultiplyBlend:   "multiply",
+	ScreenBlend:     "screen",
+	OverlayBlend:    "overlay",
+	DarkenBlend:     "darken",
+	LightenBlend:    "lighten",
+	ColorDodgeBlend: "color-dodge",
+	ColorBurnBlend:  "color-burn",
+	HardLightBlend:  "hard-light",
+	SoftLightBlend:  "soft-light",
+	DifferenceBlend: "difference",
+	ExclusionBlend:  "exclusion",
+}
+
+func (b BlendMode) String() string {
+	if int(b) < len(blendModeNames) {
+		return blendModeNames[b]
+	}
+	return "<unknown BlendMode>"
+}
+
+// parseBlendMode returns the blend mode for the given CSS keyword
+func parseBlendMode(s string) (BlendMode, bool) {
+	for mode, name := range blendModeNames {
+		if name == s {
+			return BlendMode(mode), true
+		}
+	}
+	return 0, false
+}
+
 type JoinOptions struct {
-	MiterLimit   fixed.Int26_6 // he miter cutoff value for miter, arc, miterclip and arcClip joinModes
+	MiterLimit   fixed.Int26_6 // the miter cutoff value for miter, arc, miterclip and arcClip joinModes, as a ratio of the line width (4 is stored as 4 << 6)
 	LineJoin     JoinMode      // JoinMode for curve segments
 	TrailLineCap CapMode       // capping functions for leading and trailing line ends. If one is nil, the other function is used at both ends.
 
@@ -169,10 +324,26 @@ type JoinOptions struct {
 	LineGap     GapMode // not part of the standard specification. determines how a gap on the convex side of two lines joining is filled
 }
 
+// StrokeAlignment is the value of the stroke-alignment property,
+// proposed by SVG 2.
+type StrokeAlignment uint8
+
+const (
+	// CenterAlignment centers the stroke on the path (the default)
+	CenterAlignment StrokeAlignment = iota
+	// InnerAlignment draws the stroke inside the filled region of the path
+	InnerAlignment
+	// OuterAlignment draws the stroke outside the filled region of the path
+	OuterAlignment
+)
+
 type StrokeOptions struct {
 	LineWidth fixed.Int26_6 // width of the line
 	Join      JoinOptions
 	Dash      DashOptions
+	// Alignment may be ignored by drivers, which then
+	// draw centered strokes.
+	Alignment StrokeAlignment
 }
 
 // DefaultStyle sets the default PathStyle to fill black, winding rule,
@@ -189,13 +360,117 @@ var DefaultStyle = PathStyle{
 	},
 	FillerColor: NewPlainColor(0x00, 0x00, 0x00, 0xff),
 	transform:   Identity,
+	color:       NewPlainColor(0x00, 0x00, 0x00, 0xff),
+	fontSize:    DefaultFontSize,
+	opacity:     1,
 }
 
-// SetTarget sets the Transform matrix to draw within the bounds of the rectangle arguments
+// SetTarget sets the Transform matrix to draw within the bounds of the rectangle arguments.
+// The viewport defined by the width and height attributes is stretched to the rectangle,
+// and, if its aspect ratio differs from the view box one, the view box is fitted
+// into the viewport according to PreserveAspectRatio (see IntrinsicSize).
+// See SetTargetPreserveAspect to avoid distorting the icon.
 func (s *SvgIcon) SetTarget(x, y, w, h float64) {
-	scaleW := w / s.ViewBox.W
-	scaleH := h / s.ViewBox.H
-	s.Transform = Identity.Translate(x-s.ViewBox.X, y-s.ViewBox.Y).Scale(scaleW, scaleH)
+	s.Transform = s.targetMatrix(Bounds{X: x, Y: y, W: w, H: h})
+}
+
+// SetTargetPreserveAspect is like SetTarget, but uses the rectangle arguments
+// as viewport, so that the view box is uniformly scaled and aligned into it,
+// according to PreserveAspectRatio (unless its None field is true).
+// With the "slice" mode, the icon overflows the rectangle, and callers
+// should clip to it.
+func (s *SvgIcon) SetTargetPreserveAspect(x, y, w, h float64) {
+	target := Bounds{X: x, Y: y, W: w, H: h}
+	if s.ViewBox.W <= 0 || s.ViewBox.H <= 0 {
+		s.Transform = fitMatrix(s.ViewBox, target)
+		return
+	}
+	s.Transform = s.PreserveAspectRatio.fit(s.ViewBox, target)
+}
+
+// targetMatrix returns the matrix used by SetTarget
+func (s *SvgIcon) targetMatrix(target Bounds) Matrix2D {
+	vw, vh, ok := s.IntrinsicSize()
+	if !ok || vw*s.ViewBox.H == vh*s.ViewBox.W || s.ViewBox.W <= 0 || s.ViewBox.H <= 0 {
+		return fitMatrix(s.ViewBox, target)
+	}
+	viewport := Bounds{W: vw, H: vh}
+	return fitMatrix(viewport, target).Mult(s.PreserveAspectRatio.fit(s.ViewBox, viewport))
+}
+
+// IntrinsicSize returns the size of the icon, in pixels, as specified by the
+// width and height attributes of the root element, which define the viewport
+// of the icon. A missing or relative (percentage) dimension is deduced from
+// the other one and the view box aspect ratio, or defaults to the view box dimension.
+// `ok` is false if the size can't be determined.
+func (s *SvgIcon) IntrinsicSize() (width, height float64, ok bool) {
+	absolute := func(attr string) (float64, bool) {
+		if attr == "" {
+			return 0, false
+		}
+		v, isPercentage, err := parseUnit(attr)
+		return v, err == nil && !isPercentage && v > 0
+	}
+	width, hasWidth := absolute(s.Width)
+	height, hasHeight := absolute(s.Height)
+	vb := s.ViewBox
+	switch {
+	case hasWidth && hasHeight:
+	case hasWidth && vb.W > 0 && vb.H > 0:
+		height = width * vb.H / vb.W
+	case hasHeight && vb.W > 0 && vb.H > 0:
+		width = height * vb.W / vb.H
+	default:
+		if !hasWidth {
+			width = vb.W
+		}
+		if !hasHeight {
+			height = vb.H
+		}
+	}
+	return width, height, width > 0 && height > 0
+}
+
+// AspectRatio returns the intrinsic aspect ratio (width / height) of the icon,
+// as defined by IntrinsicSize, or 0 if it is not known.
+func (s *SvgIcon) AspectRatio() float64 {
+	w, h, ok := s.IntrinsicSize()
+	if !ok {
+		return 0
+	}
+	return w / h
+}
+
+// PathBounds returns the tight bounding box of the paths of the icon, with their
+// transform applied, expressed in the icon coordinates (the icon Transform is not applied).
+// Bezier curves are bounded by their extrema, not by their control points.
+// If `withStroke` is true, the box of the stroked paths is enlarged by the extent
+// of the stroke, that is half their line width for centered strokes
+// (the extra length of miter joins is ignored).
+// An icon without paths has empty bounds.
+func (s *SvgIcon) PathBounds(withStroke bool) Bounds {
+	box, _ := pathsBounds(s.SVGPaths, Identity, withStroke)
+	return box
+}
+
+// transform returns the bounding box of the
+// rectangle `b` transformed by `m`
+func (b Bounds) transform(m Matrix2D) Bounds {
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+	for _, corner := range [4][2]float64{{b.X, b.Y}, {b.X + b.W, b.Y}, {b.X, b.Y + b.H}, {b.X + b.W, b.Y + b.H}} {
+		x, y := m.Transform(corner[0], corner[1])
+		minX, maxX = math.Min(minX, x), math.Max(maxX, x)
+		minY, maxY = math.Min(minY, y), math.Max(maxY, y)
+	}
+	return Bounds{X: minX, Y: minY, W: maxX - minX, H: maxY - minY}
+}
+
+// fitMatrix returns the matrix mapping `viewBox` onto `target`
+func fitMatrix(viewBox, target Bounds) Matrix2D {
+	scaleW := target.W / viewBox.W
+	scaleH := target.H / viewBox.H
+	return Identity.Translate(target.X, target.Y).Scale(scaleW, scaleH).Translate(-viewBox.X, -viewBox.Y)
 }
 
 // Draw the compiled SVG icon into the driver `d`.
@@ -204,17 +479,193 @@ func (s *SvgIcon) SetTarget(x, y, w, h float64) {
 // All elements should be contained by the Bounds rectangle of the SvgIcon:
 // see `SetTarget` method.
 func (s *SvgIcon) Draw(d Driver, opacity float64) {
-	for _, svgp := range s.SVGPaths {
-		svgp.drawTransformed(d, opacity, s.Transform)
+	s.DrawWithOptions(d, opacity, DrawOptions{})
+}
+
+// DrawOptions provides optional settings for DrawWithOptions.
+type DrawOptions struct {
+	// OnPath, if not nil, is called after each path is drawn,
+	// with the index of the path and the total number of paths
+	// (len(SVGPaths)). It may be used to report the progress
+	// of long renderings.
+	OnPath func(index, total int)
+
+	// Clip, if not nil, restricts the drawing to the rectangle,
+	// expressed in the driver coordinates (that is, after applying
+	// the icon Transform matrix) : see DrawClipped.
+	Clip *Bounds
+}
+
+// DrawClipped is the same as Draw, but only draws inside the rectangle `clip`,
+// expressed in the driver coordinates (that is, after applying the icon Transform matrix).
+// The paths entirely outside `clip` are skipped, and, if `d` implements ClipDriver,
+// the other ones are clipped to `clip`.
+func (s *SvgIcon) DrawClipped(d Driver, clip Bounds, opacity float64) {
+	s.DrawWithOptions(d, opacity, DrawOptions{Clip: &clip})
+}
+
+// DrawWith is the same as Draw, but uses `transform`
+// instead of the icon Transform, which is not modified.
+func (s *SvgIcon) DrawWith(d Driver, transform Matrix2D, opacity float64) {
+	s.drawWith(d, opacity, transform, DrawOptions{})
+}
+
+// DrawScaled draws the icon within the bounds of the rectangle arguments,
+// as SetTarget followed by Draw would do, but without modifying the icon,
+// which may then be shared.
+func (s *SvgIcon) DrawScaled(d Driver, x, y, w, h float64, opacity float64) {
+	s.DrawWith(d, s.targetMatrix(Bounds{X: x, Y: y, W: w, H: h}), opacity)
+}
+
+// DrawWithOptions is the same as Draw, with additional options.
+func (s *SvgIcon) DrawWithOptions(d Driver, opacity float64, options DrawOptions) {
+	s.drawWith(d, opacity, s.Transform, options)
+}
+
+// DrawWithError is the same as Draw, but, if `d` implements ErrorDriver,
+// stops at the first error reported by the driver and returns it.
+func (s *SvgIcon) DrawWithError(d Driver, opacity float64) error {
+	return s.drawWith(d, opacity, s.Transform, DrawOptions{})
+}
+
+// drawWith draws the icon using the transform `t`, stopping
+// at the first error reported by `d`, if it implements ErrorDriver.
+func (s *SvgIcon) drawWith(d Driver, opacity float64, t Matrix2D, options DrawOptions) error {
+	if iconDriver, ok := d.(IconDriver); ok {
+		iconDriver.BeginIcon(s.ViewBox.transform(t))
+		defer iconDriver.EndIcon()
+	}
+	var clipPath Path
+	if options.Clip != nil {
+		clipPath.addRect(options.Clip.X, options.Clip.Y, options.Clip.X+options.Clip.W, options.Clip.Y+options.Clip.H, 0)
+	}
+	layerer, hasLayers := d.(LayerDriver)
+	errDriver, hasErr := d.(ErrorDriver)
+	texter, hasText := d.(TextDriver)
+	var layers []int // the isolated groups currently opened
+	for i, svgp := range s.SVGPaths {
+		if hasLayers {
+			layers = updateLayers(layerer, layers, svgp.Style.isolation)
+		}
+		if svgp.Text != nil && hasText {
+			svgp.Path = texter.TextPath(*svgp.Text)
+		}
+		switch {
+		case len(svgp.Path) == 0: // text not supported by the driver
+		case options.Clip == nil:
+			svgp.drawTransformed(d, opacity, t, nil)
+		case svgp.mayIntersect(*options.Clip, t):
+			svgp.drawTransformed(d, opacity, t, clipPath)
+		}
+		if hasErr {
+			if err := errDriver.Err(); err != nil {
+				if hasLayers {
+					updateLayers(layerer, layers, nil)
+				}
+				return err
+			}
+		}
+		if options.OnPath != nil {
+			options.OnPath(i, len(s.SVGPaths))
+		}
 	}
+	if hasLayers {
+		updateLayers(layerer, layers, nil)
+	}
+	return nil
+}
+
+// updateLayers closes and opens the layers required to go
+// from the isolated groups `current` to `target`, and returns `target`.
+func updateLayers(d LayerDriver, current, target []int) []int {
+	common := 0
+	for common < len(current) && common < len(target) && current[common] == target[common] {
+		common++
+	}
+	for range current[common:] {
+		d.EndLayer()
+	}
+	for range target[common:] {
+		d.BeginLayer()
+	}
+	return target
+}
+
+// mayIntersect returns false if the path, transformed by `t`,
+// is guaranteed to be outside `clip`.
+func (svgp *SvgPath) mayIntersect(clip Bounds, t Matrix2D) bool {
+	if svgp.Style.Filter != nil { // filters may paint outside the path
+		return true
+	}
+	m := t.Mult(svgp.Style.transform)
+	box, ok := svgp.Path.bounds(m)
+	if !ok {
+		return false
+	}
+	if svgp.Style.LinerColor != nil {
+		// enlarge the box to account for joins and caps
+		miter := math.Max(float64(svgp.Style.Join.MiterLimit)/64, math.Sqrt2)
+		margin := svgp.Style.strokeExtent() * miter * math.Sqrt(math.Abs(m.A*m.D-m.B*m.C))
+		box = Bounds{X: box.X - margin, Y: box.Y - margin, W: box.W + 2*margin, H: box.H + 2*margin}
+	}
+	return box.Intersects(clip)
+}
+
+// EffectiveStyle returns the style of the path, with the defaults
+// used when drawing resolved : the NilGap and NilCap values
+// are replaced by the DefaultStyle ones, and the lead line cap,
+// if not set, is the trail line cap.
+func (svgp SvgPath) EffectiveStyle() PathStyle {
+	style := svgp.Style
+	if style.Join.LineGap == NilGap {
+		style.Join.LineGap = DefaultStyle.Join.LineGap
+	}
+	if style.Join.TrailLineCap == NilCap {
+		style.Join.TrailLineCap = DefaultStyle.Join.TrailLineCap
+	}
+	if style.Join.LeadLineCap == NilCap {
+		style.Join.LeadLineCap = style.Join.TrailLineCap
+	}
+	return style
 }
 
 // drawTransformed draws the compiled SvgPath into the driver while applying transform t.
-func (svgp *SvgPath) drawTransformed(d Driver, opacity float64, t Matrix2D) {
+// If not nil, `extraClip` is an additional clip path, expressed in the driver coordinates.
+func (svgp *SvgPath) drawTransformed(d Driver, opacity float64, t Matrix2D, extraClip Path) {
 	m := svgp.Style.transform
 	svgp.Style.transform = t.Mult(m)
 	defer func() { svgp.Style.transform = m }() // Restore untransformed matrix
 
+	if clipper, ok := d.(ClipDriver); ok && (len(svgp.Style.clips) != 0 || extraClip != nil) {
+		// clips are already expressed in icon coordinates
+		clips := make([]Path, len(svgp.Style.clips), len(svgp.Style.clips)+1)
+		for i, clip := range svgp.Style.clips {
+			clips[i] = clip.transform(t)
+		}
+		if extraClip != nil {
+			clips = append(clips, extraClip)
+		}
+		clipper.SetClip(clips)
+		defer clipper.SetClip(nil)
+	}
+
+	if blender, ok := d.(BlendDriver); ok && svgp.Style.BlendMode != NormalBlend {
+		blender.SetBlendMode(svgp.Style.BlendMode)
+		defer blender.SetBlendMode(NormalBlend)
+	}
+
+	if filterer, ok := d.(FilterDriver); ok && svgp.Style.Filter != nil {
+		filterer.BeginFilter(svgp.Style.Filter.transform(svgp.Style.transform))
+		defer filterer.EndFilter()
+	}
+
+	if svgp.Image != nil { // the path is only the extent of the image
+		if imager, ok := d.(ImageDriver); ok {
+			imager.DrawImage(*svgp.Image, svgp.Style.transform, svgp.Style.opacity*opacity)
+		}
+		return
+	}
+
 	filler, stroker := d.SetupDrawers(svgp.Style.FillerColor != nil, svgp.Style.LinerColor != nil)
 	if filler != nil { // nil color disable filling
 		filler.Clear()
@@ -232,28 +683,14 @@ func (svgp *SvgPath) drawTransformed(d Driver, opacity float64, t Matrix2D) {
 	if stroker != nil { // nil color disable lining
 		stroker.Clear()
 
-		lineGap := svgp.Style.Join.LineGap
-		if lineGap == NilGap {
-			lineGap = DefaultStyle.Join.LineGap
-		}
-		lineCap := svgp.Style.Join.TrailLineCap
-		if lineCap == NilCap {
-			lineCap = DefaultStyle.Join.TrailLineCap
-		}
-		leadLineCap := lineCap
-		if svgp.Style.Join.LeadLineCap != NilCap {
-			leadLineCap = svgp.Style.Join.LeadLineCap
-		}
+		// the path is transformed before stroking, so that the width
+		// is scaled by the (mean) scaling factor of the transform, as the dashes
+		m := svgp.Style.transform
 		stroker.SetStrokeOptions(StrokeOptions{
-			LineWidth: fixed.Int26_6(svgp.Style.LineWidth * 64),
-			Join: JoinOptions{
-				MiterLimit:   svgp.Style.Join.MiterLimit,
-				LineJoin:     svgp.Style.Join.LineJoin,
-				LeadLineCap:  leadLineCap,
-				TrailLineCap: lineCap,
-				LineGap:      lineGap,
-			},
-			Dash: svgp.Style.Dash,
+			LineWidth: fToFixed(svgp.Style.LineWidth * math.Sqrt(math.Abs(m.A*m.D-m.B*m.C))),
+			Join:      svgp.EffectiveStyle().Join,
+			Dash:      svgp.Style.Dash.transform(svgp.Style.transform),
+			Alignment: svgp.Style.StrokeAlignment,
 		})
 
 		for _, op := range svgp.Path {
@@ -0,0 +1,556 @@
+package svgicon
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+
+	"golang.org/x/image/math/fixed"
+)
+
+// recorder is a Driver storing the
+// operations it receives
+type recorder struct {
+	clips       [][]Path // one for each path
+	fills       []Path
+	strokes     []StrokeOptions
+	strokePaths []Path
+	windings    []bool // non-zero winding, for each fill
+}
+
+type recorderDrawer struct {
+	rec     *recorder
+	path    Path
+	isFill  bool
+	nonZero bool
+}
+
+func (r *recorder) SetupDrawers(willFill, willStroke bool) (Filler, Stroker) {
+	var (
+		f Filler
+		s Stroker
+	)
+	if willFill {
+		f = &recorderDrawer{rec: r, isFill: true}
+	}
+	if willStroke {
+		s = &recorderDrawer{rec: r}
+	}
+	return f, s
+}
+
+func (r *recorder) SetClip(clips []Path) {
+	if len(clips) != 0 {
+		r.clips = append(r.clips, clips)
+	}
+}
+
+func (d *recorderDrawer) Clear()                             { d.path = d.path[:0] }
+func (d *recorderDrawer) Start(a fixed.Point26_6)            { d.path.Start(a) }
+func (d *recorderDrawer) Line(b fixed.Point26_6)             { d.path.Line(b) }
+func (d *recorderDrawer) QuadBezier(b, c fixed.Point26_6)    { d.path.QuadBezier(b, c) }
+func (d *recorderDrawer) CubeBezier(b, c, e fixed.Point26_6) { d.path.CubeBezier(b, c, e) }
+func (d *recorderDrawer) Stop(closeLoop bool)                { d.path.Stop(closeLoop) }
+func (d *recorderDrawer) SetWinding(nonZero bool)            { d.nonZero = nonZero }
+func (d *recorderDrawer) SetStrokeOptions(o StrokeOptions)   { d.rec.strokes = append(d.rec.strokes, o) }
+func (d *recorderDrawer) Draw(color Pattern, opacity float64) {
+	if d.isFill {
+		d.rec.fills = append(d.rec.fills, append(Path(nil), d.path...))
+		d.rec.windings = append(d.rec.windings, d.nonZero)
+	} else {
+		d.rec.strokePaths = append(d.rec.strokePaths, append(Path(nil), d.path...))
+	}
+}
+
+func TestSymbolClip(t *testing.T) {
+	const src = `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 100 100">
+	<symbol id="s" viewBox="0 0 10 10" %s>
+		<rect x="-5" y="-5" width="20" height="20"/>
+	</symbol>
+	<use href="#s" x="10" y="20" width="30" height="30"/>
+	</svg>`
+
+	icon, err := ReadIconStream(strings.NewReader(strings.Replace(src, "%s", "", 1)), StrictErrorMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(icon.SVGPaths) != 1 {
+		t.Fatalf("expected one path, got %d", len(icon.SVGPaths))
+	}
+	var rec recorder
+	icon.Draw(&rec, 1)
+	if len(rec.clips) != 1 || len(rec.clips[0]) != 1 {
+		t.Fatalf("expected one clip, got %v", rec.clips)
+	}
+	if got, exp := rec.clips[0][0].ToSVGPath(), "M10.000,20.000 L40.000,20.000 L40.000,50.000 L10.000,50.000 Z"; got != exp {
+		t.Errorf("expected clip %s, got %s", exp, got)
+	}
+	// the rect is scaled by 3 and translated
+	if got, exp := rec.fills[0].ToSVGPath(), "M-5.000,5.000 L55.000,5.000 L55.000,65.000 L-5.000,65.000 Z"; got != exp {
+		t.Errorf("expected path %s, got %s", exp, got)
+	}
+
+	icon, err = ReadIconStream(strings.NewReader(strings.Replace(src, "%s", `style="overflow:visible"`, 1)), StrictErrorMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec = recorder{}
+	icon.Draw(&rec, 1)
+	if len(rec.clips) != 0 {
+		t.Errorf("unexpected clip %v", rec.clips)
+	}
+}
+
+func TestNestedSvgClip(t *testing.T) {
+	const src = `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 100 100">
+	<svg x="50" y="50" width="20" height="20">
+		<rect width="40" height="40"/>
+	</svg>
+	<rect width="10" height="10"/>
+	</svg>`
+	icon, err := ReadIconStream(strings.NewReader(src), StrictErrorMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if icon.ViewBox != (Bounds{W: 100, H: 100}) {
+		t.Fatalf("nested svg should not change the view box: %v", icon.ViewBox)
+	}
+	var rec recorder
+	icon.Draw(&rec, 1)
+	if len(rec.clips) != 1 {
+		t.Fatalf("expected only the nested rect to be clipped, got %v", rec.clips)
+	}
+	if got, exp := rec.clips[0][0].ToSVGPath(), "M50.000,50.000 L70.000,50.000 L70.000,70.000 L50.000,70.000 Z"; got != exp {
+		t.Errorf("expected clip %s, got %s", exp, got)
+	}
+}
+
+func TestScaledDash(t *testing.T) {
+	const src = `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 50 50">
+	<line x1="5" y1="25" x2="45" y2="25" stroke="black" stroke-dasharray="5 3" stroke-dashoffset="2"/>
+	</svg>`
+	icon, err := ReadIconStream(strings.NewReader(src), StrictErrorMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	icon.SetTarget(0, 0, 100, 100)
+	var rec recorder
+	icon.Draw(&rec, 1)
+	if len(rec.strokes) != 1 {
+		t.Fatalf("expected one stroke, got %d", len(rec.strokes))
+	}
+	dash := rec.strokes[0].Dash
+	if len(dash.Dash) != 2 || dash.Dash[0] != 10 || dash.Dash[1] != 6 || dash.DashOffset != 4 {
+		t.Errorf("unexpected dash options %v", dash)
+	}
+	// the default width is 2
+	if w := rec.strokes[0].LineWidth; w != 4*64 {
+		t.Errorf("expected a scaled line width, got %v", w)
+	}
+	if d := icon.SVGPaths[0].Style.Dash.Dash; d[0] != 5 {
+		t.Errorf("drawing should not modify the style, got %v", d)
+	}
+}
+
+func TestStrokeOpenPath(t *testing.T) {
+	const src = `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 100 100">
+	<polyline points="10,10 10,50 50,50" fill="red" stroke="blue"/>
+	<path d="M10,10 L10,50 L50,50 Z" fill="red" stroke="blue"/>
+	</svg>`
+	icon, err := ReadIconStream(strings.NewReader(src), StrictErrorMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rec recorder
+	icon.Draw(&rec, 1)
+	if len(rec.fills) != 2 || len(rec.strokePaths) != 2 {
+		t.Fatalf("unexpected number of paths: %d fills, %d strokes", len(rec.fills), len(rec.strokePaths))
+	}
+	// the open L-shape is not closed, neither for filling (closing is implicit)
+	// nor for stroking, where it would add a segment
+	const open = "M10.000,10.000 L10.000,50.000 L50.000,50.000"
+	if got := rec.fills[0].ToSVGPath(); got != open {
+		t.Errorf("expected fill path %s, got %s", open, got)
+	}
+	if got := rec.strokePaths[0].ToSVGPath(); got != open {
+		t.Errorf("expected stroke path %s, got %s", open, got)
+	}
+	if got := rec.strokePaths[1].ToSVGPath(); got != open+" Z" {
+		t.Errorf("expected closed stroke path, got %s", got)
+	}
+}
+
+func TestSetTargetOrigin(t *testing.T) {
+	icon := &SvgIcon{ViewBox: Bounds{X: 10, Y: 20, W: 10, H: 10}}
+	icon.SetTarget(100, 100, 50, 20)
+	if x, y := icon.Transform.Transform(10, 20); x != 100 || y != 100 {
+		t.Errorf("unexpected origin (%g, %g)", x, y)
+	}
+	if x, y := icon.Transform.Transform(20, 30); x != 150 || y != 120 {
+		t.Errorf("unexpected corner (%g, %g)", x, y)
+	}
+}
+
+func TestMerge(t *testing.T) {
+	const (
+		base = `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 100 100">
+		<linearGradient id="g"><stop offset="0" stop-color="red"/></linearGradient>
+		<rect width="100" height="100" fill="url(#g)"/>
+		</svg>`
+		badge = `<svg xmlns="http://www.w3.org/2000/svg" viewBox="10 10 20 20">
+		<linearGradient id="g"><stop offset="0" stop-color="blue"/></linearGradient>
+		<rect x="10" y="10" width="20" height="20" fill="url(#g)"/>
+		</svg>`
+	)
+	icon, err := ReadIconStream(strings.NewReader(base), StrictErrorMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := ReadIconStream(strings.NewReader(badge), StrictErrorMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	icon.Merge(other, Bounds{X: 60, Y: 60, W: 40, H: 40})
+
+	if len(icon.SVGPaths) != 2 || len(other.SVGPaths) != 1 {
+		t.Fatalf("unexpected number of paths %d", len(icon.SVGPaths))
+	}
+	if _, ok := icon.grads["g-1"]; !ok || len(icon.grads) != 2 {
+		t.Errorf("expected the colliding gradient to be renamed, got %v", icon.grads)
+	}
+	var rec recorder
+	icon.Draw(&rec, 1)
+	if got, exp := rec.fills[1].ToSVGPath(), "M60.000,60.000 L100.000,60.000 L100.000,100.000 L60.000,100.000 Z"; got != exp {
+		t.Errorf("expected merged path %s, got %s", exp, got)
+	}
+	// other is not modified
+	if m := other.SVGPaths[0].Style.transform; m != Identity {
+		t.Errorf("unexpected transform %v", m)
+	}
+}
+
+func TestMergeRenamedReferences(t *testing.T) {
+	const src = `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 10 10">
+	<defs>
+		<linearGradient id="g"><stop offset="0" stop-color="%[1]s"/></linearGradient>
+		<rect id="shape" width="5" height="5" style="fill: url(#g)"/>
+		<g id="group-%[1]s"><use href="#shape"/><rect width="1" height="1" fill="url('#g')"/></g>
+	</defs>
+	<use xlink:href="#shape" xmlns:xlink="http://www.w3.org/1999/xlink"/>
+	</svg>`
+	icon, err := ReadIconStream(strings.NewReader(fmt.Sprintf(src, "red")), StrictErrorMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := ReadIconStream(strings.NewReader(fmt.Sprintf(src, "blue")), StrictErrorMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	icon.Merge(other, icon.ViewBox)
+
+	// the copied definitions refer to the renamed elements
+	var refs []string
+	for _, elem := range icon.defs["group-blue"] {
+		for _, attr := range elem.Attrs {
+			if attr.Name.Local == "href" || attr.Name.Local == "fill" {
+				refs = append(refs, attr.Value)
+			}
+		}
+	}
+	if exp := []string{"#shape-1", "url(#g-1)"}; fmt.Sprint(refs) != fmt.Sprint(exp) {
+		t.Errorf("expected references %v, got %v", exp, refs)
+	}
+	if def := icon.defs["shape-1"]; len(def) == 0 || def[0].ID != "shape-1" || def[0].Attrs[len(def[0].Attrs)-1].Value != "fill: url(#g-1)" {
+		t.Errorf("unexpected renamed definition %v", def)
+	}
+	if ids := [2]string{icon.SVGPaths[0].ID, icon.SVGPaths[1].ID}; ids != [2]string{"shape", "shape-1"} {
+		t.Errorf("unexpected path IDs %v", ids)
+	}
+	// other is not modified
+	if ref := other.defs["group-blue"][1].Attrs[0].Value; ref != "#shape" {
+		t.Errorf("unexpected reference %s", ref)
+	}
+}
+
+// callsRecorder records the calls to the
+// IconDriver, LayerDriver and Driver methods
+type callsRecorder struct {
+	recorder
+	calls   []string
+	viewBox Bounds
+}
+
+func (r *callsRecorder) BeginIcon(viewBox Bounds) {
+	r.calls = append(r.calls, "begin")
+	r.viewBox = viewBox
+}
+
+func (r *callsRecorder) EndIcon() { r.calls = append(r.calls, "end") }
+
+func (r *callsRecorder) BeginLayer() { r.calls = append(r.calls, "(") }
+
+func (r *callsRecorder) EndLayer() { r.calls = append(r.calls, ")") }
+
+func (r *callsRecorder) SetupDrawers(willFill, willStroke bool) (Filler, Stroker) {
+	r.calls = append(r.calls, "path")
+	return r.recorder.SetupDrawers(willFill, willStroke)
+}
+
+func TestIconDriver(t *testing.T) {
+	const src = `<svg xmlns="http://www.w3.org/2000/svg" viewBox="10 10 20 10">
+	<rect width="5" height="5"/><rect width="5" height="5" stroke="red"/>
+	</svg>`
+	icon, err := ReadIconStream(strings.NewReader(src), StrictErrorMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	icon.SetTarget(0, 0, 40, 20)
+	var rec callsRecorder
+	icon.Draw(&rec, 1)
+	if got := strings.Join(rec.calls, " "); got != "begin path path end" {
+		t.Errorf("unexpected calls %s", got)
+	}
+	if rec.viewBox != (Bounds{X: 0, Y: 0, W: 40, H: 20}) {
+		t.Errorf("unexpected transformed view box %v", rec.viewBox)
+	}
+}
+
+func TestIsolatedGroups(t *testing.T) {
+	const src = `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 10 10">
+	<rect width="5" height="5"/>
+	<g style="isolation: isolate">
+		<rect width="5" height="5"/>
+		<g isolation="isolate"><rect width="5" height="5"/></g>
+		<g enable-background="new"><rect width="5" height="5"/><rect width="5" height="5"/></g>
+	</g>
+	<g isolation="isolate"><rect width="5" height="5"/></g>
+	<g isolation="auto"><rect width="5" height="5"/></g>
+	</svg>`
+	icon, err := ReadIconStream(strings.NewReader(src), StrictErrorMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var rec callsRecorder
+	icon.Draw(&rec, 1)
+	if got, exp := strings.Join(rec.calls, " "), "begin path ( path ( path ) ( path path ) ) ( path ) path end"; got != exp {
+		t.Errorf("expected calls %s, got %s", exp, got)
+	}
+}
+
+func TestDrawOnPath(t *testing.T) {
+	const src = `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 10 10">
+	<rect width="5" height="5"/><circle r="2"/><path d="M0 0 L 5 5" stroke="red"/>
+	</svg>`
+	icon, err := ReadIconStream(strings.NewReader(src), StrictErrorMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var indices []int
+	icon.DrawWithOptions(&recorder{}, 1, DrawOptions{OnPath: func(index, total int) {
+		if total != len(icon.SVGPaths) {
+			t.Errorf("unexpected total %d", total)
+		}
+		indices = append(indices, index)
+	}})
+	if len(indices) != 3 || indices[0] != 0 || indices[2] != 2 {
+		t.Errorf("unexpected callback calls %v", indices)
+	}
+}
+
+func TestDrawClipped(t *testing.T) {
+	const src = `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 100 100">
+	<rect width="10" height="10"/>
+	<rect x="80" y="80" width="10" height="10"/>
+	<path d="M32 0 V 40" fill="none" stroke="red" stroke-width="10"/>
+	</svg>`
+	icon, err := ReadIconStream(strings.NewReader(src), StrictErrorMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	icon.SetTarget(0, 0, 200, 200)
+	var rec recorder
+	icon.DrawClipped(&rec, Bounds{X: 0, Y: 0, W: 60, H: 60}, 1)
+	// the second rect is outside the clip, and the stroke
+	// of the line overlaps it
+	if len(rec.fills) != 1 || len(rec.strokePaths) != 1 {
+		t.Fatalf("expected one fill and one stroke, got %d and %d", len(rec.fills), len(rec.strokePaths))
+	}
+	if len(rec.clips) != 2 {
+		t.Fatalf("expected 2 clips, got %d", len(rec.clips))
+	}
+	var exp Path
+	exp.addRect(0, 0, 60, 60, 0)
+	if !rec.clips[0][0].Equal(exp) {
+		t.Errorf("unexpected clip %v", rec.clips[0])
+	}
+}
+
+func TestDrawScaled(t *testing.T) {
+	const src = `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 10 10">
+	<rect width="5" height="5"/>
+	</svg>`
+	icon, err := ReadIconStream(strings.NewReader(src), StrictErrorMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	before := icon.Transform
+	var rec recorder
+	icon.DrawScaled(&rec, 10, 10, 100, 100, 1)
+	if icon.Transform != before {
+		t.Errorf("Transform should not be modified, got %v", icon.Transform)
+	}
+
+	// same result as SetTarget + Draw
+	var exp recorder
+	icon.SetTarget(10, 10, 100, 100)
+	icon.Draw(&exp, 1)
+	if len(rec.fills) != 1 || !rec.fills[0].Equal(exp.fills[0]) {
+		t.Errorf("expected %v, got %v", exp.fills, rec.fills)
+	}
+}
+
+// failingRecorder reports an error once `failAfter` paths are drawn
+type failingRecorder struct {
+	callsRecorder
+	failAfter int
+	err       error
+}
+
+func (r *failingRecorder) SetupDrawers(willFill, willStroke bool) (Filler, Stroker) {
+	if r.failAfter--; r.failAfter == 0 {
+		r.err = errors.New("driver failure")
+	}
+	return r.callsRecorder.SetupDrawers(willFill, willStroke)
+}
+
+func (r *failingRecorder) Err() error { return r.err }
+
+func TestDrawWithError(t *testing.T) {
+	const src = `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 10 10">
+	<rect width="5" height="5"/>
+	<g isolation="isolate"><rect width="5" height="5"/><rect width="5" height="5"/></g>
+	<rect width="5" height="5"/>
+	</svg>`
+	icon, err := ReadIconStream(strings.NewReader(src), StrictErrorMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rec := failingRecorder{failAfter: 2}
+	err = icon.DrawWithError(&rec, 1)
+	if err == nil || err.Error() != "driver failure" {
+		t.Fatalf("expected driver failure, got %v", err)
+	}
+	// drawing stops after the failing path, closing the opened layer
+	if got, exp := strings.Join(rec.calls, " "), "begin path ( path ) end"; got != exp {
+		t.Errorf("expected calls %s, got %s", exp, got)
+	}
+
+	rec = failingRecorder{failAfter: 10}
+	if err = icon.DrawWithError(&rec, 1); err != nil {
+		t.Fatal(err)
+	}
+	if got, exp := strings.Join(rec.calls, " "), "begin path ( path path ) path end"; got != exp {
+		t.Errorf("expected calls %s, got %s", exp, got)
+	}
+}
+
+func TestPathBounds(t *testing.T) {
+	const src = `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 100 100">
+	<circle cx="10" cy="10" r="5" stroke="red" stroke-width="2"/>
+	<g transform="translate(20 0) scale(2)"><rect x="10" y="10" width="5" height="5"/></g>
+	</svg>`
+	icon, err := ReadIconStream(strings.NewReader(src), StrictErrorMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the control point of the curve lies outside the curve
+	quad := Path{OpMoveTo{}, OpQuadTo{fixed.P(5, 10), fixed.P(10, 0)}}
+	controls, _ := quad.bounds(Identity)
+	if tight, _ := quad.tightBounds(Identity); tight.H != 5 || controls.H != 10 {
+		t.Fatalf("expected tight bounds %v smaller than %v", tight, controls)
+	}
+
+	boundsAlmostEqual := func(b1, b2 Bounds) bool {
+		const tol = 1. / 32
+		return math.Abs(b1.X-b2.X) < tol && math.Abs(b1.Y-b2.Y) < tol &&
+			math.Abs(b1.W-b2.W) < tol && math.Abs(b1.H-b2.H) < tol
+	}
+	if b, exp := icon.PathBounds(false), (Bounds{X: 5, Y: 5, W: 45, H: 25}); !boundsAlmostEqual(b, exp) {
+		t.Errorf("expected %v, got %v", exp, b)
+	}
+	if b, exp := icon.PathBounds(true), (Bounds{X: 4, Y: 4, W: 46, H: 26}); !boundsAlmostEqual(b, exp) {
+		t.Errorf("expected %v, got %v", exp, b)
+	}
+	if b := (&SvgIcon{}).PathBounds(true); b != (Bounds{}) {
+		t.Errorf("expected empty bounds, got %v", b)
+	}
+}
+
+func TestEffectiveStyle(t *testing.T) {
+	const src = `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 10 10">
+	<path d="M0 0 L 5 5" stroke="red"/>
+	<path d="M0 0 L 5 5" stroke="red" stroke-linecap="round" stroke-linejoin="miter"/>
+	</svg>`
+	icon, err := ReadIconStream(strings.NewReader(src), StrictErrorMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// styles built by hand may rely on the defaults
+	style := DefaultStyle
+	style.LinerColor = NewPlainColor(0, 0, 0, 0xff)
+	style.Join.TrailLineCap, style.Join.LeadLineCap, style.Join.LineGap = NilCap, NilCap, NilGap
+	icon.SVGPaths = append(icon.SVGPaths, SvgPath{Path: icon.SVGPaths[0].Path, Style: style})
+	style.Join.TrailLineCap = SquareCap
+	icon.SVGPaths = append(icon.SVGPaths, SvgPath{Path: icon.SVGPaths[0].Path, Style: style})
+
+	var rec recorder
+	icon.Draw(&rec, 1)
+	if len(rec.strokes) != len(icon.SVGPaths) {
+		t.Fatalf("expected %d strokes, got %d", len(icon.SVGPaths), len(rec.strokes))
+	}
+	for i, svgp := range icon.SVGPaths {
+		effective := svgp.EffectiveStyle()
+		if effective.Join != rec.strokes[i].Join {
+			t.Errorf("path %d: expected %v, got %v", i, rec.strokes[i].Join, effective.Join)
+		}
+		if effective.Join.TrailLineCap == NilCap || effective.Join.LeadLineCap == NilCap {
+			t.Errorf("path %d: unresolved style %v", i, effective.Join)
+		}
+	}
+	if lead := icon.SVGPaths[3].EffectiveStyle().Join.LeadLineCap; lead != SquareCap {
+		t.Errorf("expected the lead cap to default to the trail cap, got %s", lead)
+	}
+}
+
+func TestStrokeAlignment(t *testing.T) {
+	icon, err := ReadIconStream(strings.NewReader(`<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 100 100">
+	<g stroke="red" stroke-width="10">
+		<rect x="10" y="10" width="20" height="20"/>
+		<rect x="10" y="10" width="20" height="20" stroke-alignment="inner"/>
+		<rect x="10" y="10" width="20" height="20" style="stroke-alignment: outer"/>
+	</g>
+	</svg>`), StrictErrorMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rec recorder
+	icon.Draw(&rec, 1)
+	for i, exp := range []StrokeAlignment{CenterAlignment, InnerAlignment, OuterAlignment} {
+		if got := rec.strokes[i].Alignment; got != exp {
+			t.Errorf("path %d: expected alignment %d, got %d", i, exp, got)
+		}
+		bounds, _ := pathsBounds(icon.SVGPaths[i:i+1], Identity, true)
+		margin := [...]float64{5, 0, 10}[i]
+		if exp := (Bounds{10 - margin, 10 - margin, 20 + 2*margin, 20 + 2*margin}); bounds != exp {
+			t.Errorf("path %d: expected stroke bounds %v, got %v", i, exp, bounds)
+		}
+	}
+
+	if _, err = ReadIconStream(strings.NewReader(`<svg viewBox="0 0 10 10"><rect width="5" height="5" stroke-alignment="inside"/></svg>`), StrictErrorMode); err == nil {
+		t.Error("expected an error for an invalid stroke-alignment")
+	}
+}
@@ -0,0 +1,130 @@
+package svgicon
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// this file implements a human readable dump of a parsed icon, for debugging purposes
+
+// String returns a dump of the parsed icon : see Dump.
+func (s *SvgIcon) String() string {
+	var b strings.Builder
+	_ = s.Dump(&b)
+	return b.String()
+}
+
+// Dump writes a human readable description of the parsed icon to `w`: its view box,
+// its gradients, and for each path, a summary of its style and its data.
+// It is useful for debugging and bug reports.
+func (s *SvgIcon) Dump(w io.Writer) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "viewBox: %g %g %g %g", s.ViewBox.X, s.ViewBox.Y, s.ViewBox.W, s.ViewBox.H)
+	if s.Width != "" || s.Height != "" {
+		fmt.Fprintf(&b, " (width: %q, height: %q)", s.Width, s.Height)
+	}
+	b.WriteByte('\n')
+	if s.Transform != Identity {
+		fmt.Fprintf(&b, "transform: %s\n", s.Transform.dumpString())
+	}
+
+	gradIDs := make([]string, 0, len(s.grads))
+	for id := range s.grads {
+		gradIDs = append(gradIDs, id)
+	}
+	sort.Strings(gradIDs)
+	for _, id := range gradIDs {
+		fmt.Fprintf(&b, "gradient #%s: %s\n", id, s.grads[id].dumpString())
+	}
+
+	fmt.Fprintf(&b, "%d path(s)\n", len(s.SVGPaths))
+	for i, path := range s.SVGPaths {
+		st := path.Style
+		fmt.Fprintf(&b, "  %d: fill: %s (opacity %g), stroke: %s (opacity %g, width %g)",
+			i, dumpPattern(st.FillerColor), st.FillOpacity, dumpPattern(st.LinerColor), st.LineOpacity, st.LineWidth)
+		if st.transform != Identity {
+			fmt.Fprintf(&b, ", transform: %s", st.transform.dumpString())
+		}
+		if len(st.Dash.Dash) != 0 {
+			fmt.Fprintf(&b, ", dash: %v (offset %g)", st.Dash.Dash, st.Dash.DashOffset)
+		}
+		if st.BlendMode != NormalBlend {
+			fmt.Fprintf(&b, ", blend: %s", st.BlendMode)
+		}
+		if st.Filter != nil {
+			fmt.Fprintf(&b, ", filter: %d primitive(s)", len(st.Filter.Primitives))
+		}
+		if len(st.clips) != 0 {
+			fmt.Fprintf(&b, ", clips: %d", len(st.clips))
+		}
+		if path.Link != "" {
+			fmt.Fprintf(&b, ", link: %s", path.Link)
+		}
+		if img := path.Image; img != nil {
+			size := img.Image.Bounds().Size()
+			fmt.Fprintf(&b, "\n     image %dx%d at (%g, %g, %g, %g)\n", size.X, size.Y, img.Bounds.X, img.Bounds.Y, img.Bounds.W, img.Bounds.H)
+		} else if text := path.Text; text != nil {
+			fmt.Fprintf(&b, "\n     text %q at (%g, %g), font-size: %g\n", text.Content, text.X, text.Y, text.FontSize)
+		} else {
+			fmt.Fprintf(&b, "\n     %s\n", path.Path.ToSVGPath())
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func (m Matrix2D) dumpString() string {
+	return fmt.Sprintf("matrix(%g %g %g %g %g %g)", m.A, m.B, m.C, m.D, m.E, m.F)
+}
+
+// dumpPattern returns a short description of `p`
+func dumpPattern(p Pattern) string {
+	switch p := p.(type) {
+	case PlainColor:
+		return fmt.Sprintf("#%02x%02x%02x%02x", p.R, p.G, p.B, p.A)
+	case Gradient:
+		if _, isRadial := p.Direction.(Radial); isRadial {
+			return "radial gradient"
+		}
+		return "linear gradient"
+	default:
+		return "none"
+	}
+}
+
+func (g *Gradient) dumpString() string {
+	var b strings.Builder
+	switch dir := g.Direction.(type) {
+	case Linear:
+		fmt.Fprintf(&b, "linear %v", [4]float64(dir))
+	case Radial:
+		fmt.Fprintf(&b, "radial %v", [6]float64(dir))
+	}
+	units := "objectBoundingBox"
+	if g.Units == UserSpaceOnUse {
+		units = "userSpaceOnUse"
+	}
+	spread := [...]string{PadSpread: "pad", ReflectSpread: "reflect", RepeatSpread: "repeat"}
+	fmt.Fprintf(&b, ", units: %s", units)
+	if int(g.Spread) < len(spread) {
+		fmt.Fprintf(&b, ", spread: %s", spread[g.Spread])
+	}
+	if g.Matrix != Identity {
+		fmt.Fprintf(&b, ", transform: %s", g.Matrix.dumpString())
+	}
+	b.WriteString(", stops:")
+	for _, stop := range g.Stops {
+		color := "none"
+		if stop.StopColor != nil {
+			r, gr, bl, a := stop.StopColor.RGBA()
+			color = fmt.Sprintf("#%02x%02x%02x%02x", r>>8, gr>>8, bl>>8, a>>8)
+		}
+		fmt.Fprintf(&b, " %g:%s", stop.Offset, color)
+		if stop.Opacity != 1 {
+			fmt.Fprintf(&b, "(opacity %g)", stop.Opacity)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,42 @@
+package svgicon
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+)
+
+// The errors returned when parsing an icon are one of:
+//   - an error wrapping the one returned by the input (file or reader)
+//   - an XMLError, for malformed XML input
+//   - a ParseError, for SVG content which can't be processed.
+// Use errors.As to distinguish them.
+
+// XMLError is returned when the input is not a well-formed XML document.
+type XMLError struct {
+	Err error // as returned by encoding/xml
+}
+
+func (e XMLError) Error() string { return "invalid XML: " + e.Err.Error() }
+
+func (e XMLError) Unwrap() error { return e.Err }
+
+// ParseError is returned when an SVG element can't be processed.
+type ParseError struct {
+	Element string // name of the element, such as "path"
+	Err     error
+}
+
+func (e ParseError) Error() string { return fmt.Sprintf("invalid <%s> element: %s", e.Element, e.Err) }
+
+func (e ParseError) Unwrap() error { return e.Err }
+
+// wrapDecoderError distinguishes malformed XML from
+// errors returned by the underlying reader
+func wrapDecoderError(err error) error {
+	var syntaxErr *xml.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		return XMLError{Err: err}
+	}
+	return fmt.Errorf("reading icon: %w", err)
+}
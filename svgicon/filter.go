@@ -0,0 +1,221 @@
+package svgicon
+
+import (
+	"encoding/xml"
+	"math"
+	"strings"
+)
+
+// This file implements a restricted subset of the SVG filters,
+// which is enough to support the common drop shadows.
+
+// Filter is a chain of filter primitives, applied to the
+// painting of a path. The output of the filter is the
+// output of the last primitive.
+type Filter struct {
+	Primitives []FilterPrimitive
+}
+
+// FilterPrimitive is one of FeGaussianBlur, FeOffset or FeMerge.
+// The inputs of a primitive are either "SourceGraphic", "SourceAlpha",
+// the result of a previous primitive, or an empty string,
+// meaning the output of the previous primitive (or SourceGraphic
+// for the first primitive).
+type FilterPrimitive interface {
+	isFilterPrimitive()
+}
+
+// FeGaussianBlur blurs its input.
+type FeGaussianBlur struct {
+	In, Result                   string
+	StdDeviationX, StdDeviationY float64
+}
+
+// FeOffset translates its input.
+type FeOffset struct {
+	In, Result string
+	Dx, Dy     float64
+}
+
+// FeMerge composites its inputs, the first one being
+// at the bottom.
+type FeMerge struct {
+	Result string
+	Nodes  []string // inputs
+}
+
+func (FeGaussianBlur) isFilterPrimitive() {}
+func (FeOffset) isFilterPrimitive()       {}
+func (FeMerge) isFilterPrimitive()        {}
+
+// FilterDriver is an optional interface which may be implemented
+// by drivers supporting filters. For other drivers, filters are ignored.
+type FilterDriver interface {
+	Driver
+
+	// BeginFilter is called before drawing a path with a filter,
+	// whose lengths are expressed in the driver coordinates.
+	BeginFilter(filter Filter)
+
+	// EndFilter is called once the path has been filled and stroked,
+	// and should apply the filter and composite its result.
+	EndFilter()
+}
+
+// transform returns the filter with lengths transformed by `m`
+func (f Filter) transform(m Matrix2D) Filter {
+	// scaling factors along the axis
+	sx, sy := math.Hypot(m.A, m.B), math.Hypot(m.C, m.D)
+	out := Filter{Primitives: make([]FilterPrimitive, len(f.Primitives))}
+	for i, pr := range f.Primitives {
+		switch pr := pr.(type) {
+		case FeGaussianBlur:
+			pr.StdDeviationX *= sx
+			pr.StdDeviationY *= sy
+			out.Primitives[i] = pr
+		case FeOffset:
+			pr.Dx, pr.Dy = m.TransformVector(pr.Dx, pr.Dy)
+			out.Primitives[i] = pr
+		default:
+			out.Primitives[i] = pr
+		}
+	}
+	return out
+}
+
+// readFilterURL resolves the filter property
+func (c *iconCursor) readFilterURL(v string) (*Filter, error) {
+	if v == "none" {
+		return nil, nil
+	}
+	if strings.HasPrefix(v, "url(") && strings.HasSuffix(v, ")") {
+		urlStr := strings.Trim(strings.TrimSpace(v[4:len(v)-1]), `"'`)
+		// a missing or unsupported filter is ignored
+		return c.icon.filters[strings.TrimPrefix(urlStr, "#")], nil
+	}
+	return nil, c.handleError("unsupported value '%s' for <filter>", v)
+}
+
+// resolveFilterRefs resolves the filters used before their
+// definition, once the whole file is parsed.
+// As for the other filters, a missing or unsupported one is ignored.
+func (c *iconCursor) resolveFilterRefs() {
+	for i := range c.icon.SVGPaths {
+		style := &c.icon.SVGPaths[i].Style
+		if style.filterRef == "" {
+			continue
+		}
+		style.Filter = c.icon.filters[style.filterRef]
+		style.filterRef = ""
+	}
+}
+
+func filterF(c *iconCursor, attrs []xml.Attr) error {
+	c.inFilter = true
+	c.filter = &Filter{}
+	c.filterInvalid = false
+	for _, attr := range attrs {
+		if attr.Name.Local == "id" {
+			c.filterID = attr.Value
+		}
+	}
+	return nil
+}
+
+// closeFilter registers the current filter, unless it
+// uses unsupported primitives
+func (c *iconCursor) closeFilter() {
+	c.inFilter = false
+	if c.filterID != "" && !c.filterInvalid {
+		c.icon.filters[c.filterID] = c.filter
+	}
+	c.filterID = ""
+}
+
+// readFilterIO reads the in and result attributes
+func readFilterIO(attrs []xml.Attr) (in, result string) {
+	for _, attr := range attrs {
+		switch attr.Name.Local {
+		case "in":
+			in = strings.TrimSpace(attr.Value)
+		case "result":
+			result = strings.TrimSpace(attr.Value)
+		}
+	}
+	return in, result
+}
+
+func feGaussianBlurF(c *iconCursor, attrs []xml.Attr) error {
+	if !c.inFilter {
+		return nil
+	}
+	var pr FeGaussianBlur
+	pr.In, pr.Result = readFilterIO(attrs)
+	for _, attr := range attrs {
+		if attr.Name.Local != "stdDeviation" {
+			continue
+		}
+		if err := c.getPoints(attr.Value); err != nil {
+			return err
+		}
+		c.scalePoints(false)
+		switch len(c.points) {
+		case 1:
+			pr.StdDeviationX, pr.StdDeviationY = c.points[0], c.points[0]
+		case 2:
+			pr.StdDeviationX, pr.StdDeviationY = c.points[0], c.points[1]
+		default:
+			return errPathParamMismatch
+		}
+	}
+	c.filter.Primitives = append(c.filter.Primitives, pr)
+	return nil
+}
+
+func feOffsetF(c *iconCursor, attrs []xml.Attr) error {
+	if !c.inFilter {
+		return nil
+	}
+	var (
+		pr  FeOffset
+		err error
+	)
+	pr.In, pr.Result = readFilterIO(attrs)
+	for _, attr := range attrs {
+		switch attr.Name.Local {
+		case "dx":
+			pr.Dx, err = parseBasicFloat(attr.Value)
+			pr.Dx = c.scaled(pr.Dx)
+		case "dy":
+			pr.Dy, err = parseBasicFloat(attr.Value)
+			pr.Dy = c.scaled(pr.Dy)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	c.filter.Primitives = append(c.filter.Primitives, pr)
+	return nil
+}
+
+func feMergeF(c *iconCursor, attrs []xml.Attr) error {
+	if !c.inFilter {
+		return nil
+	}
+	_, result := readFilterIO(attrs)
+	c.filter.Primitives = append(c.filter.Primitives, FeMerge{Result: result})
+	return nil
+}
+
+func feMergeNodeF(c *iconCursor, attrs []xml.Attr) error {
+	if !c.inFilter || len(c.filter.Primitives) == 0 {
+		return nil
+	}
+	last := len(c.filter.Primitives) - 1
+	if merge, ok := c.filter.Primitives[last].(FeMerge); ok {
+		in, _ := readFilterIO(attrs)
+		merge.Nodes = append(merge.Nodes, in)
+		c.filter.Primitives[last] = merge
+	}
+	return nil
+}
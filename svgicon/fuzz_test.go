@@ -0,0 +1,70 @@
+//go:build go1.18
+// +build go1.18
+
+package svgicon
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// The fuzz targets check that malformed inputs never panic nor hang:
+// every failure must be reported as an error.
+//
+// Run them with, for instance :
+//
+//	go test ./svgicon -run XXX -fuzz FuzzParsePathData -fuzztime 1m
+
+func FuzzParsePathData(f *testing.F) {
+	for _, seed := range []string{
+		"M10 10 L20 20 Z",
+		"m10,10 h20 v20 h-20 z",
+		"M10 80 C 40 10, 65 10, 95 80 S 150 150, 180 80",
+		"M10 80 Q 52.5 10, 95 80 T 180 80",
+		"M80 80 A 45 45, 0, 0, 0, 125 125 L 125 80 Z",
+		"M1e2-3.5.5 l-1e-2.4",
+		"M 0 0 A 0 0 0 1 1 10 10",
+	} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, data string) {
+		var c pathCursor
+		c.errorMode = StrictErrorMode
+		_ = c.compilePath(data)
+	})
+}
+
+func FuzzParseTransform(f *testing.F) {
+	for _, seed := range []string{
+		"translate(10)",
+		"translate(10, 20) scale(2)",
+		"rotate(45 10 10) skewX(10) skewY(-5)",
+		"matrix(1 0 0 1 5 5)",
+		"scale(2,3)translate(-1 -1)",
+	} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, data string) {
+		c := iconCursor{styleStack: []PathStyle{DefaultStyle}}
+		_, _ = c.parseTransform(data)
+	})
+}
+
+func FuzzReadIconStream(f *testing.F) {
+	files, _ := filepath.Glob("testdata/*.svg")
+	for _, file := range files {
+		if b, err := os.ReadFile(file); err == nil {
+			f.Add(b)
+		}
+	}
+	f.Add([]byte(`<svg viewBox="0 0 10 10"><defs><g id="a"><use href="#a"/></g></defs><use href="#a"/></svg>`))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		icon, err := ReadIconStream(bytes.NewReader(data), IgnoreErrorMode)
+		if err != nil {
+			return
+		}
+		icon.Draw(&recorder{}, 1)
+	})
+}
@@ -0,0 +1,143 @@
+package svgicon
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/jpeg" // formats supported in data URLs
+	_ "image/png"
+	"strings"
+	"unicode"
+)
+
+// Image is a raster image embedded in an <image> element.
+// Only images given by a base64 data URL are supported.
+type Image struct {
+	Image image.Image
+	// Bounds is the rectangle covered by the whole image, in the user space
+	// of the element, resolved from its position, its size and its
+	// preserveAspectRatio attribute.
+	Bounds Bounds
+}
+
+func imageF(c *iconCursor, attrs []xml.Attr) error {
+	var (
+		viewport            Bounds
+		hasWidth, hasHeight bool
+		href, aspect        string
+		err                 error
+	)
+	for _, attr := range attrs {
+		switch attr.Name.Local {
+		case "x":
+			viewport.X, err = c.parseUnit(attr.Value, widthPercentage)
+		case "y":
+			viewport.Y, err = c.parseUnit(attr.Value, heightPercentage)
+		case "width":
+			viewport.W, err = c.parseUnit(attr.Value, widthPercentage)
+			hasWidth = true
+		case "height":
+			viewport.H, err = c.parseUnit(attr.Value, heightPercentage)
+			hasHeight = true
+		case "href": // either xlink:href or href
+			href = attr.Value
+		case "preserveAspectRatio":
+			aspect = attr.Value
+		}
+		if err != nil {
+			return err
+		}
+	}
+	img, err := decodeDataURL(href)
+	if err != nil {
+		return c.handleError("unsupported image: %s", err)
+	}
+	size := img.Bounds().Size()
+	imageBox := Bounds{W: float64(size.X), H: float64(size.Y)}
+	if imageBox.W == 0 || imageBox.H == 0 {
+		return nil
+	}
+	// the size defaults to the intrinsic size, keeping the aspect ratio
+	switch {
+	case !hasWidth && !hasHeight:
+		viewport.W, viewport.H = c.scaled(imageBox.W), c.scaled(imageBox.H)
+	case !hasWidth:
+		viewport.W = viewport.H * imageBox.W / imageBox.H
+	case !hasHeight:
+		viewport.H = viewport.W * imageBox.H / imageBox.W
+	}
+	if viewport.W <= 0 || viewport.H <= 0 { // not rendered
+		return nil
+	}
+	var par PreserveAspectRatio
+	if aspect != "" {
+		if par, err = ParsePreserveAspectRatio(aspect); err != nil {
+			if err = c.handleError("%s", err); err != nil {
+				return err
+			}
+			par = PreserveAspectRatio{}
+		}
+	}
+	m := par.fit(imageBox, viewport)
+	bounds := Bounds{X: m.E, Y: m.F, W: imageBox.W * m.A, H: imageBox.H * m.D}
+
+	style := c.styleStack[len(c.styleStack)-1]
+	if style.hidden {
+		return nil
+	}
+	if par.Slice { // the image overflows the viewport
+		var clip Path
+		clip.addRect(viewport.X, viewport.Y, viewport.X+viewport.W, viewport.Y+viewport.H, 0)
+		// do not share the backing array with the parent style
+		style.clips = append(append([]Path(nil), style.clips...), clip.transform(style.transform))
+	}
+	var path Path
+	path.addRect(bounds.X, bounds.Y, bounds.X+bounds.W, bounds.Y+bounds.H, 0)
+	c.icon.SVGPaths = append(c.icon.SVGPaths, SvgPath{Path: path, Style: style, Link: style.link,
+		ID: style.id, Classes: style.classes, Image: &Image{Image: img, Bounds: bounds}})
+	return nil
+}
+
+// maxImagePixels limits the size of the embedded images,
+// whose header may otherwise trigger huge allocations
+const maxImagePixels = 1 << 26
+
+// decodeDataURL decodes the image embedded in a base64 data URL,
+// such as data:image/png;base64,iVBORw0KGgo...
+func decodeDataURL(href string) (image.Image, error) {
+	href = strings.TrimSpace(href)
+	if !strings.HasPrefix(href, "data:") {
+		return nil, errors.New("only data URLs are supported")
+	}
+	comma := strings.IndexByte(href, ',')
+	if comma == -1 {
+		return nil, errors.New("invalid data URL")
+	}
+	header, data := href[len("data:"):comma], href[comma+1:]
+	if !strings.HasSuffix(header, ";base64") {
+		return nil, errors.New("only base64 data URLs are supported")
+	}
+	// white spaces are frequent in the data, and the padding is optional
+	data = strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) {
+			return -1
+		}
+		return r
+	}, data)
+	raw, err := base64.RawStdEncoding.DecodeString(strings.TrimRight(data, "="))
+	if err != nil {
+		return nil, err
+	}
+	config, _, err := image.DecodeConfig(bytes.NewReader(raw))
+	if err != nil {
+		return nil, err
+	}
+	if pixels := int64(config.Width) * int64(config.Height); pixels > maxImagePixels {
+		return nil, fmt.Errorf("image too large (%d x %d)", config.Width, config.Height)
+	}
+	img, _, err := image.Decode(bytes.NewReader(raw))
+	return img, err
+}
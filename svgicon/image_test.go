@@ -0,0 +1,135 @@
+package svgicon
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+// pngDataURL returns a data URL embedding a 2x1 image, red then blue.
+func pngDataURL(t *testing.T) string {
+	img := image.NewNRGBA(image.Rect(0, 0, 2, 1))
+	img.Set(0, 0, color.NRGBA{0xff, 0, 0, 0xff})
+	img.Set(1, 0, color.NRGBA{0, 0, 0xff, 0xff})
+	var b bytes.Buffer
+	if err := png.Encode(&b, img); err != nil {
+		t.Fatal(err)
+	}
+	return "data:image/png;base64," + base64.StdEncoding.EncodeToString(b.Bytes())
+}
+
+func TestParseImage(t *testing.T) {
+	href := pngDataURL(t)
+	// whitespaces are allowed in the data
+	spaced := href[:40] + "\n  " + href[40:]
+	icon, err := ReadIconStream(strings.NewReader(fmt.Sprintf(`<svg xmlns="http://www.w3.org/2000/svg" xmlns:xlink="http://www.w3.org/1999/xlink" viewBox="0 0 100 100">
+	<image href="%[1]s" width="10" height="10"/>
+	<image xlink:href="%[2]s" x="10" width="20"/>
+	<image href="%[1]s" width="10" height="10" preserveAspectRatio="xMinYMin slice"/>
+	<image href="%[1]s"/>
+	<image href="%[1]s" width="10" height="10" visibility="hidden"/>
+	<image href="%[1]s" width="0" height="10"/>
+	</svg>`, href, spaced)), StrictErrorMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(icon.SVGPaths) != 4 {
+		t.Fatalf("expected 4 images, got %d", len(icon.SVGPaths))
+	}
+	for i, exp := range []Bounds{
+		{0, 2.5, 10, 5},
+		{10, 0, 20, 10},
+		{0, 0, 20, 10},
+		{0, 0, 2, 1},
+	} {
+		svgp := icon.SVGPaths[i]
+		if svgp.Image == nil {
+			t.Fatalf("path %d: expected an image", i)
+		}
+		if svgp.Image.Bounds != exp {
+			t.Errorf("path %d: expected bounds %v, got %v", i, exp, svgp.Image.Bounds)
+		}
+		if b, _ := svgp.Path.bounds(Identity); b != exp {
+			t.Errorf("path %d: expected the path to cover the image, got %v", i, b)
+		}
+		if c := color.NRGBAModel.Convert(svgp.Image.Image.At(1, 0)); c != (color.NRGBA{0, 0, 0xff, 0xff}) {
+			t.Errorf("path %d: unexpected decoded color %v", i, c)
+		}
+	}
+	// a sliced image is clipped to its viewport
+	if clips := icon.SVGPaths[2].Style.clips; len(clips) != 1 {
+		t.Errorf("expected one clip, got %v", clips)
+	}
+
+	for _, href := range []string{"image.png", "data:image/png,abc", "data:image/png;base64,%%%", "data:image/png;base64,YWJj"} {
+		src := fmt.Sprintf(`<svg viewBox="0 0 10 10"><image href="%s" width="10" height="10"/></svg>`, href)
+		if _, err := ReadIconStream(strings.NewReader(src), StrictErrorMode); err == nil {
+			t.Errorf("%s: expected an error", href)
+		}
+		icon, err := ReadIconStream(strings.NewReader(src), IgnoreErrorMode)
+		if err != nil || len(icon.SVGPaths) != 0 {
+			t.Errorf("%s: expected the image to be ignored, got %v", href, err)
+		}
+	}
+}
+
+func TestImageTooLarge(t *testing.T) {
+	href := pngDataURL(t)
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(href, "data:image/png;base64,"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// only change the size in the IHDR chunk, which starts after the signature
+	binary.BigEndian.PutUint32(raw[16:], 100000)
+	binary.BigEndian.PutUint32(raw[20:], 100000)
+	binary.BigEndian.PutUint32(raw[29:], crc32.ChecksumIEEE(raw[12:29]))
+
+	src := fmt.Sprintf(`<svg viewBox="0 0 10 10"><image href="data:image/png;base64,%s" width="10" height="10"/></svg>`,
+		base64.StdEncoding.EncodeToString(raw))
+	if _, err = ReadIconStream(strings.NewReader(src), StrictErrorMode); err == nil || !strings.Contains(err.Error(), "too large") {
+		t.Errorf("expected a size error, got %v", err)
+	}
+}
+
+// imageRecorder records the images and their opacity
+type imageRecorder struct {
+	recorder
+	images    []Image
+	opacities []float64
+}
+
+func (r *imageRecorder) DrawImage(img Image, m Matrix2D, opacity float64) {
+	r.images = append(r.images, img)
+	r.opacities = append(r.opacities, opacity)
+}
+
+func TestDrawImage(t *testing.T) {
+	icon, err := ReadIconStream(strings.NewReader(fmt.Sprintf(`<svg viewBox="0 0 10 10">
+	<g opacity="0.5" fill-opacity="0.2"><image href="%s" width="10" height="10"/></g>
+	</svg>`, pngDataURL(t))), StrictErrorMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var rec recorder
+	icon.Draw(&rec, 1)
+	if len(rec.fills) != 0 || len(rec.strokePaths) != 0 {
+		t.Error("images should be ignored by drivers not implementing ImageDriver")
+	}
+
+	var imager imageRecorder
+	icon.Draw(&imager, 0.5)
+	if len(imager.images) != 1 || imager.opacities[0] != 0.25 {
+		t.Errorf("unexpected images %v with opacities %v", imager.images, imager.opacities)
+	}
+	if len(imager.fills) != 0 {
+		t.Error("the extent of the image should not be painted")
+	}
+}
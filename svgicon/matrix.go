@@ -82,8 +82,8 @@ var Identity = Matrix2D{1, 0, 0, 1, 0, 0}
 
 // TFixed transforms a fixed.Point26_6 by the matrix
 func (a Matrix2D) TFixed(x fixed.Point26_6) (y fixed.Point26_6) {
-	y.X = fixed.Int26_6((float64(x.X)*a.A + float64(x.Y)*a.C) + a.E*64)
-	y.Y = fixed.Int26_6((float64(x.X)*a.B + float64(x.Y)*a.D) + a.F*64)
+	y.X = fixed.Int26_6(math.Round((float64(x.X)*a.A + float64(x.Y)*a.C) + a.E*64))
+	y.Y = fixed.Int26_6(math.Round((float64(x.X)*a.B + float64(x.Y)*a.D) + a.F*64))
 	return
 }
 
@@ -114,7 +114,8 @@ func (a Matrix2D) Scale(x, y float64) Matrix2D {
 		F: 0})
 }
 
-//SkewY skews the matrix in the Y dimension
+// SkewY skews the matrix in the Y dimension, by the angle `theta`
+// given in radians, as the skewY SVG transform (with degrees).
 func (a Matrix2D) SkewY(theta float64) Matrix2D {
 	return a.Mult(Matrix2D{
 		A: 1,
@@ -125,7 +126,8 @@ func (a Matrix2D) SkewY(theta float64) Matrix2D {
 		F: 0})
 }
 
-//SkewX skews the matrix in the X dimension
+// SkewX skews the matrix in the X dimension, by the angle `theta`
+// given in radians, as the skewX SVG transform (with degrees).
 func (a Matrix2D) SkewX(theta float64) Matrix2D {
 	return a.Mult(Matrix2D{
 		A: 1,
@@ -136,6 +138,14 @@ func (a Matrix2D) SkewX(theta float64) Matrix2D {
 		F: 0})
 }
 
+// Shear returns the matrix shearing by the factors `sx` in the X
+// dimension and `sy` in the Y dimension, that is mapping (x, y)
+// to (x + sx*y, y + sy*x).
+// Note that Shear(math.Tan(a), 0) is Identity.SkewX(a).
+func Shear(sx, sy float64) Matrix2D {
+	return Matrix2D{A: 1, B: sy, C: sx, D: 1}
+}
+
 //Translate translates the matrix to the x , y point
 func (a Matrix2D) Translate(x, y float64) Matrix2D {
 	return a.Mult(Matrix2D{
@@ -0,0 +1,46 @@
+package svgicon
+
+import (
+	"math"
+	"testing"
+)
+
+func TestShear(t *testing.T) {
+	angle := math.Pi / 6
+	if m := Shear(math.Tan(angle), 0); !matrixAlmostEqual(m, Identity.SkewX(angle)) {
+		t.Errorf("expected SkewX, got %v", m)
+	}
+	if m := Shear(0, math.Tan(angle)); !matrixAlmostEqual(m, Identity.SkewY(angle)) {
+		t.Errorf("expected SkewY, got %v", m)
+	}
+	if m := Shear(0.5, 0).Mult(Shear(0.25, 0)); !matrixAlmostEqual(m, Shear(0.75, 0)) {
+		t.Errorf("shears in the same dimension should add, got %v", m)
+	}
+	m := Identity.Translate(10, 0).Mult(Shear(1, 2))
+	if x, y := m.Transform(1, 1); x != 12 || y != 3 {
+		t.Errorf("unexpected transformed point (%g, %g)", x, y)
+	}
+	if inv := Shear(0.5, 0).Invert(); !matrixAlmostEqual(inv, Shear(-0.5, 0)) {
+		t.Errorf("unexpected inverse %v", inv)
+	}
+}
+
+func TestSkewUnitSquare(t *testing.T) {
+	square := [4][2]float64{{0, 0}, {1, 0}, {1, 1}, {0, 1}}
+	for _, test := range []struct {
+		m   Matrix2D
+		exp [4][2]float64
+	}{
+		{Identity.SkewX(math.Pi / 4), [4][2]float64{{0, 0}, {1, 0}, {2, 1}, {1, 1}}},
+		{Identity.SkewY(math.Pi / 4), [4][2]float64{{0, 0}, {1, 1}, {1, 2}, {0, 1}}},
+		{Shear(0.5, 0), [4][2]float64{{0, 0}, {1, 0}, {1.5, 1}, {0.5, 1}}},
+		{Shear(0.5, 0.25), [4][2]float64{{0, 0}, {1, 0.25}, {1.5, 1.25}, {0.5, 1}}},
+	} {
+		for i, p := range square {
+			x, y := test.m.Transform(p[0], p[1])
+			if !almostEqual(x, test.exp[i][0]) || !almostEqual(y, test.exp[i][1]) {
+				t.Errorf("%v: expected %v, got (%g, %g)", test.m, test.exp[i], x, y)
+			}
+		}
+	}
+}
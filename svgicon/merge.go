@@ -0,0 +1,159 @@
+package svgicon
+
+import (
+	"encoding/xml"
+	"strconv"
+	"strings"
+)
+
+// Merge appends the paths of `other` to the icon, transformed so that the view box
+// of `other` fits into the rectangle `at`, expressed in the icon user space.
+// The gradients and definitions of `other` are also added, renaming
+// their ID in case of collision, and updating the references of `other`
+// to the renamed elements. `other` is not modified.
+func (s *SvgIcon) Merge(other *SvgIcon, at Bounds) {
+	m := fitMatrix(other.ViewBox, at)
+
+	if s.grads == nil {
+		s.grads = make(map[string]*Gradient)
+	}
+	if s.defs == nil {
+		s.defs = make(map[string][]definition)
+	}
+	renamed := make(map[string]string) // old -> new ID
+	for id, grad := range other.grads {
+		newID := uniqueID(id, func(id string) bool { _, ok := s.grads[id]; return ok })
+		s.grads[newID] = grad
+		if newID != id {
+			renamed[id] = newID
+		}
+	}
+	newDefs := make([]string, 0, len(other.defs))
+	for id, def := range other.defs {
+		newID := uniqueID(id, func(id string) bool { _, ok := s.defs[id]; return ok })
+		s.defs[newID] = def
+		newDefs = append(newDefs, newID)
+		if newID != id {
+			renamed[id] = newID
+		}
+	}
+	// the references may only be updated once all the IDs are known
+	for _, id := range newDefs {
+		def := make([]definition, len(s.defs[id]))
+		for i, elem := range s.defs[id] {
+			elem.ID = renameID(elem.ID, renamed)
+			elem.Attrs = renameReferences(elem.Attrs, renamed)
+			def[i] = elem
+		}
+		def[0].ID = id
+		s.defs[id] = def
+	}
+
+	// isolated groups of `other` must not be confused with the ones of `s`
+	isolationOffset := 0
+	for _, svgp := range s.SVGPaths {
+		for _, id := range svgp.Style.isolation {
+			if id > isolationOffset {
+				isolationOffset = id
+			}
+		}
+	}
+	for _, svgp := range other.SVGPaths {
+		svgp.Path = append(Path(nil), svgp.Path...)
+		svgp.Style.transform = m.Mult(svgp.Style.transform)
+		// clips are expressed in icon coordinates
+		clips := make([]Path, len(svgp.Style.clips))
+		for i, clip := range svgp.Style.clips {
+			clips[i] = clip.transform(m)
+		}
+		svgp.Style.clips = clips
+		isolation := make([]int, len(svgp.Style.isolation))
+		for i, id := range svgp.Style.isolation {
+			isolation[i] = id + isolationOffset
+		}
+		svgp.Style.isolation = isolation
+		svgp.ID = renameID(svgp.ID, renamed)
+		if strings.HasPrefix(svgp.Link, "#") {
+			svgp.Link = "#" + renameID(svgp.Link[1:], renamed)
+		}
+		s.SVGPaths = append(s.SVGPaths, svgp)
+	}
+
+	for _, fo := range other.ForeignObjects {
+		fo.Transform = m.Mult(fo.Transform)
+		s.ForeignObjects = append(s.ForeignObjects, fo)
+	}
+
+	s.missingGrads = append(s.missingGrads, other.missingGrads...)
+	s.missingUses = append(s.missingUses, other.missingUses...)
+	s.unsupported = append(s.unsupported, other.unsupported...)
+	s.clamped = append(s.clamped, other.clamped...)
+}
+
+// uniqueID returns `id`, or, if it is already used,
+// `id` with the first free numeric suffix
+func uniqueID(id string, isUsed func(string) bool) string {
+	if !isUsed(id) {
+		return id
+	}
+	for i := 1; ; i++ {
+		candidate := id + "-" + strconv.Itoa(i)
+		if !isUsed(candidate) {
+			return candidate
+		}
+	}
+}
+
+// renameID returns the new name of `id`, or `id` if it has not been renamed
+func renameID(id string, renamed map[string]string) string {
+	if newID, ok := renamed[id]; ok {
+		return newID
+	}
+	return id
+}
+
+// renameReferences returns a copy of `attrs`, where the ids and the references
+// (href and url(#id) values) to the elements of `renamed` are updated
+func renameReferences(attrs []xml.Attr, renamed map[string]string) []xml.Attr {
+	out := make([]xml.Attr, len(attrs))
+	for i, attr := range attrs {
+		switch {
+		case attr.Name.Local == "id":
+			attr.Value = renameID(attr.Value, renamed)
+		case attr.Name.Local == "href": // either xlink:href or href
+			if strings.HasPrefix(attr.Value, "#") {
+				attr.Value = "#" + renameID(attr.Value[1:], renamed)
+			}
+		case strings.Contains(attr.Value, "url("): // either a presentation attribute or a style
+			attr.Value = renameURLs(attr.Value, renamed)
+		}
+		out[i] = attr
+	}
+	return out
+}
+
+// renameURLs updates the url(#id) references of `value`
+func renameURLs(value string, renamed map[string]string) string {
+	var b strings.Builder
+	for {
+		start := strings.Index(value, "url(")
+		if start == -1 {
+			break
+		}
+		end := strings.IndexByte(value[start:], ')')
+		if end == -1 {
+			break
+		}
+		end += start
+		ref := strings.Trim(strings.TrimSpace(value[start+len("url("):end]), `'"`)
+		b.WriteString(value[:start])
+		if strings.HasPrefix(ref, "#") {
+			b.WriteString("url(#" + renameID(ref[1:], renamed) + ")")
+		} else {
+			b.WriteString(value[start : end+1])
+		}
+		value = value[end+1:]
+	}
+	b.WriteString(value)
+	return b.String()
+}
@@ -18,19 +18,62 @@ type (
 		icon                                    *SvgIcon
 		styleStack                              []PathStyle
 		grad                                    *Gradient
+		gradOpacity                             float64 // non standard opacity attribute of the current gradient
 		inTitleText, inDescText, inGrad, inDefs bool
 		currentDef                              []definition
+		defsRecorded                            []bool // for each opened element in defs, true if it has been recorded
+
+		rootSeen            bool     // true after the top level <svg> element
+		rootUnsized         bool     // true if the top level <svg> element has no viewBox, width or height
+		inSymbol            bool     // true inside a <symbol> declared outside <defs>
+		useWidth, useHeight float64  // viewport size of the current <use> element, 0 if unspecified
+		usedIDs             []string // definitions being expanded, to detect circular references
+		expandedElements    int      // number of elements instantiated by <use>
+
+		inStyleText bool
+		styleText   string    // content of the current <style> element
+		cssRules    []cssRule // rules from the <style> elements seen so far
+
+		filter                  *Filter // current filter
+		filterID                string
+		inFilter, filterInvalid bool // filterInvalid is true if the current filter uses unsupported primitives
+
+		isolatedGroups int // number of isolated groups seen so far, used as identifiers
+
+		clipShapes []clipShape // pending clip-path shapes of the opened elements
+
+		inText   bool
+		textRuns []SvgPath // runs of the current <text> element
+
+		gradElements map[string]definition // gradient elements, by id
+		gradLinks    map[string]string     // id of the gradients referencing an other one, not resolved yet
+
+		// skipDepth is the number of opened elements whose content is ignored,
+		// including the element starting the skip, or 0
+		skipDepth int
 	}
 
 	// definition is used to store what's given in a def tag
 	definition struct {
 		ID, Tag string
 		Attrs   []xml.Attr
+		// character data following the start (or the end) of the element,
+		// used by the text elements
+		CharData string
 	}
 )
 
+// endDefinition is the tag of the marker
+// closing a definition element.
+const endDefinition = "end"
+
+// maxExpandedElements limits the number of elements
+// instantiated by <use>, which may otherwise grow exponentially
+const maxExpandedElements = 1 << 20
+
 func fToFixed(f float64) fixed.Int26_6 {
-	return fixed.Int26_6(f * 64)
+	// rounding (instead of truncating toward zero) avoids a systematic bias
+	return fixed.Int26_6(math.Round(f * 64))
 }
 
 // treat the error according to the errorMode
@@ -48,7 +91,7 @@ func (c *iconCursor) readTransformAttr(m1 Matrix2D, k string) (Matrix2D, error)
 	ln := len(c.points)
 	switch k {
 	case "rotate":
-		if ln == 1 {
+		if ln == 1 { // the center defaults to the origin
 			m1 = m1.Rotate(c.points[0] * math.Pi / 180)
 		} else if ln == 3 {
 			m1 = m1.Translate(c.points[1], c.points[2]).
@@ -58,7 +101,7 @@ func (c *iconCursor) readTransformAttr(m1 Matrix2D, k string) (Matrix2D, error)
 			return m1, errTransformParamMismatch
 		}
 	case "translate":
-		if ln == 1 {
+		if ln == 1 { // ty defaults to 0
 			m1 = m1.Translate(c.points[0], 0)
 		} else if ln == 2 {
 			m1 = m1.Translate(c.points[0], c.points[1])
@@ -78,8 +121,8 @@ func (c *iconCursor) readTransformAttr(m1 Matrix2D, k string) (Matrix2D, error)
 			return m1, errTransformParamMismatch
 		}
 	case "scale":
-		if ln == 1 {
-			m1 = m1.Scale(c.points[0], 0)
+		if ln == 1 { // sy defaults to sx
+			m1 = m1.Scale(c.points[0], c.points[0])
 		} else if ln == 2 {
 			m1 = m1.Scale(c.points[0], c.points[1])
 		} else {
@@ -104,11 +147,51 @@ func (c *iconCursor) readTransformAttr(m1 Matrix2D, k string) (Matrix2D, error)
 	return m1, nil
 }
 
+// parseTransform parses the transform presentation attribute (or the
+// gradientTransform attribute), whose arguments are plain numbers,
+// and applies it to the current transform.
 func (c *iconCursor) parseTransform(v string) (Matrix2D, error) {
+	return c.parseTransformList(c.styleStack[len(c.styleStack)-1].transform, v, c.getTransformArgs)
+}
+
+// getTransformArgs parses the arguments of a transformation
+// given in an attribute, which must be plain numbers
+func (c *iconCursor) getTransformArgs(name, args string) error {
+	if strings.ContainsRune(args, '%') {
+		return errors.New("invalid transformation: percentages are not allowed")
+	}
+	if err := c.getPoints(args); err != nil {
+		return err
+	}
+	c.scaleTransformArgs(name)
+	return nil
+}
+
+// scaleTransformArgs applies the parse time scale factor to the
+// translation arguments of the transformation `name`, read in c.points,
+// so that the transformation commutes with the scaling.
+func (c *iconCursor) scaleTransformArgs(name string) {
+	first := len(c.points)
+	switch name {
+	case "translate":
+		first = 0
+	case "rotate": // center
+		first = 1
+	case "matrix":
+		first = 4
+	}
+	for i := first; i < len(c.points); i++ {
+		c.points[i] = c.scaled(c.points[i])
+	}
+}
+
+// parseTransformList applies the transformations listed in `v` to `m1`,
+// using `readArgs` to parse the arguments of each transformation into c.points.
+func (c *iconCursor) parseTransformList(m1 Matrix2D, v string, readArgs func(name, args string) error) (Matrix2D, error) {
 	ts := strings.Split(v, ")")
-	m1 := c.styleStack[len(c.styleStack)-1].transform
 	for _, t := range ts {
-		t = strings.TrimSpace(t)
+		// transformations may be separated by a comma
+		t = strings.TrimLeft(strings.TrimSpace(t), ", \t\n\r")
 		if len(t) == 0 {
 			continue
 		}
@@ -116,11 +199,12 @@ func (c *iconCursor) parseTransform(v string) (Matrix2D, error) {
 		if len(d) != 2 || len(d[1]) < 1 {
 			return m1, errors.New("invalid transformation") // badly formed transformation
 		}
-		err := c.getPoints(d[1])
+		name := strings.ToLower(strings.TrimSpace(d[0]))
+		err := readArgs(name, d[1])
 		if err != nil {
 			return m1, err
 		}
-		m1, err = c.readTransformAttr(m1, strings.ToLower(strings.TrimSpace(d[0])))
+		m1, err = c.readTransformAttr(m1, name)
 		if err != nil {
 			return m1, err
 		}
@@ -130,22 +214,35 @@ func (c *iconCursor) parseTransform(v string) (Matrix2D, error) {
 
 func (c *iconCursor) readStyleAttr(curStyle *PathStyle, k, v string) error {
 	switch k {
+	case "color":
+		optCol, err := parseColorWith(v, curStyle)
+		if err != nil {
+			return err
+		}
+		if optCol.valid {
+			curStyle.color = optCol.color
+		}
 	case "fill":
 		gradient, ok := c.readGradURL(v, curStyle.FillerColor)
 		if ok {
 			curStyle.FillerColor = gradient
+			curStyle.fillRef = ""
 			break
 		}
-		optCol, err := parseSVGColor(v)
+		// a gradient may be defined later: resolve it at the end
+		curStyle.fillRef = gradientRef(v)
+		optCol, err := parseColorWith(v, curStyle)
 		curStyle.FillerColor = optCol.asPattern()
 		return err
 	case "stroke":
 		gradient, ok := c.readGradURL(v, curStyle.LinerColor)
 		if ok {
 			curStyle.LinerColor = gradient
+			curStyle.strokeRef = ""
 			break
 		}
-		optCol, errc := parseSVGColor(v)
+		curStyle.strokeRef = gradientRef(v)
+		optCol, errc := parseColorWith(v, curStyle)
 		if errc != nil {
 			return errc
 		}
@@ -215,9 +312,76 @@ func (c *iconCursor) readStyleAttr(curStyle *PathStyle, k, v string) error {
 		if err != nil {
 			return err
 		}
+		if mLimit < 1 { // the miter length is always greater than the line width
+			return c.handleError("invalid value '%s' for <stroke-miterlimit>", v)
+		}
 		curStyle.Join.MiterLimit = fToFixed(mLimit)
+	case "font-size":
+		size, err := c.parseFontSize(v, curStyle.fontSize)
+		if err != nil {
+			return c.handleError("unsupported value '%s' for <font-size>", v)
+		}
+		curStyle.fontSize = size
+	case "font-family":
+		curStyle.fontFamily = v
+	case "text-anchor":
+		switch v {
+		case "start":
+			curStyle.textAnchor = AnchorStart
+		case "middle":
+			curStyle.textAnchor = AnchorMiddle
+		case "end":
+			curStyle.textAnchor = AnchorEnd
+		default:
+			return c.handleError("unsupported value '%s' for <text-anchor>", v)
+		}
+	case "fill-rule":
+		switch v {
+		case "nonzero":
+			curStyle.UseNonZeroWinding = true
+		case "evenodd":
+			curStyle.UseNonZeroWinding = false
+		default:
+			return c.handleError("unsupported value '%s' for <fill-rule>", v)
+		}
+	case "stroke-alignment":
+		switch v {
+		case "center":
+			curStyle.StrokeAlignment = CenterAlignment
+		case "inner":
+			curStyle.StrokeAlignment = InnerAlignment
+		case "outer":
+			curStyle.StrokeAlignment = OuterAlignment
+		default:
+			return c.handleError("unsupported value '%s' for <stroke-alignment>", v)
+		}
+	case "visibility":
+		switch v {
+		case "hidden", "collapse":
+			curStyle.hidden = true
+		case "visible":
+			curStyle.hidden = false
+		}
+	case "mix-blend-mode":
+		mode, ok := parseBlendMode(v)
+		if !ok {
+			return c.handleError("unsupported value '%s' for <mix-blend-mode>", v)
+		}
+		curStyle.BlendMode = mode
+	case "filter":
+		filter, err := c.readFilterURL(v)
+		if err != nil {
+			return err
+		}
+		curStyle.Filter = filter
+		curStyle.filterRef = ""
+		if filter == nil {
+			// a filter may be defined later: resolve it at the end
+			curStyle.filterRef = gradientRef(v)
+		}
 	case "stroke-width":
-		width, err := c.parseUnit(v, widthPercentage)
+		// percentages refer to the normalized diagonal of the viewport
+		width, err := c.parseUnit(v, diagPercentage)
 		if err != nil {
 			return err
 		}
@@ -229,36 +393,20 @@ func (c *iconCursor) readStyleAttr(curStyle *PathStyle, k, v string) error {
 		}
 		curStyle.Dash.DashOffset = dashOffset
 	case "stroke-dasharray":
-		if v != "none" {
-			dashes := splitOnCommaOrSpace(v)
-			dList := make([]float64, len(dashes))
-			for i, dstr := range dashes {
-				d, err := c.parseUnit(strings.TrimSpace(dstr), diagPercentage)
-				if err != nil {
-					return err
-				}
-				dList[i] = d
-			}
-			curStyle.Dash.Dash = dList
+		if v == "none" { // resets an inherited dash array
+			curStyle.Dash.Dash = nil
 			break
 		}
-	case "opacity", "stroke-opacity", "fill-opacity":
-		op, err := parseBasicFloat(v)
-		if err != nil {
-			return err
-		}
-		if k != "stroke-opacity" {
-			curStyle.FillOpacity *= op
-		}
-		if k != "fill-opacity" {
-			curStyle.LineOpacity *= op
-		}
-	case "transform":
-		m, err := c.parseTransform(v)
-		if err != nil {
-			return err
+		dashes := splitOnCommaOrSpace(v)
+		dList := make([]float64, len(dashes))
+		for i, dstr := range dashes {
+			d, err := c.parseUnit(strings.TrimSpace(dstr), diagPercentage)
+			if err != nil {
+				return err
+			}
+			dList[i] = d
 		}
-		curStyle.transform = m
+		curStyle.Dash.Dash = dList
 	}
 	return nil
 }
@@ -266,34 +414,206 @@ func (c *iconCursor) readStyleAttr(curStyle *PathStyle, k, v string) error {
 // pushStyle parses the style element, and push it on the style stack. Only color and opacity are supported
 // for fill. Note that this parses both the contents of a style attribute plus
 // direct fill and opacity attributes.
-func (c *iconCursor) pushStyle(attrs []xml.Attr) error {
-	var pairs []string
+func (c *iconCursor) pushStyle(tag string, attrs []xml.Attr) error {
+	var pairs, classes, inlinePairs []string
+	var id string
 	for _, attr := range attrs {
+		if attr.Name.Space != "" {
+			// presentation attributes are never namespaced:
+			// ignore xlink:href, xml:space, or editor specific data
+			continue
+		}
+		if attr.Name.Local == "id" {
+			id = attr.Value
+		}
 		switch strings.ToLower(attr.Name.Local) {
 		case "style":
-			pairs = append(pairs, strings.Split(attr.Value, ";")...)
+			inlinePairs = append(inlinePairs, strings.Split(attr.Value, ";")...)
+		case "class":
+			classes = strings.Fields(attr.Value)
 		default:
 			pairs = append(pairs, attr.Name.Local+":"+attr.Value)
 		}
 	}
+	// style sheets override presentation attributes,
+	// and are overriden by the style attribute
+	nbAttributes := len(pairs)
+	pairs = append(pairs, c.matchingDeclarations(tag, id, classes)...)
+	pairs = append(pairs, inlinePairs...)
 	// Make a copy of the top style
 	curStyle := c.styleStack[len(c.styleStack)-1]
+	curStyle.noDisplay = false
+	curStyle.switchDone = nil
+	// the transform and opacity properties are resolved once all the
+	// declarations are known, so that the last declaration wins
+	var transform elementTransform
+	opacity, fillOpacity, strokeOpacity := 1., 1., 1.
+	var isolate bool
+	var clipPath string
+	// the spacings are resolved once the font size is known
+	var letterSpacing, wordSpacing string
+	// the color property is resolved first, since the
+	// currentColor keyword of the other properties refers to it
 	for _, pair := range pairs {
-		kv := strings.Split(pair, ":")
-		if len(kv) >= 2 {
+		kv := strings.SplitN(pair, ":", 2)
+		if len(kv) == 2 && strings.ToLower(strings.TrimSpace(kv[0])) == "color" {
+			if err := c.readStyleAttr(&curStyle, "color", strings.TrimSpace(kv[1])); err != nil {
+				return err
+			}
+		}
+	}
+	for i, pair := range pairs {
+		// values may contain ':', as in url(http://...)
+		kv := strings.SplitN(pair, ":", 2)
+		if len(kv) == 2 {
 			k := strings.ToLower(kv[0])
 			k = strings.TrimSpace(k)
 			v := strings.TrimSpace(kv[1])
-			err := c.readStyleAttr(&curStyle, k, v)
-			if err != nil {
-				return err
+			switch {
+			case k == "color": // already resolved
+			case k == "transform" && i < nbAttributes:
+				transform.attribute = v
+			case k == "transform":
+				transform.css = v
+			case k == "transform-origin":
+				transform.origin = v
+			case k == "transform-box":
+				transform.box = v
+			case k == "display":
+				curStyle.noDisplay = v == "none"
+			case k == "isolation":
+				isolate = v == "isolate"
+			case k == "enable-background": // deprecated, with a similar effect
+				isolate = strings.HasPrefix(v, "new")
+			case k == "clip-path":
+				clipPath = v
+			case k == "letter-spacing":
+				letterSpacing = v
+			case k == "word-spacing":
+				wordSpacing = v
+			case k == "opacity" || k == "fill-opacity" || k == "stroke-opacity":
+				op, err := readFraction(v)
+				if err != nil {
+					// browsers ignore invalid declarations
+					if err = c.handleError("invalid value '%s' for <%s>", v, k); err != nil {
+						return err
+					}
+					continue
+				}
+				if clamped := math.Max(0, math.Min(1, op)); clamped != op {
+					// out of range values are clamped, and reported by Validate
+					c.icon.clamped = append(c.icon.clamped, clampedValue{property: k, value: op})
+					op = clamped
+				}
+				switch k {
+				case "opacity":
+					opacity = op
+				case "fill-opacity":
+					fillOpacity = op
+				default:
+					strokeOpacity = op
+				}
+			default:
+				err := c.readStyleAttr(&curStyle, k, v)
+				if err != nil {
+					return err
+				}
 			}
 		}
 	}
+	if err := c.readSpacing(&curStyle.letterSpacing, "letter-spacing", letterSpacing, curStyle.fontSize); err != nil {
+		return err
+	}
+	if err := c.readSpacing(&curStyle.wordSpacing, "word-spacing", wordSpacing, curStyle.fontSize); err != nil {
+		return err
+	}
+	curStyle.opacity *= opacity
+	curStyle.FillOpacity *= opacity * fillOpacity
+	curStyle.LineOpacity *= opacity * strokeOpacity
+	if transform != (elementTransform{}) {
+		var ref *Bounds
+		if transform.origin != "" {
+			ref = c.referenceBox(tag, attrs, transform.box, curStyle)
+		}
+		m, err := c.resolveTransform(curStyle.transform, transform, ref)
+		if err != nil {
+			return err
+		}
+		curStyle.transform = m
+	}
+	curStyle.id = id
+	if len(classes) != 0 {
+		// do not modify the slice of the parent style
+		curStyle.classes = append(append([]string(nil), classes...), curStyle.classes...)
+	}
+	if isolate {
+		c.isolatedGroups++
+		// do not modify the slice of the parent style
+		curStyle.isolation = append(append([]int(nil), curStyle.isolation...), c.isolatedGroups)
+	}
 	c.styleStack = append(c.styleStack, curStyle) // Push style onto stack
+	if clipPath != "" {
+		return c.readClipPath(clipPath)
+	}
+	return nil
+}
+
+// readSpacing parses the letter-spacing or word-spacing property `k`, if `v` is
+// not empty, where 'em' and percentages refer to the font size.
+func (c *iconCursor) readSpacing(spacing *float64, k, v string, fontSize float64) error {
+	switch v {
+	case "":
+		return nil
+	case "normal":
+		*spacing = 0
+		return nil
+	}
+	value, err := c.parseFontSize(v, fontSize)
+	if err != nil {
+		return c.handleError("unsupported value '%s' for <%s>", v, k)
+	}
+	*spacing = value
 	return nil
 }
 
+// isRendered returns true for the elements affected by the display
+// property, that is the graphics and container elements.
+// The others (gradients, filters, style sheets...) are never directly
+// rendered, and are always defined.
+func isRendered(tag string) bool {
+	switch tag {
+	case "svg", "g", "switch", "a", "use", "foreignObject",
+		"line", "rect", "circle", "ellipse", "polyline", "polygon", "path", "text", "tspan", "image":
+		return true
+	}
+	return false
+}
+
+// switchSkips returns true if the element `tag`, whose style is on top of
+// the stack, is a direct child of a <switch> which is not rendered :
+// only the first child whose conditional attributes evaluate to true is.
+// No extension is supported, so that requiredExtensions always fails, but,
+// the user language being unknown, systemLanguage is not evaluated.
+func (c *iconCursor) switchSkips(tag string, attrs []xml.Attr) bool {
+	if len(c.styleStack) < 2 || !isRendered(tag) {
+		return false
+	}
+	done := c.styleStack[len(c.styleStack)-2].switchDone
+	if done == nil { // not in a <switch>
+		return false
+	}
+	if *done {
+		return true
+	}
+	for _, attr := range attrs {
+		if attr.Name.Space == "" && attr.Name.Local == "requiredExtensions" {
+			return true
+		}
+	}
+	*done = true
+	return false
+}
+
 // splitOnCommaOrSpace returns a list of strings after splitting the input on comma and space delimiters
 func splitOnCommaOrSpace(s string) []string {
 	return strings.FieldsFunc(s,
@@ -302,11 +622,105 @@ func splitOnCommaOrSpace(s string) []string {
 		})
 }
 
+// endDefElement is called at the end of an element inside <defs>
+func (c *iconCursor) endDefElement() {
+	L := len(c.defsRecorded)
+	if L == 0 { // closing <defs>
+		return
+	}
+	recorded := c.defsRecorded[L-1]
+	c.defsRecorded = c.defsRecorded[:L-1]
+	if recorded {
+		c.currentDef = append(c.currentDef, definition{Tag: endDefinition})
+	}
+}
+
+// registerDef stores the pending definition, if any.
+// The nested elements with an id are also registered
+// as their own definitions, so that they may be used directly.
+func (c *iconCursor) registerDef() {
+	def := c.currentDef
+	if len(def) == 0 {
+		return
+	}
+	c.icon.defs[def[0].ID] = def
+	for i := 1; i < len(def); i++ {
+		if def[i].ID == "" || def[i].Tag == endDefinition {
+			continue
+		}
+		// look for the end marker of the nested element
+		depth, end := 0, len(def)
+		for j := i; j < len(def); j++ {
+			if def[j].Tag != endDefinition {
+				depth++
+			} else if depth--; depth == 0 {
+				end = j + 1
+				break
+			}
+		}
+		if _, ok := c.icon.defs[def[i].ID]; !ok {
+			c.icon.defs[def[i].ID] = def[i:end]
+		}
+	}
+	c.currentDef = make([]definition, 0)
+}
+
+// addDefCharData records `data` in the current definition, if any.
+func (c *iconCursor) addDefCharData(data string) {
+	L := len(c.defsRecorded)
+	if L == 0 || !c.defsRecorded[L-1] || len(c.currentDef) == 0 {
+		return
+	}
+	c.currentDef[len(c.currentDef)-1].CharData += data
+}
+
+// closeDefs registers the pending definition and
+// ends the definition capture.
+func (c *iconCursor) closeDefs() {
+	c.registerDef()
+	c.inDefs = false
+}
+
+// styleProperty returns the value of the property `name`, looking
+// in the presentation attributes and in the style attribute.
+func styleProperty(attrs []xml.Attr, name string) (value string, ok bool) {
+	for _, attr := range attrs {
+		if attr.Name.Space == "" && attr.Name.Local == name {
+			value, ok = strings.TrimSpace(attr.Value), true
+		}
+	}
+	for _, attr := range attrs {
+		if attr.Name.Space != "" || attr.Name.Local != "style" {
+			continue
+		}
+		for _, pair := range strings.Split(attr.Value, ";") {
+			kv := strings.Split(pair, ":")
+			if len(kv) >= 2 && strings.ToLower(strings.TrimSpace(kv[0])) == name {
+				value, ok = strings.TrimSpace(kv[1]), true
+			}
+		}
+	}
+	return value, ok
+}
+
 func (c *iconCursor) readStartElement(se xml.StartElement) (err error) {
+	if se.Name.Local == "symbol" && !c.inDefs {
+		// symbols are never rendered directly, only through <use>:
+		// store them as definitions
+		c.inDefs = true
+		c.inSymbol = true
+	}
 	var skipDef bool
-	if se.Name.Local == "radialGradient" || se.Name.Local == "linearGradient" || c.inGrad {
+	// style sheets apply to the whole document, even inside <defs>
+	if se.Name.Local == "radialGradient" || se.Name.Local == "linearGradient" || c.inGrad ||
+		se.Name.Local == "filter" || c.inFilter || se.Name.Local == "style" {
 		skipDef = true
 	}
+	var isTopLevel bool
+	if c.inDefs {
+		isTopLevel = len(c.defsRecorded) == 0
+		c.defsRecorded = append(c.defsRecorded, !skipDef)
+	}
 	if c.inDefs && !skipDef {
 		ID := ""
 		for _, attr := range se.Attr {
@@ -314,9 +728,10 @@ func (c *iconCursor) readStartElement(se xml.StartElement) (err error) {
 				ID = attr.Value
 			}
 		}
-		if ID != "" && len(c.currentDef) > 0 {
-			c.icon.defs[c.currentDef[0].ID] = c.currentDef
-			c.currentDef = make([]definition, 0)
+		// only direct children of <defs> start a new definition,
+		// nested elements are part of their ancestor
+		if isTopLevel {
+			c.registerDef()
 		}
 		c.currentDef = append(c.currentDef, definition{
 			ID:    ID,
@@ -325,24 +740,73 @@ func (c *iconCursor) readStartElement(se xml.StartElement) (err error) {
 		})
 		return nil
 	}
+	if c.styleStack[len(c.styleStack)-1].noDisplay && isRendered(se.Name.Local) || c.switchSkips(se.Name.Local, se.Attr) {
+		// the element and its descendants are not rendered
+		c.skipDepth = 1
+		return nil
+	}
 	df, ok := drawFuncs[se.Name.Local]
 	if !ok {
+		if c.inFilter { // fallback to no filter at all
+			c.filterInvalid = true
+		}
+		c.icon.unsupported = append(c.icon.unsupported, se.Name.Local)
 		errStr := "Cannot process svg element " + se.Name.Local
 		if c.errorMode == StrictErrorMode {
 			return errors.New(errStr)
 		} else if c.errorMode == WarnErrorMode {
 			log.Println(errStr)
 		}
+		// the descendants of an unsupported element are ignored as well,
+		// so that containers such as <mask> or <clipPath> are not partially rendered
+		c.skipDepth = 1
 		return nil
 	}
 	err = df(c, se.Attr)
+	if err == nil {
+		err = c.applyPathLength(se.Attr)
+	}
+	c.flushPath()
+	return
+}
+
+// applyPathLength scales the dash pattern of the current element
+// so that it is expressed in units of its pathLength attribute, if any.
+func (c *iconCursor) applyPathLength(attrs []xml.Attr) error {
+	for _, attr := range attrs {
+		if attr.Name.Local != "pathLength" {
+			continue
+		}
+		pathLength, err := parseBasicFloat(attr.Value)
+		pathLength = c.scaled(pathLength) // as the path and the dashes
+		if err != nil || pathLength < 0 {
+			return c.handleError("invalid value '%s' for <pathLength>", attr.Value)
+		}
+		style := &c.styleStack[len(c.styleStack)-1]
+		if pathLength == 0 || len(c.path) == 0 || len(style.Dash.Dash) == 0 {
+			return nil
+		}
+		factor := c.path.length() / pathLength
+		// do not share the backing array with the parent style
+		dash := make([]float64, len(style.Dash.Dash))
+		for i, v := range style.Dash.Dash {
+			dash[i] = v * factor
+		}
+		style.Dash = DashOptions{Dash: dash, DashOffset: style.Dash.DashOffset * factor}
+	}
+	return nil
+}
 
+// flushPath stores the path parsed from the current element, if any,
+// with the current style.
+func (c *iconCursor) flushPath() {
 	if len(c.path) > 0 {
-		// The cursor parsed a path from the xml element
-		pathCopy := append(Path{}, c.path...)
-		c.icon.SVGPaths = append(c.icon.SVGPaths,
-			SvgPath{Path: pathCopy, Style: c.styleStack[len(c.styleStack)-1]})
+		style := c.styleStack[len(c.styleStack)-1]
+		if !style.hidden { // invisible elements are not painted
+			pathCopy := append(Path{}, c.path...)
+			c.icon.SVGPaths = append(c.icon.SVGPaths,
+				SvgPath{Path: pathCopy, Style: style, Link: style.link, ID: style.id, Classes: style.classes})
+		}
 		c.path = c.path[:0]
 	}
-	return
 }
@@ -4,11 +4,10 @@ package svgicon
 
 import (
 	"errors"
+	"fmt"
 	"log"
 	"math"
 	"unicode"
-
-	"golang.org/x/image/math/fixed"
 )
 
 // ErrorMode is the for setting how the parser reacts to unparsed elements
@@ -30,17 +29,58 @@ var (
 	errZeroLengthID           = errors.New("zero length id")
 )
 
+// MaxCoordinate is the largest absolute value supported for
+// coordinates, which are stored as fixed.Int26_6 (that is, multiplied by 64 and
+// stored in an int32). Larger values, such as unprojected cartographic coordinates,
+// are rejected with an error when parsing, since they would silently wrap around.
+// Such files should be rescaled (for instance with a viewBox and smaller coordinates),
+// or parsed with ParseOptions.Scale.
+const MaxCoordinate = math.MaxInt32 / 64
+
+// checkCoordinates returns an error if one of the `values`
+// can't be represented as a fixed.Int26_6
+func checkCoordinates(values ...float64) error {
+	for _, v := range values {
+		if math.Abs(v) > MaxCoordinate || math.IsNaN(v) {
+			return fmt.Errorf("coordinate %g is out of the supported range [-%d, %d]", v, MaxCoordinate, MaxCoordinate)
+		}
+	}
+	return nil
+}
+
 // pathCursor is used to parse SVG format path strings into a Path
 type pathCursor struct {
 	path                   Path
 	placeX, placeY         float64
-	curX, curY             float64
 	cntlPtX, cntlPtY       float64
 	pathStartX, pathStartY float64
 	points                 []float64
 	lastKey                uint8
 	errorMode              ErrorMode
 	inPath                 bool
+	scale                  float64 // parse time scale factor, 0 for none (see ParseOptions.Scale)
+}
+
+// scaled applies the parse time scale factor to the length `v`.
+func (c *pathCursor) scaled(v float64) float64 {
+	if c.scale == 0 {
+		return v
+	}
+	return v * c.scale
+}
+
+// scalePoints applies the parse time scale factor to c.points.
+// For arcs, the rotation and the flags are not lengths, and are kept.
+func (c *pathCursor) scalePoints(isArc bool) {
+	if c.scale == 0 {
+		return
+	}
+	for i := range c.points {
+		if isArc && i%7 >= 2 && i%7 <= 4 {
+			continue
+		}
+		c.points[i] *= c.scale
+	}
 }
 
 func (c *pathCursor) init() {
@@ -54,13 +94,14 @@ func (c *pathCursor) init() {
 
 // compilePath translates the svgPath description string into a path.
 // The resulting path element is stored in the pathCursor.
+// In case of error, the path contains the segments before the invalid one.
 func (c *pathCursor) compilePath(svgPath string) error {
 	c.init()
 	lastIndex := -1
 	for i, v := range svgPath {
 		if unicode.IsLetter(v) && v != 'e' {
 			if lastIndex != -1 {
-				if err := c.addSeg(svgPath[lastIndex:i]); err != nil {
+				if err := c.addCheckedSeg(svgPath[lastIndex:i]); err != nil {
 					return err
 				}
 			}
@@ -68,13 +109,31 @@ func (c *pathCursor) compilePath(svgPath string) error {
 		}
 	}
 	if lastIndex != -1 {
-		if err := c.addSeg(svgPath[lastIndex:]); err != nil {
+		if err := c.addCheckedSeg(svgPath[lastIndex:]); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
+// addCheckedSeg adds the segment and checks its coordinates.
+// In case of error, the path is truncated to the previous segment.
+func (c *pathCursor) addCheckedSeg(segString string) error {
+	n := len(c.path)
+	err := c.addSeg(segString)
+	if err == nil {
+		// the coordinates of the segment are stored as absolute values in `points`
+		err = checkCoordinates(c.points...)
+	}
+	if err == nil {
+		err = checkCoordinates(c.placeX, c.placeY)
+	}
+	if err != nil {
+		c.path = c.path[:n]
+	}
+	return err
+}
+
 func reflect(px, py, rx, ry float64) (x, y float64) {
 	return px*2 - rx, py*2 - ry
 }
@@ -191,8 +250,9 @@ func (c *pathCursor) addSeg(segString string) error {
 	if err := c.getPoints(segString[1:]); err != nil {
 		return err
 	}
-	l := len(c.points)
 	k := segString[0]
+	c.scalePoints(k == 'a' || k == 'A')
+	l := len(c.points)
 	rel := false
 	switch k {
 	case 'z':
@@ -216,12 +276,9 @@ func (c *pathCursor) addSeg(segString string) error {
 		}
 		c.pathStartX, c.pathStartY = c.points[0], c.points[1]
 		c.inPath = true
-		c.path.Start(fixed.Point26_6{X: fixed.Int26_6((c.pathStartX + c.curX) * 64), Y: fixed.Int26_6((c.pathStartY + c.curY) * 64)})
+		c.path.Start(toFixedP(c.pathStartX, c.pathStartY))
 		for i := 2; i < l-1; i += 2 {
-			c.path.Line(fixed.Point26_6{
-				X: fixed.Int26_6((c.points[i] + c.curX) * 64),
-				Y: fixed.Int26_6((c.points[i+1] + c.curY) * 64),
-			})
+			c.path.Line(toFixedP(c.points[i], c.points[i+1]))
 		}
 		c.placeX = c.points[l-2]
 		c.placeY = c.points[l-1]
@@ -233,10 +290,7 @@ func (c *pathCursor) addSeg(segString string) error {
 			return errPathParamMismatch
 		}
 		for i := 0; i < l-1; i += 2 {
-			c.path.Line(fixed.Point26_6{
-				X: fixed.Int26_6((c.points[i] + c.curX) * 64),
-				Y: fixed.Int26_6((c.points[i+1] + c.curY) * 64),
-			})
+			c.path.Line(toFixedP(c.points[i], c.points[i+1]))
 		}
 		c.placeX = c.points[l-2]
 		c.placeY = c.points[l-1]
@@ -248,10 +302,7 @@ func (c *pathCursor) addSeg(segString string) error {
 			return errPathParamMismatch
 		}
 		for _, p := range c.points {
-			c.path.Line(fixed.Point26_6{
-				X: fixed.Int26_6((c.placeX + c.curX) * 64),
-				Y: fixed.Int26_6((p + c.curY) * 64),
-			})
+			c.path.Line(toFixedP(c.placeX, p))
 		}
 		c.placeY = c.points[l-1]
 	case 'h':
@@ -262,10 +313,7 @@ func (c *pathCursor) addSeg(segString string) error {
 			return errPathParamMismatch
 		}
 		for _, p := range c.points {
-			c.path.Line(fixed.Point26_6{
-				X: fixed.Int26_6((p + c.curX) * 64),
-				Y: fixed.Int26_6((c.placeY + c.curY) * 64),
-			})
+			c.path.Line(toFixedP(p, c.placeY))
 		}
 		c.placeX = c.points[l-1]
 	case 'q':
@@ -277,14 +325,8 @@ func (c *pathCursor) addSeg(segString string) error {
 		}
 		for i := 0; i < l-3; i += 4 {
 			c.path.QuadBezier(
-				fixed.Point26_6{
-					X: fixed.Int26_6((c.points[i] + c.curX) * 64),
-					Y: fixed.Int26_6((c.points[i+1] + c.curY) * 64),
-				},
-				fixed.Point26_6{
-					X: fixed.Int26_6((c.points[i+2] + c.curX) * 64),
-					Y: fixed.Int26_6((c.points[i+3] + c.curY) * 64),
-				})
+				toFixedP(c.points[i], c.points[i+1]),
+				toFixedP(c.points[i+2], c.points[i+3]))
 		}
 		c.cntlPtX, c.cntlPtY = c.points[l-4], c.points[l-3]
 		c.placeX = c.points[l-2]
@@ -299,14 +341,8 @@ func (c *pathCursor) addSeg(segString string) error {
 		for i := 0; i < l-1; i += 2 {
 			c.reflectControlQuad()
 			c.path.QuadBezier(
-				fixed.Point26_6{
-					X: fixed.Int26_6((c.cntlPtX + c.curX) * 64),
-					Y: fixed.Int26_6((c.cntlPtY + c.curY) * 64),
-				},
-				fixed.Point26_6{
-					X: fixed.Int26_6((c.points[i] + c.curX) * 64),
-					Y: fixed.Int26_6((c.points[i+1] + c.curY) * 64),
-				})
+				toFixedP(c.cntlPtX, c.cntlPtY),
+				toFixedP(c.points[i], c.points[i+1]))
 			c.lastKey = k
 			c.placeX = c.points[i]
 			c.placeY = c.points[i+1]
@@ -320,18 +356,9 @@ func (c *pathCursor) addSeg(segString string) error {
 		}
 		for i := 0; i < l-5; i += 6 {
 			c.path.CubeBezier(
-				fixed.Point26_6{
-					X: fixed.Int26_6((c.points[i] + c.curX) * 64),
-					Y: fixed.Int26_6((c.points[i+1] + c.curY) * 64),
-				},
-				fixed.Point26_6{
-					X: fixed.Int26_6((c.points[i+2] + c.curX) * 64),
-					Y: fixed.Int26_6((c.points[i+3] + c.curY) * 64),
-				},
-				fixed.Point26_6{
-					X: fixed.Int26_6((c.points[i+4] + c.curX) * 64),
-					Y: fixed.Int26_6((c.points[i+5] + c.curY) * 64),
-				})
+				toFixedP(c.points[i], c.points[i+1]),
+				toFixedP(c.points[i+2], c.points[i+3]),
+				toFixedP(c.points[i+4], c.points[i+5]))
 		}
 		c.cntlPtX, c.cntlPtY = c.points[l-4], c.points[l-3]
 		c.placeX = c.points[l-2]
@@ -345,15 +372,9 @@ func (c *pathCursor) addSeg(segString string) error {
 		}
 		for i := 0; i < l-3; i += 4 {
 			c.reflectControlCube()
-			c.path.CubeBezier(fixed.Point26_6{
-				X: fixed.Int26_6((c.cntlPtX + c.curX) * 64), Y: fixed.Int26_6((c.cntlPtY + c.curY) * 64),
-			},
-				fixed.Point26_6{
-					X: fixed.Int26_6((c.points[i] + c.curX) * 64), Y: fixed.Int26_6((c.points[i+1] + c.curY) * 64),
-				},
-				fixed.Point26_6{
-					X: fixed.Int26_6((c.points[i+2] + c.curX) * 64), Y: fixed.Int26_6((c.points[i+3] + c.curY) * 64),
-				})
+			c.path.CubeBezier(toFixedP(c.cntlPtX, c.cntlPtY),
+				toFixedP(c.points[i], c.points[i+1]),
+				toFixedP(c.points[i+2], c.points[i+3]))
 			c.lastKey = k
 			c.cntlPtX, c.cntlPtY = c.points[i], c.points[i+1]
 			c.placeX = c.points[i+2]
@@ -384,16 +405,21 @@ func (c *pathCursor) addSeg(segString string) error {
 }
 
 // ellipseAt adds a path of an elipse centered at cx, cy of radius rx and ry
-// to the pathCursor
+// to the pathCursor. As required for dashing, the path starts at (cx+rx, cy)
+// and goes in the positive angle direction (clockwise on screen).
 func (c *pathCursor) ellipseAt(cx, cy, rx, ry float64) {
 	c.placeX, c.placeY = cx+rx, cy
-	c.points = c.points[0:0]
-	c.points = append(c.points, rx, ry, 0.0, 1.0, 0.0, c.placeX, c.placeY)
-	c.path.Start(fixed.Point26_6{
-		X: fixed.Int26_6(c.placeX * 64),
-		Y: fixed.Int26_6(c.placeY * 64),
-	})
-	c.placeX, c.placeY = c.path.addArc(c.points, cx, cy, c.placeX, c.placeY)
+	c.path.Start(toFixedP(c.placeX, c.placeY))
+	// four quarter arcs, drawn clockwise, with the optimal cubic approximation
+	kx, ky := circleKappa*rx, circleKappa*ry
+	for _, q := range [4][6]float64{
+		{rx, ky, kx, ry, 0, ry},
+		{-kx, ry, -rx, ky, -rx, 0},
+		{-rx, -ky, -kx, -ry, 0, -ry},
+		{kx, -ry, rx, -ky, rx, 0},
+	} {
+		c.path.CubeBezier(toFixedP(cx+q[0], cy+q[1]), toFixedP(cx+q[2], cy+q[3]), toFixedP(cx+q[4], cy+q[5]))
+	}
 	c.path.Stop(true)
 }
 
@@ -401,5 +427,5 @@ func (c *pathCursor) ellipseAt(cx, cy, rx, ry float64) {
 func (c *pathCursor) addArcFromA(points []float64) {
 	cx, cy := findEllipseCenter(&points[0], &points[1], points[2]*math.Pi/180, c.placeX,
 		c.placeY, points[5], points[6], points[4] == 0, points[3] == 0)
-	c.placeX, c.placeY = c.path.addArc(c.points, cx+c.curX, cy+c.curY, c.placeX+c.curX, c.placeY+c.curY)
+	c.placeX, c.placeY = c.path.addArc(c.points, cx, cy, c.placeX, c.placeY)
 }
@@ -57,3 +57,20 @@ func TestReadFloat(t *testing.T) {
 	}
 
 }
+
+func TestFixedRounding(t *testing.T) {
+	c := new(pathCursor)
+	if err := c.compilePath("M 0.5 0.5 L 1.999 -2.7"); err != nil {
+		t.Fatal(err)
+	}
+	if p := c.path[0].(OpMoveTo); p.X != 32 || p.Y != 32 {
+		t.Errorf("expected exact half pixel, got %v", p)
+	}
+	// truncation would give 127 and -172
+	if p := c.path[1].(OpLineTo); p.X != 128 || p.Y != -173 {
+		t.Errorf("expected rounded coordinates, got %v", p)
+	}
+	if p := toFixedP(0.5, -0.5); float64(p.X)/64 != 0.5 || float64(p.Y)/64 != -0.5 {
+		t.Errorf("expected exact round trip, got %v", p)
+	}
+}
@@ -1,8 +1,19 @@
 package svgicon
 
 import (
+	"errors"
+	"fmt"
+	"image/color"
+	"io"
+	"io/fs"
+	"math"
+	"os"
 	"strings"
 	"testing"
+	"testing/fstest"
+
+	"github.com/benoitkugler/oksvg/internal/testutil"
+	"golang.org/x/image/math/fixed"
 )
 
 func parseIcon(t *testing.T, iconPath string) {
@@ -13,6 +24,7 @@ func parseIcon(t *testing.T, iconPath string) {
 }
 
 func TestLandscapeIcons(t *testing.T) {
+	testutil.SkipWithoutCharset(t)
 	for _, p := range []string{
 		"beach", "cape", "iceberg", "island",
 		"mountains", "sea", "trees", "village",
@@ -22,6 +34,7 @@ func TestLandscapeIcons(t *testing.T) {
 }
 
 func TestTestIcons(t *testing.T) {
+	testutil.SkipWithoutCharset(t)
 	for _, p := range []string{
 		"astronaut", "jupiter", "lander", "school-bus", "telescope", "content-cut-light", "defs",
 		"24px",
@@ -64,3 +77,1456 @@ func TestIssue3(t *testing.T) {
 		t.Fatal(errSvg)
 	}
 }
+
+func TestXlinkHref(t *testing.T) {
+	for _, src := range []string{
+		// namespace declared
+		`<svg xmlns="http://www.w3.org/2000/svg" xmlns:xlink="http://www.w3.org/1999/xlink" viewBox="0 0 10 10">
+		<defs><rect id="r" width="5" height="5"/></defs>
+		<use xlink:href="#r" xml:space="preserve"/>
+		</svg>`,
+		// undeclared prefix, as found in some old files
+		`<svg viewBox="0 0 10 10">
+		<defs><rect id="r" width="5" height="5"/></defs>
+		<use xlink:href="#r" xml:space="preserve"/>
+		</svg>`,
+		// SVG2 form
+		`<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 10 10">
+		<defs><rect id="r" width="5" height="5"/></defs>
+		<use href="#r"/>
+		</svg>`,
+	} {
+		icon, err := ReadIconStream(strings.NewReader(src), StrictErrorMode)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(icon.SVGPaths) != 1 {
+			t.Fatalf("expected one path from <use>, got %d", len(icon.SVGPaths))
+		}
+	}
+}
+
+func TestNamespacedStyleAttribute(t *testing.T) {
+	const src = `<svg xmlns="http://www.w3.org/2000/svg" xmlns:editor="http://example.com/editor" viewBox="0 0 10 10">
+	<rect width="5" height="5" fill="red" editor:fill="none"/>
+	</svg>`
+	icon, err := ReadIconStream(strings.NewReader(src), StrictErrorMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fill := icon.SVGPaths[0].Style.FillerColor; fill != NewPlainColor(0xff, 0, 0, 0xff) {
+		t.Errorf("namespaced attribute should be ignored, got fill %v", fill)
+	}
+}
+
+func TestNestedDefs(t *testing.T) {
+	const src = `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 100 100">
+	<defs>
+		<rect id="base" width="10" height="10"/>
+		<g id="d" fill="red" transform="translate(10 0)">
+			<g id="inner" fill="blue" transform="translate(0 20)">
+				<use href="#base" x="1"/>
+			</g>
+			<rect width="5" height="5"/>
+		</g>
+	</defs>
+	<use href="#d" y="30"/>
+	</svg>`
+	icon, err := ReadIconStream(strings.NewReader(src), StrictErrorMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(icon.SVGPaths) != 2 {
+		t.Fatalf("expected 2 paths, got %d", len(icon.SVGPaths))
+	}
+	var rec recorder
+	icon.Draw(&rec, 1)
+	for i, exp := range []struct {
+		path string
+		fill Pattern
+	}{
+		{"M11.000,50.000 L21.000,50.000 L21.000,60.000 L11.000,60.000 Z", NewPlainColor(0, 0, 0xff, 0xff)},
+		{"M10.000,30.000 L15.000,30.000 L15.000,35.000 L10.000,35.000 Z", NewPlainColor(0xff, 0, 0, 0xff)},
+	} {
+		if got := rec.fills[i].ToSVGPath(); got != exp.path {
+			t.Errorf("path %d: expected %s, got %s", i, exp.path, got)
+		}
+		if got := icon.SVGPaths[i].Style.FillerColor; got != exp.fill {
+			t.Errorf("path %d: expected fill %v, got %v", i, exp.fill, got)
+		}
+	}
+
+	// a nested element may also be used on its own,
+	// without the attributes of its ancestors
+	icon, err = ReadIconStream(strings.NewReader(strings.Replace(src, `href="#d"`, `href="#inner"`, 1)), StrictErrorMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec = recorder{}
+	icon.Draw(&rec, 1)
+	if len(rec.fills) != 1 {
+		t.Fatalf("expected 1 fill, got %d", len(rec.fills))
+	}
+	if got, exp := rec.fills[0].ToSVGPath(), "M1.000,50.000 L11.000,50.000 L11.000,60.000 L1.000,60.000 Z"; got != exp {
+		t.Errorf("expected %s, got %s", exp, got)
+	}
+	if got := icon.SVGPaths[0].Style.FillerColor; got != NewPlainColor(0, 0, 0xff, 0xff) {
+		t.Errorf("expected the fill of the inner group, got %v", got)
+	}
+}
+
+type errReader struct{}
+
+func (errReader) Read([]byte) (int, error) { return 0, errors.New("should not be read") }
+
+func TestDecodeConfig(t *testing.T) {
+	const head = `<?xml version="1.0" encoding="UTF-8"?>
+	<!-- comment -->
+	<svg xmlns="http://www.w3.org/2000/svg" width="24px" height="12" viewBox="0 1 48 24">`
+	// the content after the root element is never read
+	viewBox, width, height, err := DecodeConfig(io.MultiReader(strings.NewReader(head), errReader{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if viewBox != (Bounds{X: 0, Y: 1, W: 48, H: 24}) || width != "24px" || height != "12" {
+		t.Errorf("unexpected config %v %s %s", viewBox, width, height)
+	}
+
+	viewBox, _, _, err = DecodeConfig(strings.NewReader(`<svg width="20" height="10"><rect/></svg>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if viewBox != (Bounds{W: 20, H: 10}) {
+		t.Errorf("unexpected view box %v", viewBox)
+	}
+
+	if _, _, _, err = DecodeConfig(strings.NewReader("dummy")); err == nil {
+		t.Error("expected error on invalid input")
+	}
+}
+
+func TestGradientOpacity(t *testing.T) {
+	const src = `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 10 10">
+	<linearGradient id="g1" opacity="0.5">
+		<stop offset="0" stop-color="red"/>
+		<stop offset="1" stop-color="blue" stop-opacity="0.5"/>
+	</linearGradient>
+	<linearGradient id="g2">
+		<stop offset="0" stop-color="red"/>
+	</linearGradient>
+	</svg>`
+	icon, err := ReadIconStream(strings.NewReader(src), StrictErrorMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stops := icon.grads["g1"].Stops
+	if stops[0].Opacity != 0.5 || stops[1].Opacity != 0.25 {
+		t.Errorf("gradient opacity not applied to stops: %v", stops)
+	}
+	if op := icon.grads["g2"].Stops[0].Opacity; op != 1 {
+		t.Errorf("unexpected opacity %f", op)
+	}
+}
+
+func TestUseCircularReference(t *testing.T) {
+	const src = `<svg viewBox="0 0 10 10">
+	<defs><g id="a"><use href="#a"/></g></defs>
+	<use href="#a"/>
+	</svg>`
+	if _, err := ReadIconStream(strings.NewReader(src), IgnoreErrorMode); err == nil {
+		t.Error("expected error for circular reference")
+	}
+}
+
+func TestUseExpansionLimit(t *testing.T) {
+	var b strings.Builder
+	b.WriteString(`<svg viewBox="0 0 10 10"><defs><rect id="l0" width="1" height="1"/>`)
+	for i := 1; i < 30; i++ {
+		fmt.Fprintf(&b, `<g id="l%d"><use href="#l%d"/><use href="#l%d"/></g>`, i, i-1, i-1)
+	}
+	b.WriteString(`</defs><use href="#l29"/></svg>`)
+	if _, err := ReadIconStream(strings.NewReader(b.String()), IgnoreErrorMode); err == nil {
+		t.Error("expected error for exponential use expansion")
+	}
+}
+
+func TestInvalidColors(t *testing.T) {
+	for _, col := range []string{"", "#", "#12", "#1234", "rgb(,,)", "rgb(-5%, 300, 0)"} {
+		_, _ = parseSVGColor(col) // must not panic
+	}
+	if c, err := parseSVGColor("rgb(-5%, 300, 0)"); err != nil || c.color != NewPlainColor(0, 255, 0, 255) {
+		t.Errorf("expected clamped color, got %v %v", c, err)
+	}
+}
+
+func TestFunctionalColors(t *testing.T) {
+	for src, exp := range map[string]PlainColor{
+		"rgb(255, 0, 0)":              NewPlainColor(255, 0, 0, 255),
+		"RGB(100%, 50%, 0%)":          NewPlainColor(255, 128, 0, 255),
+		"rgba(0, 0, 255, 0.5)":        NewPlainColor(0, 0, 255, 128),
+		"rgb(255 0 0)":                NewPlainColor(255, 0, 0, 255),
+		"rgb(255 0 0 / 50%)":          NewPlainColor(255, 0, 0, 128),
+		"rgb(255 0 0/0.25)":           NewPlainColor(255, 0, 0, 64),
+		"rgba(10.4 20.6 30 / 1)":      NewPlainColor(10, 21, 30, 255),
+		"hsl(0, 100%, 50%)":           NewPlainColor(255, 0, 0, 255),
+		"hsl(120 100% 25%)":           NewPlainColor(0, 128, 0, 255),
+		"hsl(120 50% 50% / 0.5)":      NewPlainColor(64, 191, 64, 128),
+		"hsla(240deg, 100%, 50%, 0)":  NewPlainColor(0, 0, 255, 0),
+		"hsl(0.5turn 100% 50% / 20%)": NewPlainColor(0, 255, 255, 51),
+		"hsl(-120 100% 50%)":          NewPlainColor(0, 0, 255, 255),
+		"hsl(0, 0%, 50%)":             NewPlainColor(128, 128, 128, 255),
+		"hsl(210, 50%, 40%)":          NewPlainColor(51, 102, 153, 255),
+		"hsla(570, 50%, 40%, 0.5)":    NewPlainColor(51, 102, 153, 128),
+		" rgb( 12 , 34 , 56 ) ":       NewPlainColor(12, 34, 56, 255),
+		"rgba(12 34, 56 0.5)":         NewPlainColor(12, 34, 56, 128),
+	} {
+		c, err := parseSVGColor(src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c.color != exp {
+			t.Errorf("%s: expected %v, got %v", src, exp, c.color)
+		}
+	}
+	for _, src := range []string{"rgb(1 2)", "rgb(1 2 3", "hsl(a b c)", "rgb(1 2 3 / x)", "rgb(1,,2,3)", "rgb(1, 2, 3,)"} {
+		if _, err := parseSVGColor(src); err == nil {
+			t.Errorf("%s: expected an error", src)
+		}
+	}
+}
+
+func TestClassGradient(t *testing.T) {
+	const src = `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 10 10">
+	<style>.themed { fill: url('#grad') } .other { fill: red }</style>
+	<defs>
+		<linearGradient id="grad"><stop offset="0" stop-color="blue"/><stop offset="1" stop-color="green"/></linearGradient>
+	</defs>
+	<rect class="small themed" width="5" height="5"/>
+	<rect class="other" fill="blue" width="5" height="5"/>
+	<rect class="other" style="fill:green" width="5" height="5"/>
+	</svg>`
+	icon, err := ReadIconStream(strings.NewReader(src), StrictErrorMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(icon.SVGPaths) != 3 {
+		t.Fatalf("expected 3 paths, got %d", len(icon.SVGPaths))
+	}
+	grad, ok := icon.SVGPaths[0].Style.FillerColor.(Gradient)
+	if !ok {
+		t.Fatalf("expected gradient fill from class rule, got %v", icon.SVGPaths[0].Style.FillerColor)
+	}
+	if len(grad.Stops) != 2 {
+		t.Errorf("expected 2 stops, got %d", len(grad.Stops))
+	}
+	// the style sheet overrides attributes, and the style attribute overrides the style sheet
+	if fill := icon.SVGPaths[1].Style.FillerColor; fill != NewPlainColor(0xff, 0, 0, 0xff) {
+		t.Errorf("unexpected fill %v", fill)
+	}
+	if fill := icon.SVGPaths[2].Style.FillerColor; fill != NewPlainColor(0, 0x80, 0, 0xff) {
+		t.Errorf("unexpected fill %v", fill)
+	}
+}
+
+func TestCascadeOrder(t *testing.T) {
+	const src = `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 10 10">
+	<style>.c { fill: green; fill-opacity: 0.2 }</style>
+	<rect width="5" height="5" style="fill:red;fill:blue"/>
+	<rect width="5" height="5" fill="red" style="fill:blue"/>
+	<rect width="5" height="5" style="fill:blue" fill="red"/>
+	<rect width="5" height="5" class="c" fill="red" fill-opacity="0.8"/>
+	<rect width="5" height="5" class="c" style="fill:blue;fill-opacity:0.5"/>
+	<rect width="5" height="5" opacity="0.5" style="opacity:0.8;opacity:0.4"/>
+	<g opacity="0.5"><rect width="5" height="5" opacity="0.5"/></g>
+	</svg>`
+	icon, err := ReadIconStream(strings.NewReader(src), StrictErrorMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	blue, green := NewPlainColor(0, 0, 0xff, 0xff), NewPlainColor(0, 0x80, 0, 0xff)
+	for i, exp := range []struct {
+		fill    Pattern
+		opacity float64
+	}{
+		{blue, 1},    // last declaration wins
+		{blue, 1},    // style attribute over presentation attribute
+		{blue, 1},    // whatever the attribute order
+		{green, 0.2}, // style sheet over presentation attribute
+		{blue, 0.5},  // style attribute over style sheet
+		{nil, 0.4},   // opacities are not multiplied within one element...
+		{nil, 0.25},  // ...but are composed with the parent
+	} {
+		style := icon.SVGPaths[i].Style
+		if exp.fill != nil && style.FillerColor != exp.fill {
+			t.Errorf("path %d: expected fill %v, got %v", i, exp.fill, style.FillerColor)
+		}
+		if math.Abs(style.FillOpacity-exp.opacity) > 1e-9 {
+			t.Errorf("path %d: expected opacity %g, got %g", i, exp.opacity, style.FillOpacity)
+		}
+	}
+}
+
+func TestStyleCDATAAndScript(t *testing.T) {
+	const src = `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 10 10">
+	<style type="text/css"><![CDATA[
+		/* a comment, with a fake rule: .red { fill: blue } */
+		.red { /* inside */ fill: red } /* unused */
+		<!-- .blue { fill: blue } -->
+	]]></style>
+	<script type="text/javascript"><![CDATA[
+		if (1 < 2) { document.write("<rect/>"); }
+	]]></script>
+	<script><g><rect width="5" height="5"/></g></script>
+	<rect class="red" width="5" height="5"/>
+	<rect class="blue" width="5" height="5"/>
+	</svg>`
+	icon, err := ReadIconStream(strings.NewReader(src), StrictErrorMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(icon.SVGPaths) != 2 {
+		t.Fatalf("expected 2 paths, got %d", len(icon.SVGPaths))
+	}
+	if fill := icon.SVGPaths[0].Style.FillerColor; fill != NewPlainColor(0xff, 0, 0, 0xff) {
+		t.Errorf("unexpected fill %v", fill)
+	}
+	if fill := icon.SVGPaths[1].Style.FillerColor; fill != NewPlainColor(0, 0, 0xff, 0xff) {
+		t.Errorf("unexpected fill %v", fill)
+	}
+}
+
+func TestCSSTransformOrigin(t *testing.T) {
+	const tmpl = `<svg viewBox="0 0 100 50"><rect width="10" height="10" %s/></svg>`
+	for _, attrs := range []string{
+		`style="transform: rotate(90deg); transform-origin: 50% 50%"`,
+		`style="transform: rotate(0.25turn); transform-origin: center"`,
+		`style="transform: rotate(90, 50%, 50%)"`,
+		`transform="rotate(90)" style="transform-origin: 50% 50%"`,
+		`transform="rotate(90 50 25)"`,
+	} {
+		icon, err := ReadIconStream(strings.NewReader(fmt.Sprintf(tmpl, attrs)), StrictErrorMode)
+		if err != nil {
+			t.Fatal(err)
+		}
+		m := icon.SVGPaths[0].Style.transform
+		// the center of the viewport is invariant, and (50, 0) is rotated to (75, 25)
+		if x, y := m.Transform(50, 25); math.Abs(x-50) > 1e-9 || math.Abs(y-25) > 1e-9 {
+			t.Errorf("%s: unexpected center (%g, %g)", attrs, x, y)
+		}
+		if x, y := m.Transform(50, 0); math.Abs(x-75) > 1e-9 || math.Abs(y-25) > 1e-9 {
+			t.Errorf("%s: unexpected point (%g, %g)", attrs, x, y)
+		}
+	}
+
+	// the presentation attribute is strictly numeric
+	_, err := ReadIconStream(strings.NewReader(fmt.Sprintf(tmpl, `transform="rotate(90 50% 50%)"`)), StrictErrorMode)
+	if err == nil {
+		t.Error("expected an error for percentages in the transform attribute")
+	}
+}
+
+func TestTransformBox(t *testing.T) {
+	const tmpl = `<svg viewBox="0 0 100 50"><rect x="20" y="10" width="10" height="20" stroke="red" stroke-width="4" style="transform: rotate(90deg); %s"/></svg>`
+	for _, test := range []struct {
+		style  string
+		origin [2]float64 // invariant point
+	}{
+		{"transform-origin: center", [2]float64{50, 25}},
+		{"transform-origin: center; transform-box: view-box", [2]float64{50, 25}},
+		{"transform-origin: center; transform-box: fill-box", [2]float64{25, 20}},
+		{"transform-origin: 0 0; transform-box: fill-box", [2]float64{20, 10}},
+		{"transform-origin: 5px 100%; transform-box: fill-box", [2]float64{25, 30}},
+		{"transform-origin: 0 0; transform-box: border-box", [2]float64{18, 8}},
+		{"transform-origin: 0 0; transform-box: view-box", [2]float64{0, 0}},
+	} {
+		icon, err := ReadIconStream(strings.NewReader(fmt.Sprintf(tmpl, test.style)), StrictErrorMode)
+		if err != nil {
+			t.Fatal(err)
+		}
+		m := icon.SVGPaths[0].Style.transform
+		if x, y := m.Transform(test.origin[0], test.origin[1]); math.Abs(x-test.origin[0]) > 1e-9 || math.Abs(y-test.origin[1]) > 1e-9 {
+			t.Errorf("%s: expected %v to be invariant, got (%g, %g)", test.style, test.origin, x, y)
+		}
+	}
+
+	// computing the fill box of the line must not change the style of its siblings
+	const siblings = `<svg viewBox="0 0 100 50"><g fill="red">
+		<line x1="0" y1="0" x2="10" y2="10" stroke="blue" style="transform: rotate(90deg); transform-origin: center; transform-box: fill-box"/>
+		<rect width="10" height="10"/>
+	</g></svg>`
+	icon, err := ReadIconStream(strings.NewReader(siblings), StrictErrorMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(icon.SVGPaths) != 2 {
+		t.Fatalf("expected 2 paths, got %d", len(icon.SVGPaths))
+	}
+	if fill := icon.SVGPaths[0].Style.FillerColor; fill != nil {
+		t.Errorf("expected the line not to be filled, got %v", fill)
+	}
+	if fill := icon.SVGPaths[1].Style.FillerColor; fill != NewPlainColor(0xff, 0, 0, 0xff) {
+		t.Errorf("expected the rect to keep its fill, got %v", fill)
+	}
+	if x, y := icon.SVGPaths[0].Style.transform.Transform(5, 5); math.Abs(x-5) > 1e-9 || math.Abs(y-5) > 1e-9 {
+		t.Errorf("expected the line center to be invariant, got (%g, %g)", x, y)
+	}
+}
+
+func TestInvalidMiterLimit(t *testing.T) {
+	const src = `<svg viewBox="0 0 10 10"><path d="M0,0 L5,5" stroke-miterlimit="0.5"/></svg>`
+	if _, err := ReadIconStream(strings.NewReader(src), StrictErrorMode); err == nil {
+		t.Error("expected an error for a miter limit smaller than 1")
+	}
+	icon, err := ReadIconStream(strings.NewReader(src), IgnoreErrorMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := icon.SVGPaths[0].Style.Join.MiterLimit; got != DefaultStyle.Join.MiterLimit {
+		t.Errorf("expected default miter limit, got %v", got)
+	}
+}
+
+func TestCharsetReader(t *testing.T) {
+	// "é" encoded in ISO-8859-1
+	const src = "<?xml version=\"1.0\" encoding=\"ISO-8859-1\"?>\n" +
+		"<svg viewBox=\"0 0 10 10\"><title>caf\xe9</title></svg>"
+
+	if DefaultCharsetReader != nil {
+		icon, err := ReadIconStream(strings.NewReader(src), StrictErrorMode)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(icon.Titles) != 1 || icon.Titles[0] != "café" {
+			t.Errorf("unexpected titles %v", icon.Titles)
+		}
+	}
+
+	// UTF-8 only
+	if _, err := ReadIconStreamOptions(strings.NewReader(src), ParseOptions{}); err == nil {
+		t.Error("expected an error for an unsupported charset")
+	}
+
+	var labels []string
+	_, err := ReadIconStreamOptions(strings.NewReader(src), ParseOptions{
+		CharsetReader: func(label string, input io.Reader) (io.Reader, error) {
+			labels = append(labels, label)
+			latin1, err := io.ReadAll(input)
+			if err != nil {
+				return nil, err
+			}
+			var utf8 strings.Builder
+			for _, b := range latin1 {
+				utf8.WriteRune(rune(b))
+			}
+			return strings.NewReader(utf8.String()), nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(labels) != 1 || labels[0] != "ISO-8859-1" {
+		t.Errorf("custom charset reader not used: %v", labels)
+	}
+}
+
+func TestLink(t *testing.T) {
+	const src = `<svg xmlns="http://www.w3.org/2000/svg" xmlns:xlink="http://www.w3.org/1999/xlink" viewBox="0 0 10 10">
+	<a href="https://example.com/a" fill="red">
+		<rect width="5" height="5"/>
+		<g><circle r="2"/></g>
+		<a xlink:href="#inner"><rect width="2" height="2"/></a>
+	</a>
+	<rect width="5" height="5"/>
+	</svg>`
+	icon, err := ReadIconStream(strings.NewReader(src), StrictErrorMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(icon.SVGPaths) != 4 {
+		t.Fatalf("expected 4 paths, got %d", len(icon.SVGPaths))
+	}
+	for i, exp := range []string{"https://example.com/a", "https://example.com/a", "#inner", ""} {
+		if got := icon.SVGPaths[i].Link; got != exp {
+			t.Errorf("path %d: expected link %q, got %q", i, exp, got)
+		}
+	}
+	// <a> behaves as <g>
+	if fill := icon.SVGPaths[0].Style.FillerColor; fill != NewPlainColor(0xff, 0, 0, 0xff) {
+		t.Errorf("unexpected fill %v", fill)
+	}
+}
+
+func TestDump(t *testing.T) {
+	const src = `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 10 10" width="20">
+	<linearGradient id="g" x2="0.5" spreadMethod="reflect">
+		<stop offset="0" stop-color="red"/><stop offset="1" stop-color="blue" stop-opacity="0.5"/>
+	</linearGradient>
+	<rect width="5" height="5" fill="url(#g)" stroke="green" transform="translate(1 2)"/>
+	<a href="#top"><path d="M1 1 L2 2" fill="none" stroke="blue" stroke-dasharray="1 2"/></a>
+	</svg>`
+	icon, err := ReadIconStream(strings.NewReader(src), StrictErrorMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const exp = `viewBox: 0 0 10 10 (width: "20", height: "")
+gradient #g: linear [0 0 0.5 0], units: objectBoundingBox, spread: reflect, stops: 0:#ff0000ff 1:#0000ffff(opacity 0.5)
+2 path(s)
+  0: fill: linear gradient (opacity 1), stroke: #008000ff (opacity 1, width 2), transform: matrix(1 0 0 1 1 2)
+     M0.000,0.000 L5.000,0.000 L5.000,5.000 L0.000,5.000 Z
+  1: fill: none (opacity 1), stroke: #0000ffff (opacity 1, width 2), dash: [1 2] (offset 0), link: #top
+     M1.000,1.000 L2.000,2.000
+`
+	if got := icon.String(); got != exp {
+		t.Errorf("unexpected dump:\n%s\nexpected:\n%s", got, exp)
+	}
+}
+
+func TestPercentageStrokeWidth(t *testing.T) {
+	const tmpl = `<svg viewBox="0 0 %s"><path d="M0,0 L5,5" stroke="red" stroke-width="2%%" stroke-dasharray="1%%, 5%%" stroke-dashoffset="10%%"/></svg>`
+	for viewBox, diag := range map[string]float64{
+		"100 100": 100,
+		"300 400": 500 / math.Sqrt2,
+		"200 0":   200 / math.Sqrt2,
+	} {
+		icon, err := ReadIconStream(strings.NewReader(fmt.Sprintf(tmpl, viewBox)), StrictErrorMode)
+		if err != nil {
+			t.Fatal(err)
+		}
+		style := icon.SVGPaths[0].Style
+		if !almostEqual(style.LineWidth, diag*0.02) {
+			t.Errorf("%s: expected width %g, got %g", viewBox, diag*0.02, style.LineWidth)
+		}
+		if len(style.Dash.Dash) != 2 || !almostEqual(style.Dash.Dash[0], diag*0.01) || !almostEqual(style.Dash.Dash[1], diag*0.05) {
+			t.Errorf("%s: unexpected dashes %v", viewBox, style.Dash.Dash)
+		}
+		if !almostEqual(style.Dash.DashOffset, diag*0.1) {
+			t.Errorf("%s: unexpected dash offset %g", viewBox, style.Dash.DashOffset)
+		}
+	}
+}
+
+func TestDefsGradientOrder(t *testing.T) {
+	const gradient = `<linearGradient id="g"><stop offset="0" stop-color="red"/></linearGradient>`
+	const shape = `<rect id="r" width="5" height="5" fill="url(#g)"/>`
+	for _, defs := range []string{
+		gradient + shape,
+		shape + gradient,
+		`<g id="r"><rect width="5" height="5" fill="url(#g)"/>` + gradient + `</g>`,
+	} {
+		src := `<svg viewBox="0 0 10 10"><defs>` + defs + `</defs><use href="#r"/></svg>`
+		icon, err := ReadIconStream(strings.NewReader(src), StrictErrorMode)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(icon.SVGPaths) != 1 {
+			t.Fatalf("expected one path, got %d", len(icon.SVGPaths))
+		}
+		grad, ok := icon.SVGPaths[0].Style.FillerColor.(Gradient)
+		if !ok || len(grad.Stops) != 1 {
+			t.Errorf("%s: expected the gradient fill, got %v", defs, icon.SVGPaths[0].Style.FillerColor)
+		}
+		if issues := icon.Validate(); len(issues) != 0 {
+			t.Errorf("%s: unexpected issues %v", defs, issues)
+		}
+	}
+}
+
+func TestFilter(t *testing.T) {
+	const src = `<svg viewBox="0 0 10 10">
+	<filter id="shadow">
+		<feGaussianBlur in="SourceAlpha" stdDeviation="2 1" result="blur"/>
+		<feOffset in="blur" dx="1" dy="2" result="offsetBlur"/>
+		<feMerge><feMergeNode in="offsetBlur"/><feMergeNode in="SourceGraphic"/></feMerge>
+	</filter>
+	<filter id="flood"><feFlood flood-color="red"/></filter>
+	<rect width="5" height="5" filter="url(#shadow)"/>
+	<rect width="5" height="5" style="filter: url(#flood)"/>
+	<rect width="5" height="5"/>
+	</svg>`
+	icon, err := ReadIconStream(strings.NewReader(src), IgnoreErrorMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(icon.SVGPaths) != 3 {
+		t.Fatalf("expected 3 paths, got %d", len(icon.SVGPaths))
+	}
+	expected := Filter{Primitives: []FilterPrimitive{
+		FeGaussianBlur{In: "SourceAlpha", Result: "blur", StdDeviationX: 2, StdDeviationY: 1},
+		FeOffset{In: "blur", Result: "offsetBlur", Dx: 1, Dy: 2},
+		FeMerge{Nodes: []string{"offsetBlur", "SourceGraphic"}},
+	}}
+	if filter := icon.SVGPaths[0].Style.Filter; filter == nil || fmt.Sprint(*filter) != fmt.Sprint(expected) {
+		t.Errorf("expected %v, got %v", expected, filter)
+	}
+	if filter := icon.SVGPaths[1].Style.Filter; filter != nil {
+		t.Errorf("expected unsupported filter to be ignored, got %v", filter)
+	}
+	if filter := icon.SVGPaths[2].Style.Filter; filter != nil {
+		t.Errorf("unexpected filter %v", filter)
+	}
+
+	// a filter may be used before its definition
+	icon, err = ReadIconStream(strings.NewReader(`<svg viewBox="0 0 10 10">
+	<rect width="5" height="5" filter="url(#later)"/>
+	<rect width="5" height="5" filter="url(#missing)"/>
+	<filter id="later"><feOffset dx="1" dy="2"/></filter>
+	</svg>`), StrictErrorMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filter := icon.SVGPaths[0].Style.Filter; filter == nil || len(filter.Primitives) != 1 {
+		t.Errorf("expected the filter defined later, got %v", filter)
+	}
+	if filter := icon.SVGPaths[1].Style.Filter; filter != nil {
+		t.Errorf("expected missing filter to be ignored, got %v", filter)
+	}
+
+	scaled := expected.transform(Identity.Scale(2, 3))
+	if blur := scaled.Primitives[0].(FeGaussianBlur); blur.StdDeviationX != 4 || blur.StdDeviationY != 3 {
+		t.Errorf("unexpected transformed blur %v", blur)
+	}
+	if off := scaled.Primitives[1].(FeOffset); off.Dx != 2 || off.Dy != 6 {
+		t.Errorf("unexpected transformed offset %v", off)
+	}
+}
+
+func TestRawTree(t *testing.T) {
+	const src = `<svg viewBox="0 0 10 10">
+	<text x="1" y="2">Hello <tspan>World</tspan></text>
+	<script>var a = "<rect/>";</script>
+	<rect width="5" height="5"/>
+	</svg>`
+	icon, err := ReadIconStream(strings.NewReader(src), IgnoreErrorMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if icon.Raw != nil {
+		t.Error("raw tree should only be kept on demand")
+	}
+
+	icon, err = ReadIconStreamOptions(strings.NewReader(src), ParseOptions{KeepRawTree: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := icon.Raw
+	if root == nil || root.Tag != "svg" || len(root.Children) != 3 {
+		t.Fatalf("unexpected root %v", root)
+	}
+	text := root.Children[0]
+	if text.Tag != "text" || len(text.Attrs) != 2 || text.CharData != "Hello " {
+		t.Errorf("unexpected text node %v", text)
+	}
+	if len(text.Children) != 1 || text.Children[0].Tag != "tspan" || text.Children[0].CharData != "World" {
+		t.Errorf("unexpected tspan node %v", text.Children)
+	}
+	if root.Children[1].Tag != "script" || len(root.Children[1].Children) != 0 {
+		t.Errorf("unexpected script node %v", root.Children[1])
+	}
+	if root.Children[2].Tag != "rect" {
+		t.Errorf("unexpected rect node %v", root.Children[2])
+	}
+	if len(icon.SVGPaths) != 2 || icon.SVGPaths[0].Text == nil {
+		t.Errorf("expected a text and a rectangle, got %v", icon.SVGPaths)
+	}
+}
+
+func TestCoordinatesOverflow(t *testing.T) {
+	for _, shape := range []string{
+		`<path d="M 0 0 L 40000000 0"/>`,
+		`<path d="M 30000000 0 l 30000000 0"/>`, // relative coordinates
+		`<path d="M 0 0 C 0 0 -1e9 0 10 10"/>`,  // control point
+		`<rect x="1e8" width="10" height="10"/>`,
+		`<circle cx="10" cy="10" r="5e7"/>`,
+		`<line x2="-5e7" y2="10"/>`,
+		`<polygon points="0 0 10 0 1e10 10"/>`,
+	} {
+		src := `<svg viewBox="0 0 10 10">` + shape + `</svg>`
+		_, err := ReadIconStream(strings.NewReader(src), StrictErrorMode)
+		if err == nil || !strings.Contains(err.Error(), "out of the supported range") {
+			t.Errorf("%s: expected an out of range error, got %v", shape, err)
+		}
+	}
+
+	// in the other modes, only the invalid shapes are skipped
+	src := `<svg viewBox="0 0 10 10"><rect x="1e8" width="10" height="10"/><polygon points="0 0 10 0 1e10 10"/>
+		<line x2="-5e7" y2="10"/><rect width="10" height="10"/></svg>`
+	icon, err := ReadIconStream(strings.NewReader(src), IgnoreErrorMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(icon.SVGPaths) != 1 {
+		t.Errorf("expected only the valid rectangle, got %v", icon.SVGPaths)
+	}
+
+	src = `<svg viewBox="0 0 10 10"><path d="M 0 0 L 30000000 -30000000"/></svg>`
+	icon, err = ReadIconStream(strings.NewReader(src), IgnoreErrorMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if line := icon.SVGPaths[0].Path[1].(OpLineTo); line.X != 30000000*64 || line.Y != -30000000*64 {
+		t.Errorf("unexpected line %v", line)
+	}
+}
+
+func TestTransformArgsSeparators(t *testing.T) {
+	rotate := Identity.Translate(50, 50).Rotate(math.Pi/4).Translate(-50, -50)
+	for _, test := range []struct {
+		transform string
+		expected  Matrix2D
+	}{
+		{"rotate(45,50,50)", rotate},
+		{"rotate(45 50 50)", rotate},
+		{"rotate( 45 , 50 , 50 )", rotate},
+		{"rotate(45, 50 50)", rotate},
+		{"scale(2,3)", Identity.Scale(2, 3)},
+		{"scale(2 3)", Identity.Scale(2, 3)},
+		{"scale(2, 3)", Identity.Scale(2, 3)},
+		{"translate(10,-20)", Identity.Translate(10, -20)},
+		{"translate(10-20)", Identity.Translate(10, -20)},
+		{"matrix(1,0,0,1,5,6)", Identity.Translate(5, 6)},
+		{"translate(5,6),scale(2,3)", Identity.Translate(5, 6).Scale(2, 3)},
+	} {
+		c := iconCursor{styleStack: []PathStyle{DefaultStyle}}
+		m, err := c.parseTransform(test.transform)
+		if err != nil {
+			t.Errorf("%s: %s", test.transform, err)
+			continue
+		}
+		if !matrixAlmostEqual(m, test.expected) {
+			t.Errorf("%s: expected %v, got %v", test.transform, test.expected, m)
+		}
+	}
+}
+
+func TestTransformSingleArgument(t *testing.T) {
+	for _, test := range []struct {
+		transform string
+		expected  Matrix2D
+	}{
+		{"translate(5)", Matrix2D{A: 1, D: 1, E: 5}},
+		{"translate(-5)", Matrix2D{A: 1, D: 1, E: -5}},
+		{"scale(2)", Matrix2D{A: 2, D: 2}},
+		{"scale(-0.5)", Matrix2D{A: -0.5, D: -0.5}},
+		{"rotate(90)", Matrix2D{B: 1, C: -1}},
+		{"rotate(180)", Matrix2D{A: -1, D: -1}},
+		{"skewX(45)", Matrix2D{A: 1, C: 1, D: 1}},
+		{"skewY(45)", Matrix2D{A: 1, B: 1, D: 1}},
+		{"scale(2) translate(5)", Matrix2D{A: 2, D: 2, E: 10}},
+	} {
+		c := iconCursor{styleStack: []PathStyle{DefaultStyle}, icon: &SvgIcon{}}
+		m, err := c.parseTransform(test.transform)
+		if err != nil {
+			t.Errorf("%s: %s", test.transform, err)
+			continue
+		}
+		if !matrixAlmostEqual(m, test.expected) {
+			t.Errorf("%s: expected %v, got %v", test.transform, test.expected, m)
+		}
+		// the CSS syntax has the same defaults
+		m, err = c.parseTransformList(Identity, test.transform, c.getCSSTransformArgs)
+		if err != nil {
+			t.Errorf("%s: %s", test.transform, err)
+			continue
+		}
+		if !matrixAlmostEqual(m, test.expected) {
+			t.Errorf("CSS %s: expected %v, got %v", test.transform, test.expected, m)
+		}
+	}
+}
+
+func matrixAlmostEqual(m1, m2 Matrix2D) bool {
+	return almostEqual(m1.A, m2.A) && almostEqual(m1.B, m2.B) && almostEqual(m1.C, m2.C) &&
+		almostEqual(m1.D, m2.D) && almostEqual(m1.E, m2.E) && almostEqual(m1.F, m2.F)
+}
+
+func TestInvalidPathRecovery(t *testing.T) {
+	const src = `<svg viewBox="0 0 10 10">
+	<path d="M 0 0 L 5 5"/>
+	<path d="M 1 1 L 2 2 L 3 Z"/>
+	<path d="M 0 0 L 40000000 0"/>
+	<rect width="5" height="5"/>
+	</svg>`
+	if _, err := ReadIconStream(strings.NewReader(src), StrictErrorMode); err == nil {
+		t.Fatal("expected an error in strict mode")
+	}
+
+	for _, mode := range []ErrorMode{IgnoreErrorMode, WarnErrorMode} {
+		icon, err := ReadIconStream(strings.NewReader(src), mode)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(icon.SVGPaths) != 4 {
+			t.Fatalf("expected 4 paths, got %d", len(icon.SVGPaths))
+		}
+		if s := icon.SVGPaths[1].Path.ToSVGPath(); s != "M1.000,1.000 L2.000,2.000" {
+			t.Errorf("expected truncated path, got %s", s)
+		}
+		if s := icon.SVGPaths[2].Path.ToSVGPath(); s != "M0.000,0.000" {
+			t.Errorf("expected truncated path, got %s", s)
+		}
+	}
+}
+
+func TestForeignObject(t *testing.T) {
+	const src = `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 100 100">
+	<rect width="5" height="5"/>
+	<foreignObject x="10" y="20" width="50%" height="30" transform="translate(5 5)">
+		<div xmlns="http://www.w3.org/1999/xhtml">
+			<p>Some <b>HTML</b></p>
+			<svg><rect width="50" height="50"/></svg>
+		</div>
+	</foreignObject>
+	<defs>
+		<g id="g">
+			<foreignObject width="10" height="10"><div><rect width="5" height="5"/></div></foreignObject>
+			<circle r="5"/>
+		</g>
+	</defs>
+	<use href="#g"/>
+	<rect width="5" height="5"/>
+	</svg>`
+	icon, err := ReadIconStream(strings.NewReader(src), StrictErrorMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(icon.SVGPaths) != 3 {
+		t.Errorf("expected 3 paths, got %d", len(icon.SVGPaths))
+	}
+	if len(icon.ForeignObjects) != 2 {
+		t.Fatalf("expected 2 foreign objects, got %d", len(icon.ForeignObjects))
+	}
+	fo := icon.ForeignObjects[0]
+	if fo.Bounds != (Bounds{X: 10, Y: 20, W: 50, H: 30}) || fo.Transform != Identity.Translate(5, 5) {
+		t.Errorf("unexpected foreign object %v", fo)
+	}
+	if fo := icon.ForeignObjects[1]; fo.Bounds != (Bounds{W: 10, H: 10}) {
+		t.Errorf("unexpected foreign object %v", fo)
+	}
+	if issues := icon.Validate(); len(issues) != 0 {
+		t.Errorf("unexpected issues %v", issues)
+	}
+}
+
+func TestSkipUnsupportedContainer(t *testing.T) {
+	const src = `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 10 10">
+	<mask id="m"><rect width="10" height="10" fill="white"/><g><circle r="2"/></g></mask>
+	<clipPath id="c"><rect width="5" height="5"/></clipPath>
+	<defs><g id="g"><marker><path d="M0 0 L 1 1"/></marker><rect width="1" height="1"/></g></defs>
+	<use href="#g"/>
+	<switch><g><rect width="2" height="2"/></g></switch>
+	<rect width="5" height="5"/>
+	</svg>`
+	if _, err := ReadIconStream(strings.NewReader(src), StrictErrorMode); err == nil {
+		t.Fatal("expected an error in strict mode")
+	}
+	icon, err := ReadIconStream(strings.NewReader(src), IgnoreErrorMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(icon.SVGPaths) != 3 { // the rectangles from <use>, <switch> and the last one
+		t.Errorf("expected 3 paths, got %d", len(icon.SVGPaths))
+	}
+	// mask, clipPath and marker, but not their content
+	if issues := icon.Validate(); len(issues) != 3 {
+		t.Errorf("expected 3 unsupported elements, got %v", issues)
+	}
+}
+
+func TestSwitch(t *testing.T) {
+	const src = `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 10 10">
+	<defs>
+		<switch id="s">
+			<rect width="1" height="1" requiredExtensions="http://example.org/ext"/>
+			<rect width="2" height="2"/>
+			<rect width="3" height="3"/>
+		</switch>
+	</defs>
+	<switch>
+		<desc>Conditional content</desc>
+		<foreignObject width="4" height="4" requiredExtensions="http://www.w3.org/1999/xhtml"><p xmlns="http://www.w3.org/1999/xhtml">Text</p></foreignObject>
+		<g systemLanguage="fr"><rect width="5" height="5"/><rect width="6" height="6"/></g>
+		<rect width="7" height="7"/>
+	</switch>
+	<use href="#s"/>
+	</svg>`
+	icon, err := ReadIconStream(strings.NewReader(src), StrictErrorMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(icon.ForeignObjects) != 0 {
+		t.Errorf("unexpected foreign objects %v", icon.ForeignObjects)
+	}
+	if len(icon.SVGPaths) != 3 {
+		t.Fatalf("expected 3 paths, got %d", len(icon.SVGPaths))
+	}
+	for i, width := range [3]float64{5, 6, 2} {
+		if b, _ := icon.SVGPaths[i].Path.bounds(Identity); b.W != width {
+			t.Errorf("path %d: expected width %g, got %g", i, width, b.W)
+		}
+	}
+}
+
+func TestClipPathShapeGroup(t *testing.T) {
+	const src = `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 100 100">
+	<g clip-path="inset(10%)" transform="translate(5 5)">
+		<rect width="50" height="50"/>
+		<rect x="50" y="50" width="50" height="50"/>
+	</g>
+	<rect width="10" height="10" clip-path="url(#unknown)"/>
+	</svg>`
+	icon, err := ReadIconStream(strings.NewReader(src), StrictErrorMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(icon.SVGPaths) != 3 {
+		t.Fatalf("expected 3 paths, got %d", len(icon.SVGPaths))
+	}
+	// the reference box is the bounding box of the group
+	for _, svgp := range icon.SVGPaths[:2] {
+		if len(svgp.Style.clips) != 1 {
+			t.Fatalf("expected one clip, got %d", len(svgp.Style.clips))
+		}
+		box, _ := svgp.Style.clips[0].bounds(Identity)
+		if exp := (Bounds{X: 15, Y: 15, W: 80, H: 80}); box != exp {
+			t.Errorf("expected clip %v, got %v", exp, box)
+		}
+	}
+	if len(icon.SVGPaths[2].Style.clips) != 0 {
+		t.Errorf("unexpected clip for url() reference")
+	}
+
+	_, err = ReadIconStream(strings.NewReader(`<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 10 10">
+	<rect width="10" height="10" clip-path="ellipse(5px 2px)"/></svg>`), StrictErrorMode)
+	if err == nil {
+		t.Error("expected error for unsupported shape")
+	}
+}
+
+func TestStyleInDefs(t *testing.T) {
+	const src = `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 10 10">
+	<defs>
+		<style>.red { fill: red } .blue { fill: blue }</style>
+		<rect id="r" class="blue" width="5" height="5"/>
+	</defs>
+	<rect class="red" width="5" height="5"/>
+	<use href="#r"/>
+	</svg>`
+	icon, err := ReadIconStream(strings.NewReader(src), StrictErrorMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(icon.SVGPaths) != 2 {
+		t.Fatalf("expected 2 paths, got %d", len(icon.SVGPaths))
+	}
+	if fill := icon.SVGPaths[0].Style.FillerColor; fill != NewPlainColor(0xff, 0, 0, 0xff) {
+		t.Errorf("unexpected fill %v", fill)
+	}
+	if fill := icon.SVGPaths[1].Style.FillerColor; fill != NewPlainColor(0, 0, 0xff, 0xff) {
+		t.Errorf("unexpected fill %v", fill)
+	}
+}
+
+func TestOpacityRecovery(t *testing.T) {
+	const src = `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 10 10">
+	<rect width="5" height="5" opacity="1.5"/>
+	<rect width="5" height="5" opacity="abc" fill-opacity="0.5"/>
+	<rect width="5" height="5" style="stroke-opacity: -2; fill-opacity: 50%"/>
+	</svg>`
+	icon, err := ReadIconStream(strings.NewReader(src), WarnErrorMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, exp := range [][2]float64{{1, 1}, {0.5, 1}, {0.5, 0}} {
+		style := icon.SVGPaths[i].Style
+		if style.FillOpacity != exp[0] || style.LineOpacity != exp[1] {
+			t.Errorf("path %d: expected opacities %v, got %g and %g", i, exp, style.FillOpacity, style.LineOpacity)
+		}
+	}
+	// the clamped values are recorded
+	if exp := []clampedValue{{"opacity", 1.5}, {"stroke-opacity", -2}}; fmt.Sprint(icon.clamped) != fmt.Sprint(exp) {
+		t.Errorf("expected clamped values %v, got %v", exp, icon.clamped)
+	}
+
+	if _, err = ReadIconStream(strings.NewReader(src), StrictErrorMode); err == nil {
+		t.Error("expected error in strict mode")
+	}
+}
+
+func TestApplyTheme(t *testing.T) {
+	const src = `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 10 10">
+	<rect class="primary" width="5" height="5" fill="#000000"/>
+	<g class="secondary">
+		<rect width="5" height="5" fill="rgb(0 0 0 / 0.5)" fill-opacity="0.8"/>
+		<rect class="primary other" width="5" height="5"/>
+		<rect width="5" height="5" fill="none"/>
+	</g>
+	<rect id="logo" width="5" height="5"/>
+	<rect width="5" height="5"/>
+	</svg>`
+	icon, err := ReadIconStream(strings.NewReader(src), StrictErrorMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cl := icon.SVGPaths[2].Classes; len(cl) != 3 || cl[0] != "primary" || cl[2] != "secondary" {
+		t.Errorf("unexpected classes %v", cl)
+	}
+	if icon.SVGPaths[4].ID != "logo" {
+		t.Errorf("unexpected id %s", icon.SVGPaths[4].ID)
+	}
+
+	icon.ApplyTheme(map[string]color.Color{
+		"primary":   color.RGBA{0xff, 0, 0, 0xff},
+		"secondary": color.RGBA{0, 0, 0xff, 0xff},
+		"#logo":     color.RGBA{0, 0xff, 0, 0xff},
+	})
+	for i, exp := range []Pattern{
+		NewPlainColor(0xff, 0, 0, 0xff),
+		NewPlainColor(0, 0, 0xff, 0x80),
+		NewPlainColor(0xff, 0, 0, 0xff),
+		nil,
+		NewPlainColor(0, 0xff, 0, 0xff),
+		NewPlainColor(0, 0, 0, 0xff),
+	} {
+		if fill := icon.SVGPaths[i].Style.FillerColor; fill != exp {
+			t.Errorf("path %d: expected %v, got %v", i, exp, fill)
+		}
+	}
+	if op := icon.SVGPaths[1].Style.FillOpacity; op != 0.8 {
+		t.Errorf("fill opacity should be preserved, got %g", op)
+	}
+}
+
+func TestUnsizedRoot(t *testing.T) {
+	icon, err := ReadIconStream(strings.NewReader(`<svg xmlns="http://www.w3.org/2000/svg">
+	<g transform="translate(5 0)"><rect x="10" y="20" width="30" height="40"/></g>
+	</svg>`), StrictErrorMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp := (Bounds{X: 15, Y: 20, W: 30, H: 40}); icon.ViewBox != exp {
+		t.Errorf("expected view box %v, got %v", exp, icon.ViewBox)
+	}
+
+	// percentages are not a fallback
+	icon, err = ReadIconStream(strings.NewReader(`<svg xmlns="http://www.w3.org/2000/svg" width="100%">
+	<rect width="30" height="40"/></svg>`), StrictErrorMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if icon.ViewBox != (Bounds{}) {
+		t.Errorf("unexpected view box %v", icon.ViewBox)
+	}
+}
+
+func TestParseScale(t *testing.T) {
+	const large = `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 100000000 50000000" width="100000000">
+	<path d="M 10000000 10000000 L 90000000 10000000 L 90000000 40000000 Z"/>
+	<rect x="50000000" y="0" width="25000000" height="25000000"/>
+	</svg>`
+	if _, err := ReadIconStream(strings.NewReader(large), StrictErrorMode); err == nil {
+		t.Fatal("expected an error for out of range coordinates")
+	}
+	icon, err := ReadIconStreamOptions(strings.NewReader(large), ParseOptions{ErrorMode: StrictErrorMode, Scale: 0.001})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp := (Bounds{W: 100000, H: 50000}); icon.ViewBox != exp || icon.Width != "100000" {
+		t.Errorf("unexpected view box %v and width %s", icon.ViewBox, icon.Width)
+	}
+	for i, exp := range []Bounds{{X: 10000, Y: 10000, W: 80000, H: 30000}, {X: 50000, W: 25000, H: 25000}} {
+		if b, _ := icon.SVGPaths[i].Path.bounds(Identity); b != exp {
+			t.Errorf("path %d: expected bounds %v, got %v", i, exp, b)
+		}
+	}
+
+	// the scaled icon is the same, up to the scale factor
+	const src = `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 100 100">
+	<g transform="translate(10 20) rotate(30 5 5)" style="transform-origin: 10px 10px">
+		<path d="M 10 10 h 20 a 5 5 0 0 1 5 5 v 20 Z" stroke-width="2" stroke-dasharray="4 2"/>
+		<circle cx="50%" cy="50" r="10" transform="matrix(1 0 0 1 3 4)"/>
+	</g>
+	<linearGradient id="g" gradientUnits="userSpaceOnUse" x1="10" x2="50%"/>
+	<rect width="10" height="10" fill="url(#g)" stroke="black"/>
+	</svg>`
+	ref, err := ReadIconStream(strings.NewReader(src), StrictErrorMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	scaled, err := ReadIconStreamOptions(strings.NewReader(src), ParseOptions{ErrorMode: StrictErrorMode, Scale: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if scaled.ViewBox != (Bounds{W: 200, H: 200}) {
+		t.Errorf("unexpected view box %v", scaled.ViewBox)
+	}
+	closeTo := func(a, b float64) bool { return math.Abs(a-b) < 0.1 }
+	for i, svgp := range ref.SVGPaths {
+		other := scaled.SVGPaths[i]
+		b1, _ := svgp.Path.bounds(svgp.Style.transform)
+		b2, _ := other.Path.bounds(other.Style.transform)
+		if !closeTo(2*b1.X, b2.X) || !closeTo(2*b1.Y, b2.Y) || !closeTo(2*b1.W, b2.W) || !closeTo(2*b1.H, b2.H) {
+			t.Errorf("path %d: expected bounds %v (scaled by 2), got %v", i, b1, b2)
+		}
+		if 2*svgp.Style.LineWidth != other.Style.LineWidth {
+			t.Errorf("path %d: unexpected line width %g", i, other.Style.LineWidth)
+		}
+		for j, d := range svgp.Style.Dash.Dash {
+			if 2*d != other.Style.Dash.Dash[j] {
+				t.Errorf("path %d: unexpected dash array %v", i, other.Style.Dash.Dash)
+			}
+		}
+	}
+	if dir := scaled.SVGPaths[2].Style.FillerColor.(Gradient).Direction.(Linear); dir[0] != 20 || dir[2] != 100 {
+		t.Errorf("unexpected gradient direction %v", dir)
+	}
+}
+
+func TestGradientDefinedAfterUse(t *testing.T) {
+	const src = `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 10 10">
+	<g fill="url(#fill)">
+		<rect width="5" height="5" stroke="url('#stroke')"/>
+		<rect width="5" height="5" fill="red"/>
+	</g>
+	<rect width="5" height="5" fill="url(#missing)"/>
+	<defs>
+		<linearGradient id="fill"><stop offset="0" stop-color="red"/><stop offset="1" stop-color="blue"/></linearGradient>
+		<radialGradient id="stroke"><stop offset="0" stop-color="red"/><stop offset="1" stop-color="blue"/></radialGradient>
+	</defs>
+	</svg>`
+	icon, err := ReadIconStream(strings.NewReader(src), StrictErrorMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(icon.SVGPaths) != 3 {
+		t.Fatalf("expected 3 paths, got %d", len(icon.SVGPaths))
+	}
+	style := icon.SVGPaths[0].Style
+	if g, ok := style.FillerColor.(Gradient); !ok || g.Direction.isRadial() {
+		t.Errorf("expected a linear gradient fill, got %v", style.FillerColor)
+	}
+	if g, ok := style.LinerColor.(Gradient); !ok || !g.Direction.isRadial() {
+		t.Errorf("expected a radial gradient stroke, got %v", style.LinerColor)
+	}
+	if exp := NewPlainColor(0xff, 0, 0, 0xff); icon.SVGPaths[1].Style.FillerColor != exp {
+		t.Errorf("expected %v, got %v", exp, icon.SVGPaths[1].Style.FillerColor)
+	}
+	// unresolved references are reported
+	if _, ok := icon.SVGPaths[2].Style.FillerColor.(PlainColor); !ok {
+		t.Errorf("expected the default color, got %v", icon.SVGPaths[2].Style.FillerColor)
+	}
+	if issues := icon.Validate(); len(issues) != 1 || !strings.Contains(issues[0].Message, "#missing") {
+		t.Errorf("unexpected issues %v", issues)
+	}
+}
+
+func TestUniformScaleBounds(t *testing.T) {
+	icon, err := ReadIconStream(strings.NewReader(`<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 40 40">
+	<rect x="1" y="2" width="10" height="5" transform="scale(2)"/>
+	</svg>`), StrictErrorMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	svgp := icon.SVGPaths[0]
+	b, _ := svgp.Path.bounds(svgp.Style.transform)
+	if exp := (Bounds{X: 2, Y: 4, W: 20, H: 10}); b != exp {
+		t.Errorf("expected bounds %v, got %v", exp, b)
+	}
+}
+
+func TestStyleSheetSelectors(t *testing.T) {
+	const src = `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 10 10">
+	<style>
+		#special { fill: blue }
+		.cls-1 { fill: green; stroke: red }
+		rect { fill: red }
+		g > rect, rect.cls-1, * { fill: yellow }
+	</style>
+	<rect width="5" height="5"/>
+	<rect class="cls-1" width="5" height="5"/>
+	<rect id="special" class="cls-1" width="5" height="5"/>
+	<circle r="2" style="fill: black"/>
+	</svg>`
+	icon, err := ReadIconStream(strings.NewReader(src), StrictErrorMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	red, green, blue, black := NewPlainColor(0xff, 0, 0, 0xff), NewPlainColor(0, 0x80, 0, 0xff),
+		NewPlainColor(0, 0, 0xff, 0xff), NewPlainColor(0, 0, 0, 0xff)
+	for i, exp := range []PlainColor{red, green, blue, black} {
+		if fill := icon.SVGPaths[i].Style.FillerColor; fill != exp {
+			t.Errorf("path %d: expected fill %v, got %v", i, exp, fill)
+		}
+	}
+	if stroke := icon.SVGPaths[2].Style.LinerColor; stroke != red {
+		t.Errorf("expected stroke %v, got %v", red, stroke)
+	}
+}
+
+func TestUseTransform(t *testing.T) {
+	icon, err := ReadIconStream(strings.NewReader(`<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 40 40">
+	<defs><rect id="r" width="2" height="2"/></defs>
+	<use href="#r" transform="rotate(45)" x="10" y="10"/>
+	</svg>`), StrictErrorMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the translation (x, y) is applied after the transform attribute
+	m := icon.SVGPaths[0].Style.transform
+	if x, y := m.Transform(0, 0); !almostEqual(x, 0) || !almostEqual(y, 10*math.Sqrt2) {
+		t.Errorf("unexpected origin (%g, %g)", x, y)
+	}
+	if x, y := m.Transform(2, 0); !almostEqual(x, math.Sqrt2) || !almostEqual(y, 11*math.Sqrt2) {
+		t.Errorf("unexpected corner (%g, %g)", x, y)
+	}
+}
+
+func TestErrorKinds(t *testing.T) {
+	var (
+		xmlErr   XMLError
+		parseErr ParseError
+	)
+
+	_, err := ReadIcon("testdata/not-existing.svg", StrictErrorMode)
+	if !errors.Is(err, os.ErrNotExist) || errors.As(err, &xmlErr) || errors.As(err, &parseErr) {
+		t.Errorf("expected a file error, got %v", err)
+	}
+
+	errRead := errors.New("read failed")
+	_, err = ReadIconStream(io.MultiReader(strings.NewReader("<svg>"), readerWithError{errRead}), StrictErrorMode)
+	if !errors.Is(err, errRead) || errors.As(err, &xmlErr) {
+		t.Errorf("expected a reading error, got %v", err)
+	}
+
+	for _, src := range []string{"", "<svg><rect></svg>", "<svg><rect/>"} {
+		if _, err = ReadIconStream(strings.NewReader(src), StrictErrorMode); !errors.As(err, &xmlErr) {
+			t.Errorf("%s: expected an XML error, got %v", src, err)
+		}
+	}
+
+	_, err = ReadIconStream(strings.NewReader(`<svg viewBox="0 0 10 10"><path d="M 1"/></svg>`), StrictErrorMode)
+	if !errors.As(err, &parseErr) || parseErr.Element != "path" || !errors.Is(err, errPathParamMismatch) {
+		t.Errorf("expected a parse error, got %v", err)
+	}
+	if _, _, _, err = DecodeConfig(strings.NewReader("<rect/>")); !errors.As(err, &parseErr) {
+		t.Errorf("expected a parse error, got %v", err)
+	}
+}
+
+type readerWithError struct{ err error }
+
+func (r readerWithError) Read([]byte) (int, error) { return 0, r.err }
+
+func TestCurrentColor(t *testing.T) {
+	const src = `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 10 10">
+	<g color="red">
+		<rect width="5" height="5" fill="currentColor" stroke="currentColor" color="blue"/>
+		<rect width="5" height="5" style="fill: currentColor; stroke: CurrentColor"/>
+	</g>
+	<rect width="5" height="5" fill="currentColor"/>
+	</svg>`
+	icon, err := ReadIconStream(strings.NewReader(src), StrictErrorMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	red, blue, black := NewPlainColor(0xff, 0, 0, 0xff), NewPlainColor(0, 0, 0xff, 0xff), NewPlainColor(0, 0, 0, 0xff)
+	for i, exp := range []PlainColor{blue, red, black} {
+		style := icon.SVGPaths[i].Style
+		if style.FillerColor != exp {
+			t.Errorf("path %d: expected fill %v, got %v", i, exp, style.FillerColor)
+		}
+		if i < 2 && style.LinerColor != exp {
+			t.Errorf("path %d: expected stroke %v, got %v", i, exp, style.LinerColor)
+		}
+	}
+
+	// the host application may provide the color
+	icon, err = ReadIconStreamOptions(strings.NewReader(src), ParseOptions{Color: color.RGBA{0, 0xff, 0, 0xff}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fill := icon.SVGPaths[2].Style.FillerColor; fill != NewPlainColor(0, 0xff, 0, 0xff) {
+		t.Errorf("unexpected fill %v", fill)
+	}
+}
+
+func TestDashArrayNone(t *testing.T) {
+	const src = `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 10 10">
+	<g stroke="black" stroke-dasharray="5,5" stroke-dashoffset="2">
+		<rect width="5" height="5"/>
+		<rect width="5" height="5" stroke-dasharray="none"/>
+		<rect width="5" height="5" style="stroke-dasharray: none"/>
+	</g>
+	</svg>`
+	icon, err := ReadIconStream(strings.NewReader(src), StrictErrorMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dash := icon.SVGPaths[0].Style.Dash; len(dash.Dash) != 2 || dash.DashOffset != 2 {
+		t.Errorf("expected inherited dashes, got %v", dash)
+	}
+	for _, svgp := range icon.SVGPaths[1:] {
+		if len(svgp.Style.Dash.Dash) != 0 {
+			t.Errorf("expected a solid stroke, got %v", svgp.Style.Dash)
+		}
+	}
+}
+
+func TestDisplayAndVisibility(t *testing.T) {
+	const src = `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 10 10">
+	<defs style="display:none">
+		<linearGradient id="inDefs" style="display:none"><stop offset="0" stop-color="red"/><stop offset="1" stop-color="blue"/></linearGradient>
+		<g id="group" style="display:none">
+			<rect width="1" height="1" fill="url(#inDefs)"/>
+			<rect width="2" height="2" display="none"/>
+		</g>
+	</defs>
+	<linearGradient id="outside" display="none"><stop offset="0" stop-color="red"/><stop offset="1" stop-color="blue"/></linearGradient>
+	<g display="none"><rect width="3" height="3"/></g>
+	<rect width="4" height="4" visibility="hidden"/>
+	<g visibility="hidden"><rect width="5" height="5" visibility="visible" fill="url(#outside)"/><rect width="6" height="6"/></g>
+	<use href="#group"/>
+	<g display="none"><g><text>Not displayed</text></g></g>
+	<g visibility="hidden"><text>Hidden</text></g>
+	</svg>`
+	icon, err := ReadIconStream(strings.NewReader(src), StrictErrorMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(icon.SVGPaths) != 2 {
+		t.Fatalf("expected 2 paths, got %d", len(icon.SVGPaths))
+	}
+	for i, width := range [2]float64{5, 1} {
+		svgp := icon.SVGPaths[i]
+		if b, _ := svgp.Path.bounds(Identity); b.W != width {
+			t.Errorf("path %d: expected width %g, got %g", i, width, b.W)
+		}
+		if _, ok := svgp.Style.FillerColor.(Gradient); !ok {
+			t.Errorf("path %d: expected a gradient fill, got %v", i, svgp.Style.FillerColor)
+		}
+	}
+}
+
+func TestEllipseCubics(t *testing.T) {
+	icon, err := ReadIconStream(strings.NewReader(`<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 100 100">
+	<ellipse cx="50" cy="40" rx="20" ry="10"/></svg>`), StrictErrorMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const k = 0.5522847498 // optimal distance of the control points, for a unit circle
+	p := func(x, y float64) fixed.Point26_6 { return toFixedP(x, y) }
+	exp := Path{
+		OpMoveTo(p(70, 40)),
+		OpCubicTo{p(70, 40+10*k), p(50+20*k, 50), p(50, 50)},
+		OpCubicTo{p(50-20*k, 50), p(30, 40+10*k), p(30, 40)},
+		OpCubicTo{p(30, 40-10*k), p(50-20*k, 30), p(50, 30)},
+		OpCubicTo{p(50+20*k, 30), p(70, 40-10*k), p(70, 40)},
+		OpClose{},
+	}
+	if got := icon.SVGPaths[0].Path; !got.Equal(exp) {
+		t.Errorf("expected %s, got %s", exp, got)
+	}
+}
+
+func TestLocalizedTitle(t *testing.T) {
+	icon, err := ReadIconStream(strings.NewReader(`<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 10 10">
+	<title xml:lang="en">House</title>
+	<title xml:lang="fr-FR">Maison</title>
+	<title>Home</title>
+	<desc lang="fr">Une maison</desc>
+	</svg>`), StrictErrorMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(icon.TitleLangs) != 3 || icon.TitleLangs[1] != "fr-FR" || icon.TitleLangs[2] != "" {
+		t.Errorf("unexpected title languages %v", icon.TitleLangs)
+	}
+	if len(icon.DescriptionLangs) != 1 || icon.DescriptionLangs[0] != "fr" {
+		t.Errorf("unexpected description languages %v", icon.DescriptionLangs)
+	}
+	for lang, exp := range map[string]string{
+		"en":    "House",
+		"EN-us": "House",
+		"fr":    "Maison",
+		"fr-fr": "Maison",
+		"de":    "Home",
+		"":      "Home",
+	} {
+		if got := icon.Title(lang); got != exp {
+			t.Errorf("expected %q for language %q, got %q", exp, lang, got)
+		}
+	}
+
+	icon.Titles, icon.TitleLangs = icon.Titles[:2], icon.TitleLangs[:2]
+	if got := icon.Title("de"); got != "House" {
+		t.Errorf("expected the first title, got %q", got)
+	}
+	if got := new(SvgIcon).Title("en"); got != "" {
+		t.Errorf("expected no title, got %q", got)
+	}
+}
+
+func TestReadIconFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"icons/square.svg": {Data: []byte(`<svg viewBox="0 0 10 10"><rect width="5" height="5"/></svg>`)},
+	}
+	icon, err := ReadIconFS(fsys, "icons/square.svg", StrictErrorMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(icon.SVGPaths) != 1 || icon.ViewBox != (Bounds{0, 0, 10, 10}) {
+		t.Errorf("unexpected icon %s", icon)
+	}
+
+	if _, err = ReadIconFS(fsys, "icons/missing.svg", StrictErrorMode); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected a file error, got %v", err)
+	}
+
+	// the same as ReadIcon for the host file system
+	if _, err = ReadIconFS(os.DirFS("testdata"), "issue3.svg", IgnoreErrorMode); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestFillRule(t *testing.T) {
+	icon, err := ReadIconStream(strings.NewReader(`<svg viewBox="0 0 10 10">
+	<g fill-rule="evenodd">
+		<rect width="5" height="5"/>
+		<rect width="5" height="5" style="fill-rule: nonzero"/>
+	</g>
+	<rect width="5" height="5"/>
+	</svg>`), StrictErrorMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, exp := range []bool{false, true, true} {
+		if got := icon.SVGPaths[i].Style.UseNonZeroWinding; got != exp {
+			t.Errorf("path %d: expected non-zero winding %v, got %v", i, exp, got)
+		}
+	}
+	if _, err = ReadIconStream(strings.NewReader(`<svg viewBox="0 0 10 10"><rect width="5" height="5" fill-rule="odd"/></svg>`), StrictErrorMode); err == nil {
+		t.Error("expected an error for an invalid fill-rule")
+	}
+}
+
+func TestFillRuleStar(t *testing.T) {
+	// the center of the self-intersecting star is only filled with the non-zero rule
+	icon, err := ReadIconStream(strings.NewReader(`<svg viewBox="0 0 200 100">
+	<path fill-rule="nonzero" d="M50,0 21,90 98,35 2,35 79,90z"/>
+	<path style="fill-rule:evenodd" transform="translate(100)" d="M50,0 21,90 98,35 2,35 79,90z"/>
+	</svg>`), StrictErrorMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(icon.SVGPaths) != 2 {
+		t.Fatalf("expected 2 paths, got %d", len(icon.SVGPaths))
+	}
+	if !icon.SVGPaths[0].Style.UseNonZeroWinding || icon.SVGPaths[1].Style.UseNonZeroWinding {
+		t.Errorf("unexpected winding rules %v, %v", icon.SVGPaths[0].Style.UseNonZeroWinding, icon.SVGPaths[1].Style.UseNonZeroWinding)
+	}
+
+	var rec recorder
+	icon.Draw(&rec, 1)
+	if len(rec.windings) != 2 || !rec.windings[0] || rec.windings[1] {
+		t.Errorf("unexpected windings passed to the filler: %v", rec.windings)
+	}
+}
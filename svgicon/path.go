@@ -1,7 +1,10 @@
 package svgicon
 
 import (
+	"encoding/binary"
 	"fmt"
+	"hash/fnv"
+	"math"
 	"strings"
 
 	"golang.org/x/image/math/fixed"
@@ -16,6 +19,10 @@ type Operation interface {
 
 	// add itself on the driver `d`, after aplying the transform `M`
 	drawTo(d Drawer, M Matrix2D)
+
+	// transformed returns the operation with its points
+	// (including the control points) mapped through `M`
+	transformed(M Matrix2D) Operation
 }
 
 // OpMoveTo moves the current point.
@@ -63,6 +70,22 @@ func (op OpClose) drawTo(d Drawer, _ Matrix2D) {
 	d.Stop(true)
 }
 
+func (op OpMoveTo) transformed(M Matrix2D) Operation { return OpMoveTo(M.trMove(op)) }
+
+func (op OpLineTo) transformed(M Matrix2D) Operation { return OpLineTo(M.trLine(op)) }
+
+func (op OpQuadTo) transformed(M Matrix2D) Operation {
+	b, c := M.trQuad(op)
+	return OpQuadTo{b, c}
+}
+
+func (op OpCubicTo) transformed(M Matrix2D) Operation {
+	b, c, d := M.trCubic(op)
+	return OpCubicTo{b, c, d}
+}
+
+func (op OpClose) transformed(Matrix2D) Operation { return op }
+
 func (op OpMoveTo) String() string {
 	return fmt.Sprintf("M%4.3f,%4.3f", float32(op.X)/64, float32(op.Y)/64)
 }
@@ -103,6 +126,51 @@ func (p Path) String() string {
 	return p.ToSVGPath()
 }
 
+// Equal returns true if `p` and `q` have the same operations,
+// with the same points.
+func (p Path) Equal(q Path) bool {
+	if len(p) != len(q) {
+		return false
+	}
+	for i, op := range p {
+		if op != q[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Hash returns a FNV-1a hash of the operations of the path, so that
+// equal paths (see Equal) have the same hash.
+// It may be used to detect and share identical paths.
+func (p Path) Hash() uint64 {
+	h := fnv.New64a()
+	var buf [1 + 3*8]byte // at most 3 points per operation
+	addPoints := func(kind byte, points ...fixed.Point26_6) {
+		buf[0] = kind
+		for i, pt := range points {
+			binary.LittleEndian.PutUint32(buf[1+8*i:], uint32(pt.X))
+			binary.LittleEndian.PutUint32(buf[5+8*i:], uint32(pt.Y))
+		}
+		_, _ = h.Write(buf[:1+8*len(points)])
+	}
+	for _, op := range p {
+		switch op := op.(type) {
+		case OpMoveTo:
+			addPoints('M', fixed.Point26_6(op))
+		case OpLineTo:
+			addPoints('L', fixed.Point26_6(op))
+		case OpQuadTo:
+			addPoints('Q', op[:]...)
+		case OpCubicTo:
+			addPoints('C', op[:]...)
+		case OpClose:
+			addPoints('Z')
+		}
+	}
+	return h.Sum64()
+}
+
 // Clear zeros the path slice
 func (p *Path) Clear() {
 	*p = (*p)[:0]
@@ -134,3 +202,208 @@ func (p *Path) Stop(closeLoop bool) {
 		*p = append(*p, OpClose{})
 	}
 }
+
+// transform returns a new path, with the points
+// transformed by `M`
+func (p Path) transform(M Matrix2D) Path {
+	out := make(Path, len(p))
+	for i, op := range p {
+		out[i] = op.transformed(M)
+	}
+	return out
+}
+
+// bounds returns the bounding box of the control points of the path,
+// transformed by `m`, or false for an empty path.
+func (p Path) bounds(m Matrix2D) (Bounds, bool) {
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+	add := func(points ...[2]float64) {
+		for _, pt := range points {
+			x, y := m.Transform(pt[0], pt[1])
+			minX, maxX = math.Min(minX, x), math.Max(maxX, x)
+			minY, maxY = math.Min(minY, y), math.Max(maxY, y)
+		}
+	}
+	for _, op := range p {
+		switch op := op.(type) {
+		case OpMoveTo:
+			add(fromFixed(fixed.Point26_6(op)))
+		case OpLineTo:
+			add(fromFixed(fixed.Point26_6(op)))
+		case OpQuadTo:
+			add(fromFixed(op[0]), fromFixed(op[1]))
+		case OpCubicTo:
+			add(fromFixed(op[0]), fromFixed(op[1]), fromFixed(op[2]))
+		}
+	}
+	if minX > maxX {
+		return Bounds{}, false
+	}
+	return Bounds{X: minX, Y: minY, W: maxX - minX, H: maxY - minY}, true
+}
+
+// pathsBounds returns the union of the tight bounds of `paths`, with their
+// transform composed with `m`, or false if all the paths are empty.
+// If `withStroke` is true, the bounds of the stroked paths are enlarged
+// by the extent of their stroke.
+func pathsBounds(paths []SvgPath, m Matrix2D, withStroke bool) (box Bounds, ok bool) {
+	for _, svgp := range paths {
+		pm := m.Mult(svgp.Style.transform)
+		b, nonEmpty := svgp.Path.tightBounds(pm)
+		if !nonEmpty {
+			continue
+		}
+		if withStroke && svgp.Style.LinerColor != nil {
+			margin := svgp.Style.strokeExtent() * math.Sqrt(math.Abs(pm.A*pm.D-pm.B*pm.C))
+			b = Bounds{X: b.X - margin, Y: b.Y - margin, W: b.W + 2*margin, H: b.H + 2*margin}
+		}
+		if ok {
+			box = box.Union(b)
+		} else {
+			box, ok = b, true
+		}
+	}
+	return box, ok
+}
+
+// strokeExtent returns the distance the stroke
+// extends beyond the path, in user units.
+func (style PathStyle) strokeExtent() float64 {
+	switch style.StrokeAlignment {
+	case InnerAlignment:
+		return 0
+	case OuterAlignment:
+		return style.LineWidth
+	default:
+		return style.LineWidth / 2
+	}
+}
+
+func fromFixed(p fixed.Point26_6) [2]float64 {
+	return [2]float64{float64(p.X) / 64, float64(p.Y) / 64}
+}
+
+// length returns the length of the path, where
+// Bézier curves are approximated by line segments.
+func (p Path) length() float64 {
+	const segments = 32
+	var (
+		total         float64
+		current, last [2]float64 // current point and start of the subpath
+	)
+	lineTo := func(pt [2]float64) {
+		total += math.Hypot(pt[0]-current[0], pt[1]-current[1])
+		current = pt
+	}
+	for _, op := range p {
+		switch op := op.(type) {
+		case OpMoveTo:
+			current = fromFixed(fixed.Point26_6(op))
+			last = current
+		case OpLineTo:
+			lineTo(fromFixed(fixed.Point26_6(op)))
+		case OpQuadTo:
+			p0, p1, p2 := current, fromFixed(op[0]), fromFixed(op[1])
+			for i := 1; i <= segments; i++ {
+				t := float64(i) / segments
+				a, b, c := (1-t)*(1-t), 2*t*(1-t), t*t
+				lineTo([2]float64{a*p0[0] + b*p1[0] + c*p2[0], a*p0[1] + b*p1[1] + c*p2[1]})
+			}
+		case OpCubicTo:
+			p0, p1, p2, p3 := current, fromFixed(op[0]), fromFixed(op[1]), fromFixed(op[2])
+			for i := 1; i <= segments; i++ {
+				t := float64(i) / segments
+				a, b, c, d := (1-t)*(1-t)*(1-t), 3*t*(1-t)*(1-t), 3*t*t*(1-t), t*t*t
+				lineTo([2]float64{a*p0[0] + b*p1[0] + c*p2[0] + d*p3[0], a*p0[1] + b*p1[1] + c*p2[1] + d*p3[1]})
+			}
+		case OpClose:
+			lineTo(last)
+		}
+	}
+	return total
+}
+
+// Simplify returns a new path where the runs of consecutive lines are
+// reduced with the Ramer-Douglas-Peucker algorithm: the points whose distance
+// to the simplified line is below `tolerance` (in path units) are removed.
+// Curves, moves and closes are kept intact.
+func (p Path) Simplify(tolerance float64) Path {
+	out := make(Path, 0, len(p))
+	var (
+		run            []fixed.Point26_6 // current point followed by the line ends
+		current, start fixed.Point26_6   // current point and start of the subpath
+	)
+	flush := func() {
+		if len(run) > 1 {
+			for _, pt := range simplifyPolyline(run, tolerance)[1:] {
+				out = append(out, OpLineTo(pt))
+			}
+		}
+		run = run[:0]
+	}
+	for _, op := range p {
+		switch op := op.(type) {
+		case OpLineTo:
+			if len(run) == 0 {
+				run = append(run, current)
+			}
+			current = fixed.Point26_6(op)
+			run = append(run, current)
+			continue
+		case OpMoveTo:
+			current, start = fixed.Point26_6(op), fixed.Point26_6(op)
+		case OpQuadTo:
+			current = op[1]
+		case OpCubicTo:
+			current = op[2]
+		case OpClose:
+			current = start
+		}
+		flush()
+		out = append(out, op)
+	}
+	flush()
+	return out
+}
+
+// simplifyPolyline applies the Ramer-Douglas-Peucker algorithm to `pts`,
+// keeping its first and last points.
+func simplifyPolyline(pts []fixed.Point26_6, tolerance float64) []fixed.Point26_6 {
+	keep := make([]bool, len(pts))
+	keep[0], keep[len(pts)-1] = true, true
+	var simplify func(first, last int)
+	simplify = func(first, last int) {
+		a, b := fromFixed(pts[first]), fromFixed(pts[last])
+		maxDist, index := 0., -1
+		for i := first + 1; i < last; i++ {
+			if d := segmentDistance(fromFixed(pts[i]), a, b); d > maxDist {
+				maxDist, index = d, i
+			}
+		}
+		if index != -1 && maxDist > tolerance {
+			keep[index] = true
+			simplify(first, index)
+			simplify(index, last)
+		}
+	}
+	simplify(0, len(pts)-1)
+
+	out := make([]fixed.Point26_6, 0, len(pts))
+	for i, pt := range pts {
+		if keep[i] {
+			out = append(out, pt)
+		}
+	}
+	return out
+}
+
+// segmentDistance returns the distance from `p` to the segment [a, b].
+func segmentDistance(p, a, b [2]float64) float64 {
+	dx, dy := b[0]-a[0], b[1]-a[1]
+	t := 0.
+	if l2 := dx*dx + dy*dy; l2 != 0 {
+		t = math.Max(0, math.Min(1, ((p[0]-a[0])*dx+(p[1]-a[1])*dy)/l2))
+	}
+	return math.Hypot(p[0]-a[0]-t*dx, p[1]-a[1]-t*dy)
+}
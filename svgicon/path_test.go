@@ -0,0 +1,120 @@
+package svgicon
+
+import (
+	"math"
+	"testing"
+
+	"golang.org/x/image/math/fixed"
+)
+
+func TestPathEqualHash(t *testing.T) {
+	pt := func(x, y int) fixed.Point26_6 { return fixed.P(x, y) }
+	var p1 Path
+	p1.Start(pt(0, 0))
+	p1.Line(pt(10, 0))
+	p1.QuadBezier(pt(10, 10), pt(0, 10))
+	p1.CubeBezier(pt(1, 2), pt(3, 4), pt(0, 0))
+	p1.Stop(true)
+
+	var p2 Path
+	p2.Start(pt(0, 0))
+	p2.Line(pt(10, 0))
+	p2.QuadBezier(pt(10, 10), pt(0, 10))
+	p2.CubeBezier(pt(1, 2), pt(3, 4), pt(0, 0))
+	p2.Stop(true)
+
+	if !p1.Equal(p2) || p1.Hash() != p2.Hash() {
+		t.Errorf("expected equal paths and hashes for %s", p1)
+	}
+
+	p3 := append(Path{}, p2[:4]...) // not closed
+	p4 := append(Path{}, p2...)
+	p4[1] = OpLineTo(pt(10, 1))
+	p5 := Path{OpLineTo(pt(0, 0))} // same point, other operation
+	p6 := Path{OpMoveTo(pt(0, 0))}
+	for _, other := range []Path{p3, p4, p5, nil} {
+		if p1.Equal(other) || other.Equal(p1) {
+			t.Errorf("expected different paths for %s", other)
+		}
+		if p1.Hash() == other.Hash() {
+			t.Errorf("unexpected hash collision for %s", other)
+		}
+	}
+	if p5.Equal(p6) || p5.Hash() == p6.Hash() {
+		t.Error("expected different paths for different operations")
+	}
+	if !Path(nil).Equal(Path{}) {
+		t.Error("expected empty paths to be equal")
+	}
+}
+
+func TestOperationTransformed(t *testing.T) {
+	pt := func(x, y int) fixed.Point26_6 { return fixed.P(x, y) }
+	m := Identity.Translate(10, 20).Scale(2, 3) // (x, y) -> (2x + 10, 3y + 20)
+	for _, test := range []struct {
+		op, expected Operation
+	}{
+		{OpMoveTo(pt(1, 2)), OpMoveTo(pt(12, 26))},
+		{OpLineTo(pt(-1, 0)), OpLineTo(pt(8, 20))},
+		{OpQuadTo{pt(1, 1), pt(2, 0)}, OpQuadTo{pt(12, 23), pt(14, 20)}},
+		{OpCubicTo{pt(0, 1), pt(1, 2), pt(3, 3)}, OpCubicTo{pt(10, 23), pt(12, 26), pt(16, 29)}},
+		{OpClose{}, OpClose{}},
+	} {
+		if got := test.op.transformed(m); got != test.expected {
+			t.Errorf("%s: expected %s, got %s", test.op, test.expected, got)
+		}
+		if got := test.op.transformed(Identity); got != test.op {
+			t.Errorf("%s: identity should not modify the operation, got %s", test.op, got)
+		}
+	}
+
+	// rotations also apply to control points
+	rot := Identity.Rotate(math.Pi / 2)
+	if got, exp := (OpCubicTo{pt(1, 0), pt(0, 1), pt(1, 1)}).transformed(rot), (OpCubicTo{pt(0, 1), pt(-1, 0), pt(-1, 1)}); got != exp {
+		t.Errorf("expected %s, got %s", exp, got)
+	}
+}
+
+func TestSimplify(t *testing.T) {
+	pt := func(x, y float64) fixed.Point26_6 { return fixed.Point26_6{X: fToFixed(x), Y: fToFixed(y)} }
+	var p Path
+	p.Start(pt(0, 0))
+	// a noisy horizontal line, then a vertical one
+	for i := 1; i <= 100; i++ {
+		p.Line(pt(float64(i), 0.2*math.Sin(float64(i))))
+	}
+	for i := 1; i <= 100; i++ {
+		p.Line(pt(100, float64(i)))
+	}
+	p.QuadBezier(pt(50, 150), pt(0, 100))
+	p.Line(pt(0, 50))
+	p.Stop(true)
+
+	simplified := p.Simplify(0.5)
+	expected := Path{
+		OpMoveTo(pt(0, 0)),
+		OpLineTo(pt(100, 0.2*math.Sin(100))),
+		OpLineTo(pt(100, 100)),
+		OpQuadTo{pt(50, 150), pt(0, 100)},
+		OpLineTo(pt(0, 50)),
+		OpClose{},
+	}
+	if !simplified.Equal(expected) {
+		t.Fatalf("expected %s, got %s", expected, simplified)
+	}
+
+	// the removed points are within the tolerance
+	a, b := fromFixed(pt(0, 0)), fromFixed(fixed.Point26_6(expected[1].(OpLineTo)))
+	for _, op := range p[1:100] {
+		if d := segmentDistance(fromFixed(fixed.Point26_6(op.(OpLineTo))), a, b); d > 0.5 {
+			t.Errorf("point %v too far from the simplified line: %g", op, d)
+		}
+	}
+
+	// aligned points are removed even with a zero tolerance
+	zigzag := Path{OpMoveTo(pt(0, 0)), OpLineTo(pt(1, 1)), OpLineTo(pt(2, 0)), OpLineTo(pt(3, 0)), OpLineTo(pt(4, 0))}
+	expected = Path{OpMoveTo(pt(0, 0)), OpLineTo(pt(1, 1)), OpLineTo(pt(2, 0)), OpLineTo(pt(4, 0))}
+	if got := zigzag.Simplify(0); !got.Equal(expected) {
+		t.Errorf("expected %s, got %s", expected, got)
+	}
+}
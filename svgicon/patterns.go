@@ -2,8 +2,11 @@ package svgicon
 
 import (
 	"encoding/xml"
+	"errors"
 	"fmt"
 	"image/color"
+	"math"
+	"sort"
 	"strconv"
 	"strings"
 
@@ -19,10 +22,15 @@ type Pattern interface {
 	isPattern()
 }
 
+// PlainColor is a non premultiplied color, whose alpha
+// is combined with the fill or stroke opacity by the drivers.
+// As a color.Color, its RGBA method returns premultiplied values.
 type PlainColor struct {
 	color.NRGBA
 }
 
+var _ color.Color = PlainColor{}
+
 func NewPlainColor(r, g, b, a uint8) PlainColor {
 	return PlainColor{NRGBA: color.NRGBA{r, g, b, a}}
 }
@@ -54,9 +62,19 @@ func (o optionnalColor) asPattern() Pattern {
 	return nil
 }
 
+// parseColorWith is the same as parseSVGColor, but also
+// resolves the currentColor keyword, using the color property of `style`.
+func parseColorWith(colorStr string, style *PathStyle) (optionnalColor, error) {
+	if strings.EqualFold(strings.TrimSpace(colorStr), "currentColor") {
+		return toOptColor(style.color), nil
+	}
+	return parseSVGColor(colorStr)
+}
+
 // parseSVGColor parses an SVG color string in all forms
 // including all SVG1.1 names, obtained from the colornames package
 func parseSVGColor(colorStr string) (optionnalColor, error) {
+	colorStr = strings.TrimSpace(colorStr)
 	v := strings.ToLower(colorStr)
 	if strings.HasPrefix(v, "url") { // We are not handling urls
 		// and gradients and stuff at this point
@@ -76,24 +94,13 @@ func parseSVGColor(colorStr string) (optionnalColor, error) {
 			return toOptColor(NewPlainColor(uint8(r), uint8(g), uint8(b), uint8(a))), nil
 		}
 	}
-	cStr := strings.TrimPrefix(colorStr, "rgb(")
-	if cStr != colorStr {
-		cStr := strings.TrimSuffix(cStr, ")")
-		vals := strings.Split(cStr, ",")
-		if len(vals) != 3 {
-			return toOptColor(PlainColor{}), fmt.Errorf("invalid color: %s", colorStr)
-		}
-		var cvals [3]uint8
-		var err error
-		for i := range cvals {
-			cvals[i], err = parseColorValue(vals[i])
-			if err != nil {
-				return optionnalColor{}, err
-			}
+	if c, ok, err := parseFunctionalColor(v); ok {
+		if err != nil {
+			return optionnalColor{}, fmt.Errorf("invalid color %s: %s", colorStr, err)
 		}
-		return toOptColor(NewPlainColor(cvals[0], cvals[1], cvals[2], 0xFF)), nil
+		return toOptColor(c), nil
 	}
-	if colorStr[0] == '#' {
+	if strings.HasPrefix(colorStr, "#") {
 		r, g, b, err := parseSVGColorNum(colorStr)
 		if err != nil {
 			return optionnalColor{}, err
@@ -103,31 +110,155 @@ func parseSVGColor(colorStr string) (optionnalColor, error) {
 	return optionnalColor{}, fmt.Errorf("invalid color: %s", colorStr)
 }
 
+// parseFunctionalColor parses the rgb(), rgba(), hsl() and hsla() notations, either with
+// the legacy comma separated syntax, or with the CSS Color 4 syntax, such
+// as rgb(255 0 0 / 50%). `ok` is false if `v` does not use one of these notations.
+func parseFunctionalColor(v string) (c PlainColor, ok bool, err error) {
+	open := strings.IndexByte(v, '(')
+	if open == -1 {
+		return c, false, nil
+	}
+	name := strings.TrimSpace(v[:open])
+	switch name {
+	case "rgb", "rgba", "hsl", "hsla":
+	default:
+		return c, false, nil
+	}
+	if !strings.HasSuffix(v, ")") {
+		return c, true, errors.New("missing closing parenthesis")
+	}
+	args := v[open+1 : len(v)-1]
+
+	var components []string
+	alpha := "1"
+	if slash := strings.IndexByte(args, '/'); slash != -1 {
+		// CSS Color 4 syntax, with space separated components
+		components = strings.Fields(args[:slash])
+		alpha = strings.TrimSpace(args[slash+1:])
+	} else {
+		// commas are optional, but may not delimit empty components
+		for _, part := range strings.Split(args, ",") {
+			fields := strings.Fields(part)
+			if len(fields) == 0 {
+				return c, true, errors.New("empty component")
+			}
+			components = append(components, fields...)
+		}
+		if len(components) == 4 {
+			components, alpha = components[:3], components[3]
+		}
+	}
+	if len(components) != 3 {
+		return c, true, errors.New("expected 3 components")
+	}
+
+	var r, g, b uint8
+	if name == "rgb" || name == "rgba" {
+		var cvals [3]uint8
+		for i, comp := range components {
+			if cvals[i], err = parseColorValue(comp); err != nil {
+				return c, true, err
+			}
+		}
+		r, g, b = cvals[0], cvals[1], cvals[2]
+	} else {
+		hue, err := parseAngle(components[0])
+		if err != nil {
+			return c, true, err
+		}
+		saturation, err := parsePercentage(components[1])
+		if err != nil {
+			return c, true, err
+		}
+		lightness, err := parsePercentage(components[2])
+		if err != nil {
+			return c, true, err
+		}
+		r, g, b = hslToRGB(hue, saturation, lightness)
+	}
+
+	a, err := parseAlphaValue(alpha)
+	if err != nil {
+		return c, true, err
+	}
+	return NewPlainColor(r, g, b, a), true, nil
+}
+
+// parseColorValue parses a color channel, given as a number in [0, 255]
+// or as a percentage. Out of range values are clamped.
 func parseColorValue(v string) (uint8, error) {
-	if v[len(v)-1] == '%' {
-		n, err := strconv.Atoi(strings.TrimSpace(v[:len(v)-1]))
+	v = strings.TrimSpace(v)
+	if strings.HasSuffix(v, "%") {
+		n, err := strconv.ParseFloat(strings.TrimSpace(v[:len(v)-1]), 64)
 		if err != nil {
 			return 0, err
 		}
-		return uint8(n * 0xFF / 100), nil
+		return clampColorValue(n * 0xFF / 100), nil
 	}
-	n, err := strconv.Atoi(strings.TrimSpace(v))
+	n, err := strconv.ParseFloat(v, 64)
+	return clampColorValue(n), err
+}
+
+// parseAlphaValue parses an alpha value, given as a number in [0, 1]
+// or as a percentage. Out of range values are clamped.
+func parseAlphaValue(v string) (uint8, error) {
+	v = strings.TrimSpace(v)
+	if strings.HasSuffix(v, "%") {
+		return parseColorValue(v)
+	}
+	n, err := strconv.ParseFloat(v, 64)
+	return clampColorValue(n * 0xFF), err
+}
+
+// parsePercentage parses a percentage, returning a fraction.
+// The % sign is optional.
+func parsePercentage(v string) (float64, error) {
+	v = strings.TrimSuffix(strings.TrimSpace(v), "%")
+	n, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+	return n / 100, err
+}
+
+func clampColorValue(n float64) uint8 {
 	if n > 255 {
 		n = 255
+	} else if n < 0 || math.IsNaN(n) {
+		n = 0
+	}
+	return uint8(math.Round(n))
+}
+
+// hslToRGB converts the hue (in degrees), saturation and lightness
+// (in [0, 1], clamped if needed) to RGB
+func hslToRGB(hue, saturation, lightness float64) (r, g, b uint8) {
+	hue = math.Mod(hue, 360)
+	if hue < 0 {
+		hue += 360
 	}
-	return uint8(n), err
+	saturation = math.Max(0, math.Min(1, saturation))
+	lightness = math.Max(0, math.Min(1, lightness))
+	// see https://www.w3.org/TR/css-color-4/#hsl-to-rgb
+	channel := func(n float64) uint8 {
+		k := math.Mod(n+hue/30, 12)
+		a := saturation * math.Min(lightness, 1-lightness)
+		return clampColorValue(255 * (lightness - a*math.Max(-1, math.Min(math.Min(k-3, 9-k), 1))))
+	}
+	return channel(0), channel(8), channel(4)
 }
 
 // parseSVGColorNum reads the SFG color string e.g. #FBD9BD
 func parseSVGColorNum(colorStr string) (r, g, b uint8, err error) {
 	colorStr = strings.TrimPrefix(colorStr, "#")
 	var t uint64
-	if len(colorStr) != 6 {
+	switch len(colorStr) {
+	case 6:
+	case 3:
 		// SVG specs say duplicate characters in case of 3 digit hex number
 		colorStr = string([]byte{
 			colorStr[0], colorStr[0],
 			colorStr[1], colorStr[1], colorStr[2], colorStr[2],
 		})
+	default:
+		return 0, 0, 0, fmt.Errorf("invalid color: #%s", colorStr)
 	}
 	for _, v := range []struct {
 		c *uint8
@@ -255,36 +386,213 @@ func localizeGradIfStopClrNil(g *Gradient, defaultColor Pattern) Gradient {
 	return grad
 }
 
+// gradientRef returns the id referenced by the paint
+// value url(#id), or an empty string
+func gradientRef(v string) string {
+	if strings.HasPrefix(v, "url(") && strings.HasSuffix(v, ")") {
+		urlStr := strings.TrimSpace(v[4 : len(v)-1])
+		urlStr = strings.Trim(urlStr, `"'`) // quotes are allowed in CSS
+		if strings.HasPrefix(urlStr, "#") {
+			return urlStr[1:]
+		}
+	}
+	return ""
+}
+
 // readGradURL reads an SVG format gradient url
 // Since the context of the gradient can affect the colors
 // the current fill or line color is passed in and used in
-// the case of a nil stopClor value
+// the case of a nil stopClor value.
+// Gradients not defined yet are resolved by resolveGradientRefs.
 func (c *iconCursor) readGradURL(v string, defaultColor Pattern) (grad Gradient, ok bool) {
-	if strings.HasPrefix(v, "url(") && strings.HasSuffix(v, ")") {
-		urlStr := strings.TrimSpace(v[4 : len(v)-1])
-		if strings.HasPrefix(urlStr, "#") {
-			var g *Gradient
-			g, ok = c.icon.grads[urlStr[1:]]
-			if ok {
-				grad = localizeGradIfStopClrNil(g, defaultColor)
-			}
+	ref := gradientRef(v)
+	if _, isLinked := c.gradLinks[ref]; isLinked { // not complete yet
+		return grad, false
+	}
+	g, ok := c.icon.grads[ref]
+	if !ok {
+		return grad, false
+	}
+	return localizeGradIfStopClrNil(g, defaultColor), true
+}
+
+// recordGradientElement stores the attributes of a gradient element,
+// and its href link to an other gradient, if any.
+func (c *iconCursor) recordGradientElement(tag string, attrs []xml.Attr) {
+	var id, href string
+	for _, attr := range attrs {
+		switch attr.Name.Local {
+		case "id":
+			id = attr.Value
+		case "href": // either xlink:href or href
+			href = strings.TrimSpace(attr.Value)
 		}
 	}
-	return
+	if id == "" {
+		return
+	}
+	if c.gradElements == nil {
+		c.gradElements = make(map[string]definition)
+		c.gradLinks = make(map[string]string)
+	}
+	c.gradElements[id] = definition{ID: id, Tag: tag, Attrs: attrs}
+	if strings.HasPrefix(href, "#") {
+		c.gradLinks[id] = href[1:]
+	}
 }
 
-// readGradAttr reads an SVG gradient attribute
-func (c *iconCursor) readGradAttr(attr xml.Attr) (err error) {
-	switch attr.Name.Local {
-	case "gradientTransform":
-		c.grad.Matrix, err = c.parseTransform(attr.Value)
-	case "gradientUnits":
+// resolveGradientLinks resolves the gradients referencing an other one
+// through href, once the whole file is parsed, so that the referenced
+// gradient may be defined later : the attributes not specified, and the stops,
+// if the gradient has none, are inherited from the referenced gradient.
+func (c *iconCursor) resolveGradientLinks() {
+	ids := make([]string, 0, len(c.gradLinks))
+	for id := range c.gradLinks {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids) // for a deterministic output
+	for _, id := range ids {
+		c.resolveGradientLink(id, nil)
+	}
+}
+
+// resolveGradientLink resolves the gradient `id`, after the gradient it references.
+// `visiting` are the gradients being resolved, so that circular references are ignored.
+func (c *iconCursor) resolveGradientLink(id string, visiting []string) {
+	ref, ok := c.gradLinks[id]
+	if !ok { // not linked, or already resolved
+		return
+	}
+	for _, v := range visiting {
+		if v == id {
+			return
+		}
+	}
+	c.resolveGradientLink(ref, append(visiting, id))
+	delete(c.gradLinks, id)
+
+	base, ok := c.gradElements[ref]
+	if !ok {
+		c.icon.missingGrads = append(c.icon.missingGrads, ref)
+		return
+	}
+	element := c.gradElements[id]
+	df := linearGradientF
+	if element.Tag == "radialGradient" {
+		df = radialGradientF
+	}
+	// parse the gradient again, with the inherited attributes
+	old := c.icon.grads[id]
+	savedGrad, savedInGrad, savedOpacity := c.grad, c.inGrad, c.gradOpacity
+	if err := df(c, inheritedGradAttrs(base, element)); err != nil {
+		c.icon.grads[id] = old // keep the gradient as defined
+	} else {
+		c.grad.Stops = old.Stops
+		if baseGrad := c.icon.grads[ref]; len(c.grad.Stops) == 0 && baseGrad != nil {
+			c.grad.Stops = append([]GradStop(nil), baseGrad.Stops...)
+		}
+	}
+	c.grad, c.inGrad, c.gradOpacity = savedGrad, savedInGrad, savedOpacity
+}
+
+// inheritedGradAttrs returns the attributes of the gradient `element`, completed by the
+// ones of `base` it does not specify : the direction attributes are only inherited
+// between gradients of the same kind.
+func inheritedGradAttrs(base, element definition) []xml.Attr {
+	inherited := map[string]bool{"gradientUnits": true, "gradientTransform": true, "spreadMethod": true}
+	if base.Tag == element.Tag {
+		names := []string{"x1", "y1", "x2", "y2"}
+		if element.Tag == "radialGradient" {
+			names = []string{"cx", "cy", "fx", "fy", "r", "fr"}
+		}
+		for _, name := range names {
+			inherited[name] = true
+		}
+	}
+	var attrs []xml.Attr
+	for _, attr := range element.Attrs {
+		if attr.Name.Local == "href" { // already resolved
+			continue
+		}
+		inherited[attr.Name.Local] = false // specified
+		attrs = append(attrs, attr)
+	}
+	for _, attr := range base.Attrs {
+		if inherited[attr.Name.Local] {
+			attrs = append(attrs, attr)
+		}
+	}
+	return attrs
+}
+
+// resolveGradientRefs resolves the references to gradients
+// defined after their use, once the whole file is parsed,
+// and records the missing ones.
+func (c *iconCursor) resolveGradientRefs() {
+	resolve := func(ref *string, pattern *Pattern) {
+		if *ref == "" {
+			return
+		}
+		if g, ok := c.icon.grads[*ref]; ok && *pattern != nil {
+			*pattern = localizeGradIfStopClrNil(g, *pattern)
+		} else if !ok {
+			c.icon.missingGrads = append(c.icon.missingGrads, *ref)
+		}
+		*ref = ""
+	}
+	for i := range c.icon.SVGPaths {
+		style := &c.icon.SVGPaths[i].Style
+		resolve(&style.fillRef, &style.FillerColor)
+		resolve(&style.strokeRef, &style.LinerColor)
+	}
+}
+
+// normalizeGradients normalizes the gradients used by the paths,
+// once the whole file is parsed.
+func (c *iconCursor) normalizeGradients() {
+	normalize := func(pattern *Pattern) {
+		if g, ok := (*pattern).(Gradient); ok {
+			g.Normalize()
+			*pattern = g
+		}
+	}
+	for i := range c.icon.SVGPaths {
+		style := &c.icon.SVGPaths[i].Style
+		normalize(&style.FillerColor)
+		normalize(&style.LinerColor)
+	}
+}
+
+// readGradUnits reads the gradientUnits attribute, which
+// is needed to interpret the other attributes of the gradient
+func (c *iconCursor) readGradUnits(attrs []xml.Attr) {
+	for _, attr := range attrs {
+		if attr.Name.Local != "gradientUnits" {
+			continue
+		}
 		switch strings.TrimSpace(attr.Value) {
 		case "userSpaceOnUse":
 			c.grad.Units = UserSpaceOnUse
 		case "objectBoundingBox":
 			c.grad.Units = ObjectBoundingBox
 		}
+	}
+}
+
+// readGradAttr reads an SVG gradient attribute
+func (c *iconCursor) readGradAttr(attr xml.Attr) (err error) {
+	switch attr.Name.Local {
+	case "gradientTransform":
+		scale := c.scale
+		if c.grad.Units == ObjectBoundingBox {
+			c.scale = 0 // translations are fractions of the bounding box
+		}
+		c.grad.Matrix, err = c.parseTransform(attr.Value)
+		c.scale = scale
+	case "opacity":
+		// not part of the specification, but used by some tools:
+		// it is applied to the stops when the gradient is closed
+		c.gradOpacity, err = parseBasicFloat(attr.Value)
 	case "spreadMethod":
 		switch strings.TrimSpace(attr.Value) {
 		case "pad":
@@ -297,3 +605,60 @@ func (c *iconCursor) readGradAttr(attr xml.Attr) (err error) {
 	}
 	return
 }
+
+// closeGradient is called at the end of a gradient element
+func (c *iconCursor) closeGradient() {
+	c.inGrad = false
+	if c.gradOpacity != 1 {
+		for i := range c.grad.Stops {
+			c.grad.Stops[i].Opacity *= c.gradOpacity
+		}
+	}
+}
+
+// Normalize fixes a malformed gradient, following the SVG specification :
+//   - a missing direction is replaced by the default horizontal one,
+//     and negative radii are set to zero
+//   - stop offsets and opacities are clamped to [0, 1], and offsets smaller than
+//     the previous one are set to the previous one
+//   - among three or more coincident stops, only the first and
+//     the last are kept, which preserves sharp transitions.
+//
+// Note that a gradient without stops should not be painted, and that a
+// gradient with one stop is equivalent to a plain color.
+// The stops are copied, not modified in place.
+// The gradients of the parsed paths are normalized, but not the definitions,
+// so that Validate may report the malformations.
+func (g *Gradient) Normalize() {
+	switch dir := g.Direction.(type) {
+	case nil:
+		if g.Units == UserSpaceOnUse {
+			g.Direction = Linear{0, 0, g.Bounds.W, 0}
+		} else {
+			g.Direction = Linear{0, 0, 1, 0}
+		}
+	case Radial:
+		dir[4], dir[5] = math.Max(dir[4], 0), math.Max(dir[5], 0)
+		g.Direction = dir
+	}
+
+	clamp := func(v float64) float64 {
+		if math.IsNaN(v) {
+			return 0
+		}
+		return math.Max(0, math.Min(1, v))
+	}
+	stops := make([]GradStop, 0, len(g.Stops))
+	for _, stop := range g.Stops {
+		stop.Offset, stop.Opacity = clamp(stop.Offset), clamp(stop.Opacity)
+		if L := len(stops); L != 0 && stop.Offset < stops[L-1].Offset {
+			stop.Offset = stops[L-1].Offset
+		}
+		if L := len(stops); L >= 2 && stops[L-2].Offset == stop.Offset && stops[L-1].Offset == stop.Offset {
+			stops[L-1] = stop // the middle stop has no effect
+			continue
+		}
+		stops = append(stops, stop)
+	}
+	g.Stops = stops
+}
@@ -0,0 +1,143 @@
+package svgicon
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestGradientNormalize(t *testing.T) {
+	red, green, blue := NewPlainColor(0xff, 0, 0, 0xff), NewPlainColor(0, 0xff, 0, 0xff), NewPlainColor(0, 0, 0xff, 0xff)
+	for _, test := range []struct {
+		name     string
+		stops    []GradStop
+		expected []GradStop
+	}{
+		{"empty", nil, nil},
+		{"out of range", []GradStop{{red, -0.5, 2}, {blue, 1.5, -1}}, []GradStop{{red, 0, 1}, {blue, 1, 0}}},
+		{"not a number", []GradStop{{red, math.NaN(), 1}}, []GradStop{{red, 0, 1}}},
+		{"unsorted", []GradStop{{red, 0.5, 1}, {blue, 0.2, 1}, {green, 0.8, 1}}, []GradStop{{red, 0.5, 1}, {blue, 0.5, 1}, {green, 0.8, 1}}},
+		{"sharp transition", []GradStop{{red, 0, 1}, {red, 0.5, 1}, {blue, 0.5, 1}, {blue, 1, 1}}, []GradStop{{red, 0, 1}, {red, 0.5, 1}, {blue, 0.5, 1}, {blue, 1, 1}}},
+		{"coincident", []GradStop{{red, 0.5, 1}, {green, 0.5, 1}, {green, 0.5, 0.5}, {blue, 0.5, 1}}, []GradStop{{red, 0.5, 1}, {blue, 0.5, 1}}},
+	} {
+		g := Gradient{Direction: Linear{0, 0, 1, 1}, Stops: test.stops}
+		g.Normalize()
+		if len(g.Stops) != len(test.expected) {
+			t.Errorf("%s: expected %v, got %v", test.name, test.expected, g.Stops)
+			continue
+		}
+		for i, stop := range g.Stops {
+			if stop != test.expected[i] {
+				t.Errorf("%s: expected %v, got %v", test.name, test.expected, g.Stops)
+				break
+			}
+		}
+	}
+
+	g := Gradient{}
+	g.Normalize()
+	if g.Direction != (Linear{0, 0, 1, 0}) {
+		t.Errorf("unexpected default direction %v", g.Direction)
+	}
+	g = Gradient{Units: UserSpaceOnUse, Bounds: Bounds{W: 20, H: 10}}
+	g.Normalize()
+	if g.Direction != (Linear{0, 0, 20, 0}) {
+		t.Errorf("unexpected default direction %v", g.Direction)
+	}
+	g = Gradient{Direction: Radial{0.5, 0.5, 0.5, 0.5, -1, -0.5}}
+	g.Normalize()
+	if g.Direction != (Radial{0.5, 0.5, 0.5, 0.5, 0, 0}) {
+		t.Errorf("unexpected direction %v", g.Direction)
+	}
+}
+
+func TestGradientNormalizedWhenParsed(t *testing.T) {
+	icon, err := ReadIconStream(strings.NewReader(`<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 10 10">
+	<linearGradient id="g">
+		<stop offset="0.8" stop-color="red"/><stop offset="0.2" stop-color="blue" stop-opacity="2"/>
+	</linearGradient>
+	<rect width="5" height="5" fill="url(#g)"/>
+	</svg>`), StrictErrorMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stops := icon.SVGPaths[0].Style.FillerColor.(Gradient).Stops
+	if len(stops) != 2 || stops[1].Offset != 0.8 || stops[1].Opacity != 1 {
+		t.Errorf("unexpected stops %v", stops)
+	}
+}
+
+func TestGradientTransform(t *testing.T) {
+	icon, err := ReadIconStream(strings.NewReader(`<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 10 10">
+	<linearGradient id="g" gradientTransform="rotate(45)">
+		<stop offset="0" stop-color="red"/><stop offset="1" stop-color="blue"/>
+	</linearGradient>
+	<rect width="10" height="10" fill="url(#g)"/>
+	</svg>`), StrictErrorMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	grad := icon.SVGPaths[0].Style.FillerColor.(Gradient)
+	if grad.Matrix == Identity {
+		t.Fatal("expected a rotated gradient")
+	}
+	exp := Identity.Rotate(math.Pi / 4)
+	for i, v := range [6]float64{grad.Matrix.A, grad.Matrix.B, grad.Matrix.C, grad.Matrix.D, grad.Matrix.E, grad.Matrix.F} {
+		if e := [6]float64{exp.A, exp.B, exp.C, exp.D, exp.E, exp.F}[i]; math.Abs(v-e) > 1e-9 {
+			t.Errorf("expected %v, got %v", exp, grad.Matrix)
+			break
+		}
+	}
+}
+
+func TestGradientHref(t *testing.T) {
+	icon, err := ReadIconStream(strings.NewReader(`<svg xmlns="http://www.w3.org/2000/svg" xmlns:xlink="http://www.w3.org/1999/xlink" viewBox="0 0 10 10">
+	<linearGradient id="derived" href="#base" x2="0.5"/>
+	<rect width="10" height="10" fill="url(#derived)"/>
+	<radialGradient id="radial" xlink:href="#base" r="0.25"/>
+	<rect width="10" height="10" fill="url(#radial)"/>
+	<linearGradient id="base" spreadMethod="reflect" y2="1">
+		<stop offset="0" stop-color="red"/><stop offset="1" stop-color="blue"/>
+	</linearGradient>
+	<linearGradient id="chained" href="#derived"><stop offset="0.5" stop-color="green"/></linearGradient>
+	<rect width="10" height="10" fill="url(#chained)"/>
+	<linearGradient id="loop1" href="#loop2"/><linearGradient id="loop2" href="#loop1"/>
+	<rect width="10" height="10" fill="url(#loop1)"/>
+	</svg>`), StrictErrorMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(icon.SVGPaths) != 4 {
+		t.Fatalf("expected 4 paths, got %d", len(icon.SVGPaths))
+	}
+	grad := func(i int) Gradient { return icon.SVGPaths[i].Style.FillerColor.(Gradient) }
+
+	derived := grad(0)
+	if len(derived.Stops) != 2 || derived.Spread != ReflectSpread {
+		t.Errorf("expected the stops and the spread method of the base, got %v", derived)
+	}
+	if dir := derived.Direction.(Linear); dir != (Linear{0, 0, 0.5, 1}) {
+		t.Errorf("unexpected direction %v", dir)
+	}
+
+	radial := grad(1)
+	if len(radial.Stops) != 2 || radial.Spread != ReflectSpread {
+		t.Errorf("expected the stops and the spread method of the base, got %v", radial)
+	}
+	// the direction is not inherited from a linear gradient
+	if dir := radial.Direction.(Radial); dir != (Radial{0.5, 0.5, 0.5, 0.5, 0.25, 0}) {
+		t.Errorf("unexpected direction %v", dir)
+	}
+
+	chained := grad(2)
+	if len(chained.Stops) != 1 || chained.Spread != ReflectSpread {
+		t.Errorf("expected its own stop and the inherited spread method, got %v", chained)
+	}
+	if dir := chained.Direction.(Linear); dir != (Linear{0, 0, 0.5, 1}) {
+		t.Errorf("unexpected direction %v", dir)
+	}
+
+	if loop := grad(3); len(loop.Stops) != 0 {
+		t.Errorf("unexpected stops %v", loop.Stops)
+	}
+}
@@ -0,0 +1,44 @@
+package svgicon
+
+import "encoding/xml"
+
+// RawNode is an element of the XML tree of an SVG file,
+// kept when ParseOptions.KeepRawTree is true.
+// It gives access to the elements and attributes not supported
+// by this package.
+type RawNode struct {
+	Tag      string // local name
+	Attrs    []xml.Attr
+	Children []RawNode
+	CharData string // concatenated text content of the element (not its children)
+}
+
+// rawTreeBuilder builds the RawNode tree while parsing
+type rawTreeBuilder struct {
+	stack []RawNode // opened elements
+	root  *RawNode
+}
+
+func (b *rawTreeBuilder) start(se xml.StartElement) {
+	b.stack = append(b.stack, RawNode{Tag: se.Name.Local, Attrs: se.Attr})
+}
+
+func (b *rawTreeBuilder) end() {
+	if len(b.stack) == 0 {
+		return
+	}
+	node := b.stack[len(b.stack)-1]
+	b.stack = b.stack[:len(b.stack)-1]
+	if len(b.stack) == 0 {
+		b.root = &node
+		return
+	}
+	parent := &b.stack[len(b.stack)-1]
+	parent.Children = append(parent.Children, node)
+}
+
+func (b *rawTreeBuilder) charData(data string) {
+	if len(b.stack) != 0 {
+		b.stack[len(b.stack)-1].CharData += data
+	}
+}
@@ -12,6 +12,10 @@ import (
 const (
 	cubicsPerHalfCircle = 8 // Number of cubic beziers to approx half a circle
 
+	// circleKappa is the distance of the control points of the optimal
+	// cubic approximation of a quarter circle, for a unit radius
+	circleKappa = 4 * (math.Sqrt2 - 1) / 3
+
 	// fixed point t paramaterization shift factor;
 	// (2^this)/64 is the max length of t for fixed.Int26_6
 	tStrokeShift = 14
@@ -21,10 +25,11 @@ const (
 	maxDx float64 = math.Pi / 8
 )
 
-// toFixedP converts two floats to a fixed point.
+// toFixedP converts two floats to a fixed point,
+// rounding to the nearest value.
 func toFixedP(x, y float64) (p fixed.Point26_6) {
-	p.X = fixed.Int26_6(x * 64)
-	p.Y = fixed.Int26_6(y * 64)
+	p.X = fToFixed(x)
+	p.Y = fToFixed(y)
 	return
 }
 
@@ -3,9 +3,9 @@ package svgicon
 import (
 	"encoding/xml"
 	"errors"
+	"fmt"
+	"strconv"
 	"strings"
-
-	"golang.org/x/image/math/fixed"
 )
 
 func init() {
@@ -19,6 +19,8 @@ type svgFunc func(c *iconCursor, attrs []xml.Attr) error
 var drawFuncs = map[string]svgFunc{
 	"svg":            svgF,
 	"g":              gF,
+	"switch":         switchF,
+	"a":              aF,
 	"line":           lineF,
 	"stop":           stopF,
 	"rect":           rectF,
@@ -30,39 +32,65 @@ var drawFuncs = map[string]svgFunc{
 	"desc":           descF,
 	"defs":           defsF,
 	"title":          titleF,
+	"style":          styleF,
+	"symbol":         symbolF,
 	"linearGradient": linearGradientF,
 	"radialGradient": radialGradientF,
+	"filter":         filterF,
+	"foreignObject":  foreignObjectF,
+	"text":           textF,
+	"tspan":          tspanF,
+	"image":          imageF,
+	"feGaussianBlur": feGaussianBlurF,
+	"feOffset":       feOffsetF,
+	"feMerge":        feMergeF,
+	"feMergeNode":    feMergeNodeF,
 }
 
 func svgF(c *iconCursor, attrs []xml.Attr) error {
+	if c.rootSeen {
+		return nestedSvgF(c, attrs)
+	}
+	c.rootSeen = true
 	c.icon.ViewBox.X = 0
 	c.icon.ViewBox.Y = 0
 	c.icon.ViewBox.W = 0
 	c.icon.ViewBox.H = 0
 	var width, height float64
 	var err error
+	c.rootUnsized = true
 	for _, attr := range attrs {
+		switch attr.Name.Local {
+		case "viewBox", "width", "height":
+			c.rootUnsized = false
+		}
 		switch attr.Name.Local {
 		case "viewBox":
 			err = c.getPoints(attr.Value)
 			if len(c.points) != 4 {
 				return errPathParamMismatch
 			}
+			c.scalePoints(false)
 			c.icon.ViewBox.X = c.points[0]
 			c.icon.ViewBox.Y = c.points[1]
 			c.icon.ViewBox.W = c.points[2]
 			c.icon.ViewBox.H = c.points[3]
 		case "width":
-			c.icon.Width = attr.Value
-			width, err = parseBasicFloat(attr.Value)
+			c.icon.Width, width, err = c.readRootLength(attr.Value)
 		case "height":
-			c.icon.Height = attr.Value
-			height, err = parseBasicFloat(attr.Value)
+			c.icon.Height, height, err = c.readRootLength(attr.Value)
+		case "preserveAspectRatio":
+			if c.icon.PreserveAspectRatio, err = ParsePreserveAspectRatio(attr.Value); err != nil {
+				c.icon.PreserveAspectRatio = PreserveAspectRatio{}
+				err = c.handleError("%s", err)
+			}
 		}
 		if err != nil {
 			return err
 		}
 	}
+	// percentages are relative to the (unknown) viewport
+	// and can't be used as a view box size
 	if c.icon.ViewBox.W == 0 {
 		c.icon.ViewBox.W = width
 	}
@@ -71,7 +99,122 @@ func svgF(c *iconCursor, attrs []xml.Attr) error {
 	}
 	return nil
 }
+
+// readRootLength parses the width or height attribute of the root element,
+// applying the parse time scale factor to absolute lengths.
+func (c *iconCursor) readRootLength(v string) (string, float64, error) {
+	length, err := parseAbsoluteLength(v)
+	if err != nil || c.scale == 0 || length == 0 {
+		return v, length, err
+	}
+	length *= c.scale
+	return strconv.FormatFloat(length, 'g', -1, 64), length, nil
+}
+
+// readViewBox parses the viewBox attribute, if any
+func (c *iconCursor) readViewBox(attrs []xml.Attr) (viewBox Bounds, ok bool, err error) {
+	for _, attr := range attrs {
+		if attr.Name.Local != "viewBox" {
+			continue
+		}
+		if err = c.getPoints(attr.Value); err != nil {
+			return viewBox, false, err
+		}
+		if len(c.points) != 4 {
+			return viewBox, false, errPathParamMismatch
+		}
+		c.scalePoints(false)
+		return Bounds{X: c.points[0], Y: c.points[1], W: c.points[2], H: c.points[3]}, true, nil
+	}
+	return viewBox, false, nil
+}
+
+// enterViewport establishes a new viewport for the current element (a nested <svg>
+// or an instantiated <symbol>), by updating the transform on top of the style stack,
+// and, unless the overflow property is visible, clipping to the viewport rectangle.
+func (c *iconCursor) enterViewport(attrs []xml.Attr, viewport Bounds) error {
+	viewBox, hasViewBox, err := c.readViewBox(attrs)
+	if err != nil {
+		return err
+	}
+	style := &c.styleStack[len(c.styleStack)-1]
+	m := style.transform.Translate(viewport.X, viewport.Y)
+
+	overflow, _ := styleProperty(attrs, "overflow")
+	if overflow != "visible" && overflow != "auto" {
+		var clip Path
+		q := matrixAdder{M: m, path: &clip}
+		q.Start(toFixedP(0, 0))
+		q.Line(toFixedP(viewport.W, 0))
+		q.Line(toFixedP(viewport.W, viewport.H))
+		q.Line(toFixedP(0, viewport.H))
+		clip.Stop(true)
+		// do not share the backing array with the parent style
+		style.clips = append(append([]Path(nil), style.clips...), clip)
+	}
+
+	if hasViewBox && viewBox.W > 0 && viewBox.H > 0 {
+		m = m.Scale(viewport.W/viewBox.W, viewport.H/viewBox.H).Translate(-viewBox.X, -viewBox.Y)
+	}
+	style.transform = m
+	return nil
+}
+
+// nestedSvgF handles an <svg> element which is not the root
+func nestedSvgF(c *iconCursor, attrs []xml.Attr) error {
+	viewport := Bounds{W: c.icon.ViewBox.W, H: c.icon.ViewBox.H} // default to 100%
+	var err error
+	for _, attr := range attrs {
+		switch attr.Name.Local {
+		case "x":
+			viewport.X, err = c.parseUnit(attr.Value, widthPercentage)
+		case "y":
+			viewport.Y, err = c.parseUnit(attr.Value, heightPercentage)
+		case "width":
+			viewport.W, err = c.parseUnit(attr.Value, widthPercentage)
+		case "height":
+			viewport.H, err = c.parseUnit(attr.Value, heightPercentage)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return c.enterViewport(attrs, viewport)
+}
+
+// symbolF is called when a <symbol> is instantiated by a <use> element,
+// which provides the position and size of the viewport.
+func symbolF(c *iconCursor, attrs []xml.Attr) error {
+	// the <use> position is already applied by the transform
+	viewport := Bounds{W: c.useWidth, H: c.useHeight}
+	if viewport.W == 0 {
+		viewport.W = c.icon.ViewBox.W
+	}
+	if viewport.H == 0 {
+		viewport.H = c.icon.ViewBox.H
+	}
+	return c.enterViewport(attrs, viewport)
+}
+
 func gF(*iconCursor, []xml.Attr) error { return nil } // g does nothing but push the style
+
+// switchF starts a conditional group, where only one child is rendered (see switchSkips)
+func switchF(c *iconCursor, _ []xml.Attr) error {
+	c.styleStack[len(c.styleStack)-1].switchDone = new(bool)
+	return nil
+}
+
+// aF handles an hyperlink, which is a container as <g>,
+// and records its target on the descendant paths
+func aF(c *iconCursor, attrs []xml.Attr) error {
+	for _, attr := range attrs {
+		if attr.Name.Local == "href" { // either xlink:href or href
+			c.styleStack[len(c.styleStack)-1].link = attr.Value
+		}
+	}
+	return nil
+}
+
 func rectF(c *iconCursor, attrs []xml.Attr) error {
 	var x, y, w, h, rx, ry float64
 	var err error
@@ -97,7 +240,11 @@ func rectF(c *iconCursor, attrs []xml.Attr) error {
 	if w == 0 || h == 0 {
 		return nil
 	}
-	c.path.addRoundRect(x+c.curX, y+c.curY, w+x+c.curX, h+y+c.curY, rx, ry, 0)
+	if err = checkCoordinates(x, y, w+x, h+y); err != nil {
+		// only this element is skipped
+		return c.handleError("invalid <rect>: %s", err)
+	}
+	c.path.addRoundRect(x, y, w+x, h+y, rx, ry, 0)
 	return nil
 }
 
@@ -125,7 +272,11 @@ func circleF(c *iconCursor, attrs []xml.Attr) error {
 	if rx == 0 || ry == 0 { // not drawn, but not an error
 		return nil
 	}
-	c.ellipseAt(cx+c.curX, cy+c.curY, rx, ry)
+	if err = checkCoordinates(cx-rx, cx+rx, cy-ry, cy+ry); err != nil {
+		// only this element is skipped
+		return c.handleError("invalid circle or ellipse: %s", err)
+	}
+	c.ellipseAt(cx, cy, rx, ry)
 	return nil
 }
 
@@ -147,14 +298,14 @@ func lineF(c *iconCursor, attrs []xml.Attr) error {
 			return err
 		}
 	}
-	c.path.Start(fixed.Point26_6{
-		X: fixed.Int26_6((x1 + c.curX) * 64),
-		Y: fixed.Int26_6((y1 + c.curY) * 64),
-	})
-	c.path.Line(fixed.Point26_6{
-		X: fixed.Int26_6((x2 + c.curX) * 64),
-		Y: fixed.Int26_6((y2 + c.curY) * 64),
-	})
+	if err = checkCoordinates(x1, y1, x2, y2); err != nil {
+		// only this element is skipped
+		return c.handleError("invalid <line>: %s", err)
+	}
+	c.path.Start(toFixedP(x1, y1))
+	c.path.Line(toFixedP(x2, y2))
+	// lines are never filled, whatever the fill property
+	c.styleStack[len(c.styleStack)-1].FillerColor = nil
 	return nil
 }
 
@@ -167,21 +318,22 @@ func polylineF(c *iconCursor, attrs []xml.Attr) error {
 			if len(c.points)%2 != 0 {
 				return errors.New("polygon has odd number of points")
 			}
+			c.scalePoints(false)
 		}
 		if err != nil {
 			return err
 		}
 	}
-	if len(c.points) > 4 {
-		c.path.Start(fixed.Point26_6{
-			X: fixed.Int26_6((c.points[0] + c.curX) * 64),
-			Y: fixed.Int26_6((c.points[1] + c.curY) * 64),
-		})
+	if err = checkCoordinates(c.points...); err != nil {
+		c.points = c.points[:0] // so that polygons are not closed
+		return c.handleError("invalid polyline or polygon: %s", err)
+	}
+	// open polylines are filled as if closed, which
+	// the drivers do when filling
+	if len(c.points) >= 4 {
+		c.path.Start(toFixedP(c.points[0], c.points[1]))
 		for i := 2; i < len(c.points)-1; i += 2 {
-			c.path.Line(fixed.Point26_6{
-				X: fixed.Int26_6((c.points[i] + c.curX) * 64),
-				Y: fixed.Int26_6((c.points[i+1] + c.curY) * 64),
-			})
+			c.path.Line(toFixedP(c.points[i], c.points[i+1]))
 		}
 	}
 	return nil
@@ -189,7 +341,7 @@ func polylineF(c *iconCursor, attrs []xml.Attr) error {
 
 func polygonF(c *iconCursor, attrs []xml.Attr) error {
 	err := polylineF(c, attrs)
-	if len(c.points) > 4 {
+	if len(c.points) >= 4 {
 		c.path.Stop(true)
 	}
 	return err
@@ -201,6 +353,10 @@ func pathF(c *iconCursor, attrs []xml.Attr) error {
 		switch attr.Name.Local {
 		case "d":
 			err = c.compilePath(attr.Value)
+			if err != nil && c.errorMode != StrictErrorMode {
+				// keep the valid beginning of the path
+				err = c.handleError("invalid path data (truncated): %s", err)
+			}
 		}
 		if err != nil {
 			return err
@@ -212,12 +368,31 @@ func pathF(c *iconCursor, attrs []xml.Attr) error {
 func descF(c *iconCursor, attrs []xml.Attr) error {
 	c.inDescText = true
 	c.icon.Descriptions = append(c.icon.Descriptions, "")
+	c.icon.DescriptionLangs = append(c.icon.DescriptionLangs, langAttr(attrs))
 	return nil
 }
 
 func titleF(c *iconCursor, attrs []xml.Attr) error {
 	c.inTitleText = true
 	c.icon.Titles = append(c.icon.Titles, "")
+	c.icon.TitleLangs = append(c.icon.TitleLangs, langAttr(attrs))
+	return nil
+}
+
+// langAttr returns the language of an element, given
+// by its xml:lang or lang attribute, or an empty string.
+func langAttr(attrs []xml.Attr) string {
+	for _, attr := range attrs {
+		if attr.Name.Local == "lang" {
+			return strings.TrimSpace(attr.Value)
+		}
+	}
+	return ""
+}
+
+func styleF(c *iconCursor, attrs []xml.Attr) error {
+	c.inStyleText = true
+	c.styleText = ""
 	return nil
 }
 
@@ -227,6 +402,7 @@ func defsF(c *iconCursor, attrs []xml.Attr) error {
 }
 
 func linearGradientF(c *iconCursor, attrs []xml.Attr) error {
+	c.recordGradientElement("linearGradient", attrs)
 	var err error
 	c.inGrad = true
 	// interpretation of percentage in direction depends
@@ -234,6 +410,8 @@ func linearGradientF(c *iconCursor, attrs []xml.Attr) error {
 	// and resolve them in a second pass
 	directionStrings := [4]string{"0%", "0%", "100%", "0"} // default value
 	c.grad = &Gradient{Bounds: c.icon.ViewBox, Matrix: Identity}
+	c.gradOpacity = 1
+	c.readGradUnits(attrs)
 	for _, attr := range attrs {
 		switch attr.Name.Local {
 		case "id":
@@ -259,24 +437,24 @@ func linearGradientF(c *iconCursor, attrs []xml.Attr) error {
 		}
 	}
 	// now we can resolve percentages
-	bbox := Bounds{W: 1, H: 1} // default is ObjectBoundingBox
+	resolve := Bounds{W: 1, H: 1}.resolveUnit // default is ObjectBoundingBox
 	if c.grad.Units == UserSpaceOnUse {
-		bbox = c.grad.Bounds
+		resolve = c.parseUnit // relative to the view box, that is c.grad.Bounds
 	}
 	var direction Linear
-	direction[0], err = bbox.resolveUnit(directionStrings[0], widthPercentage)
+	direction[0], err = resolve(directionStrings[0], widthPercentage)
 	if err != nil {
 		return err
 	}
-	direction[1], err = bbox.resolveUnit(directionStrings[1], heightPercentage)
+	direction[1], err = resolve(directionStrings[1], heightPercentage)
 	if err != nil {
 		return err
 	}
-	direction[2], err = bbox.resolveUnit(directionStrings[2], widthPercentage)
+	direction[2], err = resolve(directionStrings[2], widthPercentage)
 	if err != nil {
 		return err
 	}
-	direction[3], err = bbox.resolveUnit(directionStrings[3], heightPercentage)
+	direction[3], err = resolve(directionStrings[3], heightPercentage)
 	if err != nil {
 		return err
 	}
@@ -285,11 +463,14 @@ func linearGradientF(c *iconCursor, attrs []xml.Attr) error {
 }
 
 func radialGradientF(c *iconCursor, attrs []xml.Attr) error {
+	c.recordGradientElement("radialGradient", attrs)
 	c.inGrad = true
 	c.grad = &Gradient{Bounds: c.icon.ViewBox, Matrix: Identity}
+	c.gradOpacity = 1
+	c.readGradUnits(attrs)
 	var setFx, setFy bool
 	var err error
-	directionStrings := [6]string{"50%", "50%", "50%", "50%", "50%", "50%"} // default values
+	directionStrings := [6]string{"50%", "50%", "50%", "50%", "50%", "0%"} // default values
 	for _, attr := range attrs {
 		switch attr.Name.Local {
 		case "id":
@@ -328,32 +509,32 @@ func radialGradientF(c *iconCursor, attrs []xml.Attr) error {
 	}
 
 	// now we can resolve percentages
-	bbox := Bounds{W: 1, H: 1} // default is ObjectBoundingBox
+	resolve := Bounds{W: 1, H: 1}.resolveUnit // default is ObjectBoundingBox
 	if c.grad.Units == UserSpaceOnUse {
-		bbox = c.grad.Bounds
+		resolve = c.parseUnit // relative to the view box, that is c.grad.Bounds
 	}
 	var direction Radial
-	direction[0], err = bbox.resolveUnit(directionStrings[0], widthPercentage)
+	direction[0], err = resolve(directionStrings[0], widthPercentage)
 	if err != nil {
 		return err
 	}
-	direction[1], err = bbox.resolveUnit(directionStrings[1], heightPercentage)
+	direction[1], err = resolve(directionStrings[1], heightPercentage)
 	if err != nil {
 		return err
 	}
-	direction[2], err = bbox.resolveUnit(directionStrings[2], widthPercentage)
+	direction[2], err = resolve(directionStrings[2], widthPercentage)
 	if err != nil {
 		return err
 	}
-	direction[3], err = bbox.resolveUnit(directionStrings[3], heightPercentage)
+	direction[3], err = resolve(directionStrings[3], heightPercentage)
 	if err != nil {
 		return err
 	}
-	direction[4], err = bbox.resolveUnit(directionStrings[4], diagPercentage)
+	direction[4], err = resolve(directionStrings[4], diagPercentage)
 	if err != nil {
 		return err
 	}
-	direction[5], err = bbox.resolveUnit(directionStrings[5], diagPercentage)
+	direction[5], err = resolve(directionStrings[5], diagPercentage)
 	if err != nil {
 		return err
 	}
@@ -371,9 +552,8 @@ func stopF(c *iconCursor, attrs []xml.Attr) error {
 			case "offset":
 				stop.Offset, err = readFraction(attr.Value)
 			case "stop-color":
-				// todo: add current color inherit
 				var optColor optionnalColor
-				optColor, err = parseSVGColor(attr.Value)
+				optColor, err = parseColorWith(attr.Value, &c.styleStack[len(c.styleStack)-1])
 				stop.StopColor = optColor.asColor()
 			case "stop-opacity":
 				stop.Opacity, err = parseBasicFloat(attr.Value)
@@ -389,9 +569,10 @@ func stopF(c *iconCursor, attrs []xml.Attr) error {
 
 func useF(c *iconCursor, attrs []xml.Attr) error {
 	var (
-		href string
-		x, y float64
-		err  error
+		href          string
+		x, y          float64
+		width, height float64
+		err           error
 	)
 	for _, attr := range attrs {
 		switch attr.Name.Local {
@@ -401,46 +582,131 @@ func useF(c *iconCursor, attrs []xml.Attr) error {
 			x, err = c.parseUnit(attr.Value, widthPercentage)
 		case "y":
 			y, err = c.parseUnit(attr.Value, heightPercentage)
+		case "width":
+			width, err = c.parseUnit(attr.Value, widthPercentage)
+		case "height":
+			height, err = c.parseUnit(attr.Value, heightPercentage)
 		}
 		if err != nil {
 			return err
 		}
 	}
-	c.curX, c.curY = x, y
-	defer func() {
-		c.curX, c.curY = 0, 0
-	}()
 	if href == "" {
 		return errors.New("only use tags with href is supported")
 	}
 	if !strings.HasPrefix(href, "#") {
 		return errors.New("only the ID CSS selector is supported")
 	}
-	defs, ok := c.icon.defs[href[1:]]
+	id := href[1:]
+	defs, ok := c.icon.defs[id]
 	if !ok {
-		return errors.New("href ID in use statement was not found in saved defs")
+		c.icon.missingUses = append(c.icon.missingUses, id)
+		return c.handleError("href ID in use statement was not found in saved defs: %s", href)
+	}
+	for _, used := range c.usedIDs {
+		if used == id {
+			return fmt.Errorf("circular reference in use statement: %s", href)
+		}
+	}
+	c.usedIDs = append(c.usedIDs, id)
+	defer func() { c.usedIDs = c.usedIDs[:len(c.usedIDs)-1] }()
+	c.expandedElements += len(defs)
+	if c.expandedElements > maxExpandedElements {
+		return errors.New("too many elements instantiated by use statements")
 	}
-	for _, def := range defs {
-		if def.Tag == "endg" {
+
+	// the position of the use element is an additional translation,
+	// applied after its transform attribute
+	style := &c.styleStack[len(c.styleStack)-1]
+	style.transform = style.transform.Translate(x, y)
+	// the size is used by the (eventual) <symbol>, and restored
+	// to support nested <use>
+	outerWidth, outerHeight := c.useWidth, c.useHeight
+	c.useWidth, c.useHeight = width, height
+	defer func() { c.useWidth, c.useHeight = outerWidth, outerHeight }()
+
+	var opened []string // tags of the opened elements
+	for i, def := range defs {
+		if c.skipDepth > 0 { // inside an element whose content is ignored
+			if def.Tag != endDefinition {
+				c.skipDepth++
+				continue
+			}
+			c.skipDepth--
+			if c.skipDepth > 0 {
+				continue
+			}
+		}
+		if def.Tag == endDefinition {
+			tag := opened[len(opened)-1]
+			opened = opened[:len(opened)-1]
+			if tag == "text" && c.inText {
+				c.closeText()
+			}
 			// pop style
-			c.styleStack = c.styleStack[:len(c.styleStack)-1]
+			if err = c.popStyle(); err != nil {
+				return err
+			}
+			c.addTextContent(def.CharData)
 			continue
 		}
-		if err = c.pushStyle(def.Attrs); err != nil {
+		opened = append(opened, def.Tag)
+		if err = c.pushStyle(def.Tag, def.Attrs); err != nil {
 			return err
 		}
+		// the referenced element itself is instantiated even if it is not
+		// displayed, but not its descendants, as the unselected children of <switch>
+		if i > 0 && (c.styleStack[len(c.styleStack)-1].noDisplay && isRendered(def.Tag) || c.switchSkips(def.Tag, def.Attrs)) {
+			c.skipDepth = 1 // the style will be popped by the end marker
+			continue
+		}
 		df, ok := drawFuncs[def.Tag]
 		if !ok {
-			errStr := "Cannot process svg element " + def.Tag
-			return c.handleError(errStr)
+			c.icon.unsupported = append(c.icon.unsupported, def.Tag)
+			// the style will be popped by the end marker
+			if err = c.handleError("Cannot process svg element %s", def.Tag); err != nil {
+				return err
+			}
+			c.skipDepth = 1 // ignore the descendants as well
+			continue
 		}
 		if err := df(c, def.Attrs); err != nil {
 			return err
 		}
-		if def.Tag != "g" {
-			// pop style
-			c.styleStack = c.styleStack[:len(c.styleStack)-1]
+		if err := c.applyPathLength(def.Attrs); err != nil {
+			return err
+		}
+		// use the style of the definition
+		c.flushPath()
+		c.addTextContent(def.CharData)
+	}
+	return nil
+}
+
+// foreignObjectF records the region of the element,
+// and ignores its content, which is not SVG
+func foreignObjectF(c *iconCursor, attrs []xml.Attr) error {
+	var (
+		fo  ForeignObject
+		err error
+	)
+	for _, attr := range attrs {
+		switch attr.Name.Local {
+		case "x":
+			fo.Bounds.X, err = c.parseUnit(attr.Value, widthPercentage)
+		case "y":
+			fo.Bounds.Y, err = c.parseUnit(attr.Value, heightPercentage)
+		case "width":
+			fo.Bounds.W, err = c.parseUnit(attr.Value, widthPercentage)
+		case "height":
+			fo.Bounds.H, err = c.parseUnit(attr.Value, heightPercentage)
+		}
+		if err != nil {
+			return err
 		}
 	}
+	fo.Transform = c.styleStack[len(c.styleStack)-1].transform
+	c.icon.ForeignObjects = append(c.icon.ForeignObjects, fo)
+	c.skipDepth = 1
 	return nil
 }
@@ -7,10 +7,12 @@ package svgicon
 import (
 	"encoding/xml"
 	"errors"
+	"fmt"
+	"image/color"
 	"io"
+	"io/fs"
 	"os"
-
-	"golang.org/x/net/html/charset"
+	"strings"
 )
 
 // PathStyle holds the state of the SVG style
@@ -23,19 +25,81 @@ type PathStyle struct {
 	Dash                    DashOptions
 	FillerColor, LinerColor Pattern // either PlainColor or Gradient
 
-	transform Matrix2D // current transform
+	// BlendMode is applied to the path only, and not
+	// to the group it belongs to, which is not isolated
+	// (unless it has the isolation property: see LayerDriver).
+	BlendMode BlendMode
+
+	// StrokeAlignment is the position of the stroke
+	// relative to the path (see StrokeOptions).
+	StrokeAlignment StrokeAlignment
+
+	// Filter is applied to each path of a group separately,
+	// which is only an approximation of the SVG model.
+	Filter *Filter // optional
+
+	transform Matrix2D   // current transform
+	clips     []Path     // clipping regions (in icon coordinates), to intersect
+	link      string     // target of the enclosing <a> element
+	color     PlainColor // value of the color property, used by the currentColor keyword
+	id        string     // id attribute of the element (not inherited)
+	classes   []string   // class names of the element and its ancestors, innermost first
+	isolation []int      // identifiers of the enclosing isolated groups, outermost first
+	hidden    bool       // true if the visibility property is hidden or collapse
+	opacity   float64    // product of the opacity properties, used for images
+	noDisplay bool       // true if the display property is none (not inherited)
+	// for a <switch> element, true once one of its children
+	// has been rendered (not inherited)
+	switchDone *bool
+
+	fontSize   float64 // in user units
+	fontFamily string
+	textAnchor TextAnchor
+	// letter-spacing and word-spacing properties, in user units
+	letterSpacing, wordSpacing float64
+
+	// gradients and filters referenced before their definition,
+	// resolved once the whole file is parsed
+	fillRef, strokeRef, filterRef string
 }
 
 // SvgPath binds a style to a path
 type SvgPath struct {
 	Path  Path
 	Style PathStyle
+
+	// Link is the target (href attribute) of the
+	// enclosing <a> element, if any.
+	Link string
+
+	// ID is the id attribute of the element
+	// drawing the path, if any.
+	ID string
+	// Classes are the class names of the element drawing the path
+	// and of its ancestors, innermost first.
+	Classes []string
+
+	// Text, if not nil, is the text drawn by the element, whose
+	// Path is empty. Its glyphs are provided by the drivers implementing
+	// TextDriver, and painted with Style.
+	Text *Text
+
+	// Image, if not nil, is the image drawn by the element, by the drivers
+	// implementing ImageDriver. Path is then the rectangle
+	// covered by the image, which is not painted.
+	Image *Image
 }
 
 // Bounds defines a bounding box, such as a viewport
 // or a path extent.
 type Bounds struct{ X, Y, W, H float64 }
 
+// ForeignObject is the region of a <foreignObject> element.
+type ForeignObject struct {
+	Bounds    Bounds   // in the user space of the element
+	Transform Matrix2D // from the user space of the element to the icon coordinates
+}
+
 // SvgIcon holds data from parsed SVGs.
 // See the `Draw` methods to use it.
 type SvgIcon struct {
@@ -45,10 +109,102 @@ type SvgIcon struct {
 	SVGPaths     []SvgPath
 	Transform    Matrix2D
 
+	// TitleLangs and DescriptionLangs are the xml:lang (or lang) attributes
+	// of the Titles and Descriptions, empty when not specified.
+	TitleLangs, DescriptionLangs []string
+
 	Width, Height string // top level width and height attributes
 
-	grads map[string]*Gradient
-	defs  map[string][]definition
+	// PreserveAspectRatio is the preserveAspectRatio attribute of the
+	// root element, controlling how the view box fits into the viewport.
+	PreserveAspectRatio PreserveAspectRatio
+
+	// ForeignObjects are the regions of the <foreignObject> elements,
+	// whose content is not rendered. Callers may overlay their own content there.
+	ForeignObjects []ForeignObject
+
+	// Raw is the root <svg> element, only set when
+	// ParseOptions.KeepRawTree is true.
+	Raw *RawNode
+
+	grads   map[string]*Gradient
+	filters map[string]*Filter // only the supported ones
+	defs    map[string][]definition
+
+	// references and elements ignored while parsing,
+	// reported by Validate
+	missingGrads, missingUses, unsupported []string
+	// out of range values clamped while parsing,
+	// reported by Validate
+	clamped []clampedValue
+}
+
+// clampedValue is a property value, such as an opacity,
+// clamped to its valid range while parsing
+type clampedValue struct {
+	property string
+	value    float64
+}
+
+// Title returns the title in the language `lang` (such as "en" or "fr-CA"),
+// a title in a variant of that language, or else the first title without
+// language, the first title, or an empty string if the icon has no title.
+func (s *SvgIcon) Title(lang string) string {
+	lang = strings.ToLower(lang)
+	primary := strings.SplitN(lang, "-", 2)[0]
+	best, bestScore := "", -1
+	for i, title := range s.Titles {
+		var titleLang string
+		if i < len(s.TitleLangs) {
+			titleLang = strings.ToLower(s.TitleLangs[i])
+		}
+		score := 0
+		switch {
+		case titleLang == lang:
+			score = 3
+		case lang != "" && strings.SplitN(titleLang, "-", 2)[0] == primary:
+			score = 2
+		case titleLang == "":
+			score = 1
+		}
+		if score > bestScore {
+			best, bestScore = title, score
+		}
+	}
+	return best
+}
+
+// ParseOptions customizes the parsing of SVG files.
+type ParseOptions struct {
+	// ErrorMode determines if the icon ignores, errors out, or logs a warning
+	// if it does not handle an element found in the icon file.
+	ErrorMode ErrorMode
+
+	// CharsetReader, if not nil, is used to decode SVG files
+	// with a non UTF-8 encoding (see encoding/xml.Decoder).
+	// If nil, only UTF-8 (and US-ASCII) files are supported.
+	// ReadIconStream uses DefaultCharsetReader.
+	CharsetReader func(label string, input io.Reader) (io.Reader, error)
+
+	// DefaultFill, if not nil, replaces the black default fill color,
+	// used by the elements without (inherited) fill property.
+	// It is an easy way of rendering monochrome icons with a theme color.
+	DefaultFill color.Color
+
+	// Color, if not nil, replaces black as the initial value of the color
+	// property, to which the currentColor keyword refers.
+	// It is a way of tinting icons designed with currentColor.
+	Color color.Color
+
+	// KeepRawTree, if true, stores the XML element tree in SvgIcon.Raw,
+	// for advanced usage.
+	KeepRawTree bool
+
+	// Scale, if not zero, multiplies every coordinate and length as it is read,
+	// including the view box and the width and height attributes.
+	// It may be used to parse files whose coordinates are out of
+	// the range supported by the fixed point paths (see MaxCoordinate).
+	Scale float64
 }
 
 // ReadIconStream reads the Icon from the given io.Reader
@@ -56,72 +212,186 @@ type SvgIcon struct {
 // is enough to draw many icons. errMode determines if the icon ignores, errors out, or logs a warning
 // if it does not handle an element found in the icon file.
 func ReadIconStream(stream io.Reader, errMode ErrorMode) (*SvgIcon, error) {
-	icon := &SvgIcon{defs: make(map[string][]definition), grads: make(map[string]*Gradient), Transform: Identity}
-	cursor := &iconCursor{styleStack: []PathStyle{DefaultStyle}, icon: icon}
-	cursor.errorMode = errMode
+	return ReadIconStreamOptions(stream, ParseOptions{ErrorMode: errMode, CharsetReader: DefaultCharsetReader})
+}
+
+// ReadIconStreamOptions is the same as ReadIconStream, but
+// uses the given options.
+func ReadIconStreamOptions(stream io.Reader, options ParseOptions) (*SvgIcon, error) {
+	icon := &SvgIcon{defs: make(map[string][]definition), grads: make(map[string]*Gradient),
+		filters: make(map[string]*Filter), Transform: Identity}
+	rootStyle := DefaultStyle
+	if options.DefaultFill != nil {
+		rootStyle.FillerColor = PlainColor{color.NRGBAModel.Convert(options.DefaultFill).(color.NRGBA)}
+	}
+	if options.Color != nil {
+		rootStyle.color = PlainColor{color.NRGBAModel.Convert(options.Color).(color.NRGBA)}
+	}
+	if options.Scale != 0 {
+		rootStyle.LineWidth *= options.Scale
+		rootStyle.fontSize *= options.Scale
+	}
+	cursor := &iconCursor{styleStack: []PathStyle{rootStyle}, icon: icon}
+	cursor.errorMode = options.ErrorMode
+	cursor.scale = options.Scale
 	decoder := xml.NewDecoder(stream)
-	decoder.CharsetReader = charset.NewReaderLabel
+	decoder.CharsetReader = options.CharsetReader
+	var raw *rawTreeBuilder
+	if options.KeepRawTree {
+		raw = new(rawTreeBuilder)
+	}
 	seenTag := false
 	for {
 		t, err := decoder.Token()
 		if err != nil {
 			if err == io.EOF {
 				if !seenTag {
-					return nil, errors.New("invalid svg xml icon")
+					return nil, XMLError{Err: errors.New("no root element")}
 				}
 				break
 			}
-			return icon, err
+			return icon, wrapDecoderError(err)
 		}
 		// Inspect the type of the XML token
 		switch se := t.(type) {
 		case xml.StartElement:
 			seenTag = true
+			if raw != nil {
+				raw.start(se)
+			}
+			if cursor.skipDepth > 0 { // inside an element whose content is ignored
+				cursor.skipDepth++
+				continue
+			}
+			if se.Name.Local == "script" {
+				// scripts are not supported: skip their content,
+				// which is not SVG
+				if err = decoder.Skip(); err != nil {
+					return icon, wrapDecoderError(err)
+				}
+				if raw != nil {
+					raw.end()
+				}
+				continue
+			}
 			// Reads all recognized style attributes from the start element
 			// and places it on top of the styleStack
-			err = cursor.pushStyle(se.Attr)
+			err = cursor.pushStyle(se.Name.Local, se.Attr)
 			if err != nil {
-				return icon, err
+				return icon, ParseError{Element: se.Name.Local, Err: err}
 			}
 			err = cursor.readStartElement(se)
 			if err != nil {
-				return icon, err
+				return icon, ParseError{Element: se.Name.Local, Err: err}
 			}
 		case xml.EndElement:
+			if raw != nil {
+				raw.end()
+			}
+			if cursor.skipDepth > 1 {
+				cursor.skipDepth--
+				continue
+			}
+			cursor.skipDepth = 0 // closing the skipped element itself, if any
+			if se.Name.Local == "text" && cursor.inText {
+				// before popping the style, which may apply a clip
+				// to the paths of the element
+				cursor.closeText()
+			}
 			// pop style
-			cursor.styleStack = cursor.styleStack[:len(cursor.styleStack)-1]
-			switch se.Name.Local {
-			case "g":
-				if cursor.inDefs {
-					cursor.currentDef = append(cursor.currentDef, definition{
-						Tag: "endg",
-					})
+			if err = cursor.popStyle(); err != nil {
+				return icon, ParseError{Element: se.Name.Local, Err: err}
+			}
+			if cursor.inDefs {
+				cursor.endDefElement()
+				if cursor.inSymbol && len(cursor.defsRecorded) == 0 {
+					// a top level symbol is complete
+					cursor.closeDefs()
+					cursor.inSymbol = false
 				}
+			}
+			switch se.Name.Local {
 			case "title":
 				cursor.inTitleText = false
 			case "desc":
 				cursor.inDescText = false
-			case "defs":
-				if len(cursor.currentDef) > 0 {
-					cursor.icon.defs[cursor.currentDef[0].ID] = cursor.currentDef
-					cursor.currentDef = make([]definition, 0)
+			case "style":
+				if cursor.inStyleText {
+					cursor.cssRules = append(cursor.cssRules, parseStyleSheet(cursor.styleText)...)
+					cursor.inStyleText = false
 				}
-				cursor.inDefs = false
+			case "defs":
+				cursor.closeDefs()
 			case "radialGradient", "linearGradient":
-				cursor.inGrad = false
+				cursor.closeGradient()
+			case "filter":
+				cursor.closeFilter()
 			}
 		case xml.CharData:
+			if raw != nil {
+				raw.charData(string(se))
+			}
 			if cursor.inTitleText {
 				icon.Titles[len(icon.Titles)-1] += string(se)
 			}
 			if cursor.inDescText {
 				icon.Descriptions[len(icon.Descriptions)-1] += string(se)
 			}
+			if cursor.inStyleText {
+				cursor.styleText += string(se)
+			}
+			if cursor.inDefs {
+				cursor.addDefCharData(string(se))
+			} else if cursor.skipDepth == 0 {
+				cursor.addTextContent(string(se))
+			}
 		}
 	}
+	if raw != nil {
+		icon.Raw = raw.root
+	}
+	cursor.resolveGradientLinks()
+	cursor.resolveGradientRefs()
+	cursor.resolveFilterRefs()
+	cursor.normalizeGradients()
+	if cursor.rootUnsized {
+		// the viewport defaults to 100% of an unknown container:
+		// use the content instead, so that the icon is renderable
+		icon.ViewBox, _ = pathsBounds(icon.SVGPaths, Identity, false)
+	}
 	return icon, nil
 }
 
+// DecodeConfig only reads the dimensions of the SVG image
+// from `stream`, that is, the view box and the width and height attributes
+// of the root element.
+// The input is consumed until the root <svg> element only, which is much faster
+// than a full parsing with ReadIconStream.
+func DecodeConfig(stream io.Reader) (viewBox Bounds, width, height string, err error) {
+	icon := &SvgIcon{}
+	cursor := &iconCursor{icon: icon}
+	decoder := xml.NewDecoder(stream)
+	decoder.CharsetReader = DefaultCharsetReader
+	for {
+		t, err := decoder.Token()
+		if err != nil {
+			if err == io.EOF {
+				return viewBox, "", "", XMLError{Err: errors.New("no root element")}
+			}
+			return viewBox, "", "", wrapDecoderError(err)
+		}
+		if se, ok := t.(xml.StartElement); ok {
+			if se.Name.Local != "svg" {
+				return viewBox, "", "", ParseError{Element: se.Name.Local, Err: errors.New("missing root <svg> element")}
+			}
+			if err = svgF(cursor, se.Attr); err != nil {
+				err = ParseError{Element: "svg", Err: err}
+			}
+			return icon.ViewBox, icon.Width, icon.Height, err
+		}
+	}
+}
+
 // ReadIcon reads the Icon from the named file
 // This only supports a sub-set of SVG, but
 // is enough to draw many icons. errMode determines if the icon ignores, errors out, or logs a warning
@@ -129,7 +399,18 @@ func ReadIconStream(stream io.Reader, errMode ErrorMode) (*SvgIcon, error) {
 func ReadIcon(iconFile string, errMode ErrorMode) (*SvgIcon, error) {
 	fin, errf := os.Open(iconFile)
 	if errf != nil {
-		return nil, errf
+		return nil, fmt.Errorf("opening icon: %w", errf)
+	}
+	defer fin.Close()
+	return ReadIconStream(fin, errMode)
+}
+
+// ReadIconFS is the same as ReadIcon, but opens the named
+// file from `fsys`, such as an embed.FS.
+func ReadIconFS(fsys fs.FS, name string, errMode ErrorMode) (*SvgIcon, error) {
+	fin, errf := fsys.Open(name)
+	if errf != nil {
+		return nil, fmt.Errorf("opening icon: %w", errf)
 	}
 	defer fin.Close()
 	return ReadIconStream(fin, errMode)
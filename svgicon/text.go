@@ -0,0 +1,133 @@
+package svgicon
+
+import (
+	"encoding/xml"
+	"strconv"
+	"strings"
+)
+
+// this file implements a basic support of the <text> element
+
+// TextAnchor is the alignment of a text
+// relative to its position (see the text-anchor property).
+type TextAnchor uint8
+
+const (
+	AnchorStart TextAnchor = iota
+	AnchorMiddle
+	AnchorEnd
+)
+
+// Text is a run of characters, from a <text> element
+// or from one of its <tspan> children with its own position.
+//
+// Only a basic subset of SVG text is supported: each run is laid out
+// on one line, from a single position. Per-glyph positions,
+// relative shifts (dx, dy), rotations and decorations are ignored, as well as
+// the style of the <tspan> elements without x or y attributes.
+type Text struct {
+	X, Y       float64 // position of the anchor, on the baseline, in user units
+	Content    string  // with white spaces collapsed
+	FontSize   float64 // in user units
+	FontFamily string  // the font-family property, as written
+	Anchor     TextAnchor
+
+	// extra spaces, in user units, added after each character,
+	// and after each space character (see the letter-spacing and word-spacing properties)
+	LetterSpacing, WordSpacing float64
+}
+
+// DefaultFontSize is the initial value of the font-size property,
+// in user units.
+const DefaultFontSize = 16.
+
+func textF(c *iconCursor, attrs []xml.Attr) error {
+	c.inText = true
+	c.textRuns = c.textRuns[:0]
+	return c.startTextRun(attrs, false)
+}
+
+func tspanF(c *iconCursor, attrs []xml.Attr) error {
+	if !c.inText { // only supported inside <text>
+		return nil
+	}
+	return c.startTextRun(attrs, true)
+}
+
+// startTextRun starts a new run, whose characters are read from the following
+// character data, unless `isSpan` is true and the element has no position.
+func (c *iconCursor) startTextRun(attrs []xml.Attr, isSpan bool) error {
+	var (
+		text   Text
+		hasPos bool
+		err    error
+	)
+	if L := len(c.textRuns); L != 0 { // the position defaults to the one of the current run
+		text.X, text.Y = c.textRuns[L-1].Text.X, c.textRuns[L-1].Text.Y
+	}
+	for _, attr := range attrs {
+		// only the first value of a list of coordinates is used
+		switch attr.Name.Local {
+		case "x":
+			text.X, err = c.parseUnit(firstCoordinate(attr.Value), widthPercentage)
+			hasPos = true
+		case "y":
+			text.Y, err = c.parseUnit(firstCoordinate(attr.Value), heightPercentage)
+			hasPos = true
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if isSpan && !hasPos {
+		return nil
+	}
+	style := c.styleStack[len(c.styleStack)-1]
+	text.FontSize, text.FontFamily, text.Anchor = style.fontSize, style.fontFamily, style.textAnchor
+	text.LetterSpacing, text.WordSpacing = style.letterSpacing, style.wordSpacing
+	c.textRuns = append(c.textRuns, SvgPath{Text: &text, Style: style, Link: style.link, ID: style.id, Classes: style.classes})
+	return nil
+}
+
+// firstCoordinate returns the first item of the list `v`.
+func firstCoordinate(v string) string {
+	if fields := splitOnCommaOrSpace(v); len(fields) != 0 {
+		return fields[0]
+	}
+	return v
+}
+
+// addTextContent appends `data` to the current text run, if any.
+func (c *iconCursor) addTextContent(data string) {
+	if L := len(c.textRuns); c.inText && L != 0 {
+		c.textRuns[L-1].Text.Content += data
+	}
+}
+
+// closeText stores the runs of the current <text> element,
+// ignoring the empty and invisible ones.
+func (c *iconCursor) closeText() {
+	for _, run := range c.textRuns {
+		// the white spaces are handled as with xml:space="default"
+		run.Text.Content = strings.Join(strings.Fields(run.Text.Content), " ")
+		if run.Text.Content != "" && !run.Style.hidden {
+			c.icon.SVGPaths = append(c.icon.SVGPaths, run)
+		}
+	}
+	c.textRuns = c.textRuns[:0]
+	c.inText = false
+}
+
+// parseFontSize parses the font-size property, where
+// percentages and 'em' refer to the parent font size.
+func (c *iconCursor) parseFontSize(v string, parent float64) (float64, error) {
+	switch {
+	case strings.HasSuffix(v, "em") && !strings.HasSuffix(v, "rem"):
+		f, err := strconv.ParseFloat(strings.TrimSpace(strings.TrimSuffix(v, "em")), 64)
+		return f * parent, err
+	case strings.HasSuffix(v, "%"):
+		f, err := strconv.ParseFloat(strings.TrimSpace(strings.TrimSuffix(v, "%")), 64)
+		return f / 100 * parent, err
+	}
+	return c.parseUnit(v, diagPercentage)
+}
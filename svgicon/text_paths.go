@@ -0,0 +1,127 @@
+package svgicon
+
+import (
+	"errors"
+	"math"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/sfnt"
+	"golang.org/x/image/math/fixed"
+)
+
+// Outlines returns the outlines of the glyphs of the text, taken from `f`,
+// laid out on one line, with kerning and the letter and word spacings.
+// The glyphs which can't be loaded are skipped, and the first error is returned.
+func (text Text) Outlines(f *sfnt.Font) (Path, error) {
+	var (
+		buf      sfnt.Buffer
+		firstErr error
+	)
+	checkErr := func(err error) bool {
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+		return err != nil
+	}
+	// load the glyphs in font units, for precision
+	ppem := fixed.Int26_6(f.UnitsPerEm()) << 6
+	scale := text.FontSize / float64(f.UnitsPerEm())
+
+	type placedGlyph struct {
+		segments sfnt.Segments
+		x        fixed.Int26_6 // start of the glyph on the baseline
+	}
+	var (
+		glyphs []placedGlyph
+		x      fixed.Int26_6
+		prev   sfnt.GlyphIndex
+	)
+	// the spacings are included in the advances, so that they
+	// are taken into account by the text anchor
+	letterSpacing := fixed.Int26_6(math.Round(text.LetterSpacing / scale * 64))
+	wordSpacing := fixed.Int26_6(math.Round(text.WordSpacing / scale * 64))
+	for _, r := range text.Content {
+		index, err := f.GlyphIndex(&buf, r)
+		if checkErr(err) {
+			continue
+		}
+		if prev != 0 {
+			if kern, err := f.Kern(&buf, prev, index, ppem, font.HintingNone); err == nil {
+				x += kern
+			}
+		}
+		segments, err := f.LoadGlyph(&buf, index, ppem, nil)
+		if checkErr(err) {
+			continue
+		}
+		// the segments are only valid until the next call
+		glyphs = append(glyphs, placedGlyph{append(sfnt.Segments(nil), segments...), x})
+		if advance, err := f.GlyphAdvance(&buf, index, ppem, font.HintingNone); err == nil {
+			x += advance
+		}
+		x += letterSpacing
+		if r == ' ' {
+			x += wordSpacing
+		}
+		prev = index
+	}
+
+	originX := text.X
+	switch text.Anchor {
+	case AnchorMiddle:
+		originX -= float64(x) / 64 * scale / 2
+	case AnchorEnd:
+		originX -= float64(x) / 64 * scale
+	}
+	toPath := func(dx fixed.Int26_6, p fixed.Point26_6) fixed.Point26_6 {
+		return fixed.Point26_6{
+			X: fixed.Int26_6(math.Round((originX + float64(p.X+dx)/64*scale) * 64)),
+			Y: fixed.Int26_6(math.Round((text.Y + float64(p.Y)/64*scale) * 64)),
+		}
+	}
+	var path Path
+	for _, glyph := range glyphs {
+		for i, seg := range glyph.segments {
+			switch seg.Op {
+			case sfnt.SegmentOpMoveTo:
+				if i != 0 { // contours are implicitly closed
+					path.Stop(true)
+				}
+				path.Start(toPath(glyph.x, seg.Args[0]))
+			case sfnt.SegmentOpLineTo:
+				path.Line(toPath(glyph.x, seg.Args[0]))
+			case sfnt.SegmentOpQuadTo:
+				path.QuadBezier(toPath(glyph.x, seg.Args[0]), toPath(glyph.x, seg.Args[1]))
+			case sfnt.SegmentOpCubeTo:
+				path.CubeBezier(toPath(glyph.x, seg.Args[0]), toPath(glyph.x, seg.Args[1]), toPath(glyph.x, seg.Args[2]))
+			}
+		}
+		if len(glyph.segments) != 0 {
+			path.Stop(true)
+		}
+	}
+	return path, firstErr
+}
+
+// ConvertTextToPaths replaces the texts of `icon` by the outlines of
+// their glyphs, taken from `f` (see Text.Outlines), so that the icon
+// may be drawn by any driver, including the ones not implementing TextDriver,
+// such as the PDF one. The style of the texts is kept.
+// A *sfnt.Font is required, since a font.Face does not expose the glyph outlines.
+func ConvertTextToPaths(icon *SvgIcon, f *sfnt.Font) error {
+	if f == nil {
+		return errors.New("no font provided to convert the texts")
+	}
+	var firstErr error
+	for i, svgp := range icon.SVGPaths {
+		if svgp.Text == nil {
+			continue
+		}
+		path, err := svgp.Text.Outlines(f)
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+		icon.SVGPaths[i].Path, icon.SVGPaths[i].Text = path, nil
+	}
+	return firstErr
+}
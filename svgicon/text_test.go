@@ -0,0 +1,178 @@
+package svgicon
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/sfnt"
+	"golang.org/x/image/math/fixed"
+)
+
+func TestParseText(t *testing.T) {
+	icon, err := ReadIconStream(strings.NewReader(`<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 100 100">
+	<g font-size="20" font-family="Arial, sans-serif" fill="red">
+		<text x="10 20 30" y="50%" text-anchor="middle">
+			Hello   <tspan fill="blue">big</tspan>
+			<tspan x="10" dy="12" font-size="1.5em">World</tspan>
+		</text>
+		<text style="font-size: 50%; text-anchor: end">Small</text>
+		<text visibility="hidden">Hidden</text>
+		<text x="5" y="5">  </text>
+	</g>
+	<text>Default</text>
+	</svg>`), StrictErrorMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(icon.SVGPaths) != 4 {
+		t.Fatalf("expected 4 texts, got %d", len(icon.SVGPaths))
+	}
+	for i, exp := range []Text{
+		{X: 10, Y: 50, Content: "Hello big", FontSize: 20, FontFamily: "Arial, sans-serif", Anchor: AnchorMiddle},
+		{X: 10, Y: 50, Content: "World", FontSize: 30, FontFamily: "Arial, sans-serif", Anchor: AnchorMiddle},
+		{Content: "Small", FontSize: 10, FontFamily: "Arial, sans-serif", Anchor: AnchorEnd},
+		{Content: "Default", FontSize: DefaultFontSize},
+	} {
+		svgp := icon.SVGPaths[i]
+		if svgp.Text == nil || *svgp.Text != exp {
+			t.Errorf("expected %v, got %v", exp, svgp.Text)
+		}
+		if len(svgp.Path) != 0 {
+			t.Errorf("unexpected path %s", svgp.Path)
+		}
+	}
+	// the style of a tspan without position is ignored
+	if fill := icon.SVGPaths[0].Style.FillerColor; fill != NewPlainColor(0xff, 0, 0, 0xff) {
+		t.Errorf("unexpected fill %v", fill)
+	}
+
+	scaled, err := ReadIconStreamOptions(strings.NewReader(`<svg viewBox="0 0 100 100"><text>A</text></svg>`), ParseOptions{Scale: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size := scaled.SVGPaths[0].Text.FontSize; size != 2*DefaultFontSize {
+		t.Errorf("expected a scaled font size, got %g", size)
+	}
+
+	spaced, err := ReadIconStream(strings.NewReader(`<svg viewBox="0 0 100 100">
+	<g letter-spacing="2" word-spacing="0.5em" font-size="10">
+		<text>A B<tspan x="0" word-spacing="normal">C D</tspan></text>
+	</g></svg>`), StrictErrorMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text := spaced.SVGPaths[0].Text; text.LetterSpacing != 2 || text.WordSpacing != 5 {
+		t.Errorf("unexpected spacings %g, %g", text.LetterSpacing, text.WordSpacing)
+	}
+	if text := spaced.SVGPaths[1].Text; text.LetterSpacing != 2 || text.WordSpacing != 0 {
+		t.Errorf("unexpected spacings %g, %g", text.LetterSpacing, text.WordSpacing)
+	}
+
+	if _, err = ReadIconStream(strings.NewReader(`<svg viewBox="0 0 100 100"><text text-anchor="left">A</text></svg>`), StrictErrorMode); err == nil {
+		t.Error("expected an error for an invalid text-anchor")
+	}
+}
+
+// squareTexter draws each text as a square
+type squareTexter struct{ recorder }
+
+func (squareTexter) TextPath(text Text) Path {
+	var p Path
+	p.addRect(text.X, text.Y-text.FontSize, text.X+text.FontSize, text.Y, 0)
+	return p
+}
+
+func TestDrawText(t *testing.T) {
+	icon, err := ReadIconStream(strings.NewReader(`<svg viewBox="0 0 100 100">
+	<text x="10" y="20" font-size="10">A</text>
+	</svg>`), StrictErrorMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var rec recorder
+	icon.Draw(&rec, 1)
+	if len(rec.fills) != 0 {
+		t.Errorf("texts should be ignored by drivers not implementing TextDriver, got %v", rec.fills)
+	}
+
+	var texter squareTexter
+	icon.Draw(&texter, 1)
+	if len(texter.fills) != 1 {
+		t.Fatalf("expected one filled text, got %v", texter.fills)
+	}
+	if start := texter.fills[0][0]; start != OpMoveTo(fixed.P(10, 10)) {
+		t.Errorf("unexpected path start %v", start)
+	}
+}
+
+func TestTextInUse(t *testing.T) {
+	icon, err := ReadIconStream(strings.NewReader(`<svg viewBox="0 0 100 100">
+	<defs>
+		<g id="label" font-size="10">
+			<text x="5" y="10">Hello <tspan fill="blue">big</tspan> <tspan x="5" y="30">World</tspan></text>
+		</g>
+	</defs>
+	<use href="#label" y="20"/>
+	<text>After</text>
+	</svg>`), StrictErrorMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(icon.SVGPaths) != 3 {
+		t.Fatalf("expected 3 texts, got %d", len(icon.SVGPaths))
+	}
+	for i, exp := range []Text{
+		{X: 5, Y: 10, Content: "Hello big", FontSize: 10},
+		{X: 5, Y: 30, Content: "World", FontSize: 10},
+		{Content: "After", FontSize: DefaultFontSize},
+	} {
+		if got := icon.SVGPaths[i].Text; got == nil || *got != exp {
+			t.Errorf("expected %v, got %v", exp, got)
+		}
+	}
+	// the position of the use element is applied
+	if x, y := icon.SVGPaths[0].Style.transform.Transform(5, 10); x != 5 || y != 30 {
+		t.Errorf("unexpected text position (%g, %g)", x, y)
+	}
+}
+
+func TestConvertTextToPaths(t *testing.T) {
+	icon, err := ReadIconStream(strings.NewReader(`<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 100 100">
+	<rect width="10" height="10"/>
+	<text x="50" y="60" fill="red">Go<tspan x="10" y="90">text</tspan></text>
+	</svg>`), StrictErrorMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = ConvertTextToPaths(icon, nil); err == nil {
+		t.Error("expected an error without font")
+	}
+
+	f, err := sfnt.Parse(goregular.TTF)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = ConvertTextToPaths(icon, f); err != nil {
+		t.Fatal(err)
+	}
+	if len(icon.SVGPaths) != 3 {
+		t.Fatalf("unexpected paths %v", icon.SVGPaths)
+	}
+	for _, svgp := range icon.SVGPaths[1:] {
+		if svgp.Text != nil || len(svgp.Path) == 0 {
+			t.Errorf("expected the glyph outlines, got %v", svgp)
+		}
+		if svgp.Style.FillerColor != NewPlainColor(0xff, 0, 0, 0xff) {
+			t.Errorf("unexpected fill %v", svgp.Style.FillerColor)
+		}
+	}
+
+	// the texts are now drawn by drivers without text support
+	var rec recorder
+	icon.Draw(&rec, 1)
+	if len(rec.fills) != 3 {
+		t.Errorf("expected 3 fills, got %d", len(rec.fills))
+	}
+}
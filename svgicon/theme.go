@@ -0,0 +1,33 @@
+package svgicon
+
+import "image/color"
+
+// ApplyTheme replaces the fill color of the paths matching one of the keys of `byClass`,
+// which are class names, or element ids prefixed by '#'. The classes of the ancestors
+// of an element are also considered, the innermost matching one being used.
+// The alpha channel of the original color and the fill opacity are preserved,
+// and paths with no fill, or filled with a gradient, are not modified.
+func (s *SvgIcon) ApplyTheme(byClass map[string]color.Color) {
+	for i := range s.SVGPaths {
+		svgp := &s.SVGPaths[i]
+		fill, ok := svgp.Style.FillerColor.(PlainColor)
+		if !ok {
+			continue
+		}
+		themeColor, ok := byClass["#"+svgp.ID]
+		if !ok || svgp.ID == "" {
+			ok = false
+			for _, class := range svgp.Classes {
+				if themeColor, ok = byClass[class]; ok {
+					break
+				}
+			}
+		}
+		if !ok {
+			continue
+		}
+		c := color.NRGBAModel.Convert(themeColor).(color.NRGBA)
+		c.A = uint8(uint16(c.A) * uint16(fill.A) / 0xff)
+		svgp.Style.FillerColor = PlainColor{c}
+	}
+}
@@ -54,10 +54,44 @@ const (
 	diagPercentage
 )
 
+// viewportUnits are relative to the viewBox, and
+// must be checked before the absolute units ('vmin' ends with 'in')
+var viewportUnits = [...]string{"vmin", "vmax", "vw", "vh"}
+
+// resolveViewportUnit converts a length with one of the viewport units into its value in 'px',
+// where 1vw (resp. 1vh) is 1% of the viewBox width (resp. height), and
+// 1vmin (resp. 1vmax) is the smaller (resp. larger) of 1vw and 1vh.
+// `ok` is false if `s` does not use a viewport unit.
+func (viewBox Bounds) resolveViewportUnit(s string) (value float64, ok bool, err error) {
+	s = strings.TrimSpace(s)
+	for _, suffix := range viewportUnits {
+		if !strings.HasSuffix(s, suffix) {
+			continue
+		}
+		value, err = strconv.ParseFloat(strings.TrimSpace(strings.TrimSuffix(s, suffix)), 64)
+		var unit float64
+		switch suffix {
+		case "vw":
+			unit = viewBox.W
+		case "vh":
+			unit = viewBox.H
+		case "vmin":
+			unit = math.Min(viewBox.W, viewBox.H)
+		case "vmax":
+			unit = math.Max(viewBox.W, viewBox.H)
+		}
+		return value * unit / 100, true, err
+	}
+	return 0, false, nil
+}
+
 // resolveUnit converts a length with a unit into its value in 'px'
-// percentage are supported, and refer to the viewBox
+// percentage and viewport units are supported, and refer to the viewBox
 // `asPerc` is only applied when `s` contains a percentage.
 func (viewBox Bounds) resolveUnit(s string, asPerc percentageReference) (float64, error) {
+	if value, ok, err := viewBox.resolveViewportUnit(s); ok {
+		return value, err
+	}
 	value, isPercentage, err := parseUnit(s)
 	if err != nil {
 		return 0, err
@@ -79,8 +113,26 @@ func (viewBox Bounds) resolveUnit(s string, asPerc percentageReference) (float64
 
 // parseUnit converts a length with a unit into its value in 'px'
 // percentage are supported, and refer to the current ViewBox
+// The parse time scale factor is applied to the result.
 func (c *iconCursor) parseUnit(s string, asPerc percentageReference) (float64, error) {
-	return c.icon.ViewBox.resolveUnit(s, asPerc)
+	if c.scale == 0 {
+		return c.icon.ViewBox.resolveUnit(s, asPerc)
+	}
+	// the view box is already scaled
+	viewBox := Bounds{X: c.icon.ViewBox.X / c.scale, Y: c.icon.ViewBox.Y / c.scale,
+		W: c.icon.ViewBox.W / c.scale, H: c.icon.ViewBox.H / c.scale}
+	v, err := viewBox.resolveUnit(s, asPerc)
+	return v * c.scale, err
+}
+
+// parseAbsoluteLength is the same as parseBasicFloat,
+// but returns 0 for percentages.
+func parseAbsoluteLength(s string) (float64, error) {
+	value, isPercentage, err := parseUnit(s)
+	if isPercentage {
+		return 0, err
+	}
+	return value, err
 }
 
 func parseBasicFloat(s string) (float64, error) {
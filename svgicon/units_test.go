@@ -40,3 +40,29 @@ func TestUnits(t *testing.T) {
 		}
 	}
 }
+
+func TestViewportUnits(t *testing.T) {
+	viewBox := Bounds{X: 10, Y: 10, W: 200, H: 50}
+	for s, exp := range map[string]float64{
+		"10vw":    20,
+		"10 vh":   5,
+		"50vmin":  25,
+		"50vmax":  100,
+		"-2.5vw":  -5,
+		"10%":     20, // percentages are still supported
+		"1in":     96,
+		"20":      20,
+		"100vmin": 50,
+	} {
+		value, err := viewBox.resolveUnit(s, widthPercentage)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !almostEqual(value, exp) {
+			t.Errorf("for %s, expected %g, got %g", s, exp, value)
+		}
+	}
+	if _, err := viewBox.resolveUnit("xvw", widthPercentage); err == nil {
+		t.Error("expected an error for an invalid value")
+	}
+}
@@ -0,0 +1,104 @@
+package svgicon
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Severity indicates how serious an Issue is.
+type Severity uint8
+
+const (
+	// SeverityWarning is used for constructs which are rendered,
+	// but probably not as intended
+	SeverityWarning Severity = iota
+	// SeverityError is used for constructs which are
+	// invalid, or ignored when rendering
+	SeverityError
+)
+
+func (s Severity) String() string {
+	if s == SeverityError {
+		return "error"
+	}
+	return "warning"
+}
+
+// Issue describes a problem found by Validate.
+type Issue struct {
+	Severity Severity
+	Message  string
+}
+
+func (i Issue) String() string {
+	return i.Severity.String() + ": " + i.Message
+}
+
+// Validate analyses the parsed icon, and reports the problems
+// found with respect to the SVG specification, such as
+// references to undefined gradients or elements, or invalid values.
+// Note that the icon must be parsed with IgnoreErrorMode or WarnErrorMode,
+// since StrictErrorMode reports some of these problems as parsing errors.
+func (s *SvgIcon) Validate() []Issue {
+	var issues []Issue
+	addf := func(severity Severity, format string, args ...interface{}) {
+		issues = append(issues, Issue{Severity: severity, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if s.ViewBox.W <= 0 || s.ViewBox.H <= 0 {
+		addf(SeverityError, "the view box has a zero size (%g x %g)", s.ViewBox.W, s.ViewBox.H)
+	}
+
+	for _, id := range uniqueStrings(s.missingGrads) {
+		addf(SeverityError, "gradient #%s is referenced but not defined", id)
+	}
+	for _, id := range uniqueStrings(s.missingUses) {
+		addf(SeverityError, "the target #%s of a <use> element is not defined", id)
+	}
+	for _, tag := range uniqueStrings(s.unsupported) {
+		addf(SeverityWarning, "the element <%s> is not supported and has been ignored", tag)
+	}
+
+	clamped := make([]string, len(s.clamped))
+	for i, v := range s.clamped {
+		clamped[i] = fmt.Sprintf("%s %g", strings.ReplaceAll(v.property, "-", " "), v.value)
+	}
+	for _, v := range uniqueStrings(clamped) {
+		addf(SeverityWarning, "%s is out of range [0, 1] and has been clamped", v)
+	}
+
+	gradIDs := make([]string, 0, len(s.grads))
+	for id := range s.grads {
+		gradIDs = append(gradIDs, id)
+	}
+	sort.Strings(gradIDs)
+	for _, id := range gradIDs {
+		grad := s.grads[id]
+		for i, stop := range grad.Stops {
+			if i > 0 && stop.Offset < grad.Stops[i-1].Offset {
+				addf(SeverityWarning, "gradient #%s: stop %d has an offset (%g) smaller than the previous one (%g)",
+					id, i, stop.Offset, grad.Stops[i-1].Offset)
+			}
+			if stop.Opacity < 0 || stop.Opacity > 1 {
+				addf(SeverityWarning, "gradient #%s: stop %d has an opacity %g out of range [0, 1]", id, i, stop.Opacity)
+			}
+		}
+	}
+
+	return issues
+}
+
+// uniqueStrings returns the sorted, distinct values of `list`
+func uniqueStrings(list []string) []string {
+	seen := make(map[string]bool, len(list))
+	var out []string
+	for _, s := range list {
+		if !seen[s] {
+			seen[s] = true
+			out = append(out, s)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
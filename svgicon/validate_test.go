@@ -0,0 +1,98 @@
+package svgicon
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	for _, test := range []struct {
+		src      string
+		severity Severity
+		contains string
+	}{
+		{
+			`<svg viewBox="0 0 0 10"><rect width="5" height="5"/></svg>`,
+			SeverityError, "view box has a zero size",
+		},
+		{
+			`<svg viewBox="0 0 10 10"><rect width="5" height="5" fill="url(#missing)"/></svg>`,
+			SeverityError, "gradient #missing is referenced",
+		},
+		{
+			`<svg viewBox="0 0 10 10"><use href="#missing"/></svg>`,
+			SeverityError, "target #missing of a <use>",
+		},
+		{
+			`<svg viewBox="0 0 10 10"><rect width="5" height="5" fill-opacity="1.5"/></svg>`,
+			SeverityWarning, "fill opacity 1.5 is out of range",
+		},
+		{
+			`<svg viewBox="0 0 10 10"><rect width="5" height="5" stroke="red" stroke-opacity="-1"/></svg>`,
+			SeverityWarning, "stroke opacity -1 is out of range",
+		},
+		{
+			`<svg viewBox="0 0 10 10"><linearGradient id="g">
+				<stop offset="0.8" stop-color="red"/><stop offset="0.2" stop-color="blue"/>
+			</linearGradient></svg>`,
+			SeverityWarning, "gradient #g: stop 1 has an offset (0.2) smaller",
+		},
+		{
+			`<svg viewBox="0 0 10 10"><linearGradient id="g">
+				<stop offset="0" stop-color="red" stop-opacity="2"/>
+			</linearGradient></svg>`,
+			SeverityWarning, "gradient #g: stop 0 has an opacity 2 out of range",
+		},
+		{
+			`<svg viewBox="0 0 10 10"><mask id="a"/><mask id="b"/></svg>`,
+			SeverityWarning, "element <mask> is not supported",
+		},
+		{
+			`<svg viewBox="0 0 10 10"><defs><mask id="m"/></defs><use href="#m"/></svg>`,
+			SeverityWarning, "element <mask> is not supported",
+		},
+	} {
+		icon, err := ReadIconStream(strings.NewReader(test.src), IgnoreErrorMode)
+		if err != nil {
+			t.Fatal(err)
+		}
+		issues := icon.Validate()
+		if len(issues) != 1 {
+			t.Fatalf("expected one issue for %s, got %v", test.src, issues)
+		}
+		if issues[0].Severity != test.severity || !strings.Contains(issues[0].Message, test.contains) {
+			t.Errorf("unexpected issue %s for %s", issues[0], test.src)
+		}
+	}
+}
+
+func TestValidateValidIcon(t *testing.T) {
+	icon, err := ReadIcon("testdata/testIcons/defs.svg", IgnoreErrorMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if issues := icon.Validate(); len(issues) != 0 {
+		t.Errorf("expected no issues, got %v", issues)
+	}
+}
+
+func TestValidateTextInDefs(t *testing.T) {
+	const src = `<svg viewBox="0 0 10 10"><defs><text id="t" font-size="5">Hello</text></defs><use href="#t" x="2"/></svg>`
+	icon, err := ReadIconStream(strings.NewReader(src), StrictErrorMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if issues := icon.Validate(); len(issues) != 0 {
+		t.Errorf("expected no issues, got %v", issues)
+	}
+	if len(icon.SVGPaths) != 1 || icon.SVGPaths[0].Text == nil || icon.SVGPaths[0].Text.Content != "Hello" {
+		t.Fatalf("expected the used text, got %v", icon.SVGPaths)
+	}
+}
+
+func TestUseMissingTarget(t *testing.T) {
+	const src = `<svg viewBox="0 0 10 10"><use href="#missing"/></svg>`
+	if _, err := ReadIconStream(strings.NewReader(src), StrictErrorMode); err == nil {
+		t.Error("expected an error in strict mode")
+	}
+}
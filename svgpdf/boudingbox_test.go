@@ -15,36 +15,37 @@ func randPoint(offsetx, offsety int) fixed.Point26_6 {
 	return fixed.Point26_6{X: fixed.Int26_6(x + offsetx), Y: fixed.Int26_6(y + offsety)}
 }
 
-func generateDrawCurve(p pather, order int, offsetx, offsety int) bezier {
+// generateDrawCurve draws a random curve and returns its bounding box
+func generateDrawCurve(p pather, order int, offsetx, offsety int) fixed.Rectangle26_6 {
+	var bb BoundingBox
 	a := randPoint(offsetx, offsety)
 	b := randPoint(offsetx, offsety)
 	p.Start(a)
+	bb.Start(a)
 	switch order {
 	case 1:
 		p.Line(b)
-		return line{a, b}
+		bb.Line(b)
 	case 2:
 		c := randPoint(offsetx, offsety)
 		p.QuadBezier(b, c)
-		return quadBezier{a, b, c}
+		bb.QuadBezier(b, c)
 	case 3:
 		c := randPoint(offsetx, offsety)
 		d := randPoint(offsetx, offsety)
 		p.CubeBezier(b, c, d)
-		return cubicBezier{a, b, c, d}
-	default:
-		return nil
+		bb.CubeBezier(b, c, d)
 	}
+	return bb.BBox
 }
 
 func drawOneBox(p pather, order int, offsetx, offsety int) {
 	p.pdf.SetStrokeAlpha(1)
 
-	curve := generateDrawCurve(p, order, offsetx, offsety)
+	rect := generateDrawCurve(p, order, offsetx, offsety)
 	p.Stop(true)
 	p.pdf.Ops(contentstream.OpCloseStroke{})
 
-	rect := computeBoundingBox(curve)
 	p.pdf.SetFillAlpha(0.2)
 	drawRectange(p.pdf, rect)
 }
@@ -106,3 +107,16 @@ func TestAggregateBoxes(t *testing.T) {
 		t.Error(err)
 	}
 }
+
+func TestBoundingBoxDegenerateSegments(t *testing.T) {
+	var bb BoundingBox
+	// an horizontal line, away from the origin
+	bb.Start(fixed.P(10, 20))
+	bb.Line(fixed.P(30, 20))
+	// a vertical one, in a second sub-path
+	bb.Start(fixed.P(15, 40))
+	bb.Line(fixed.P(15, 50))
+	if exp := (fixed.Rectangle26_6{Min: fixed.P(10, 20), Max: fixed.P(30, 50)}); bb.BBox != exp {
+		t.Errorf("expected %v, got %v", exp, bb.BBox)
+	}
+}
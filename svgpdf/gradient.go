@@ -0,0 +1,229 @@
+package svgpdf
+
+import (
+	"image/color"
+	"math"
+	"sort"
+
+	"github.com/benoitkugler/oksvg/svgicon"
+	"github.com/benoitkugler/pdf/model"
+	"golang.org/x/image/math/fixed"
+)
+
+// maxSpreadPeriods limits the number of periods
+// explicitly written for reflected or repeated gradients.
+const maxSpreadPeriods = 256
+
+// gradientShading builds the shading for `grad`, used to paint a path
+// with bounding box `bbox`, and returns the matrix mapping the shading space
+// to the path space.
+// The stop opacities are not supported.
+func gradientShading(grad svgicon.Gradient, bbox fixed.Rectangle26_6) (*model.ShadingDict, svgicon.Matrix2D) {
+	// mapping from the gradient space to the path space,
+	// as done by the raster backend
+	mat := grad.Matrix
+	if grad.Units == svgicon.ObjectBoundingBox {
+		x, y := float64(bbox.Min.X)/64, float64(bbox.Min.Y)/64
+		w, h := float64(bbox.Max.X-bbox.Min.X)/64, float64(bbox.Max.Y-bbox.Min.Y)/64
+		mat = svgicon.Identity.Translate(x, y).Scale(w, h).Mult(grad.Matrix)
+	}
+
+	tMin, tMax := spreadRange(grad, mat, bbox)
+
+	base := model.BaseGradient{
+		Domain:   [2]model.Fl{model.Fl(tMin), model.Fl(tMax)},
+		Function: []model.FunctionDict{spreadFunction(stopsFunction(grad.Stops), grad.Spread, int(tMin), int(tMax))},
+		Extend:   [2]bool{true, true},
+	}
+	shading := &model.ShadingDict{ColorSpace: model.ColorSpaceRGB}
+	switch dir := grad.Direction.(type) {
+	case svgicon.Radial:
+		cx, cy, fx, fy, r, fr := dir[0], dir[1], dir[2], dir[3], dir[4], dir[5]
+		// the circle for t is centered at f + t(c-f), with radius fr + t(r-fr)
+		circle := func(t float64) (x, y, radius model.Fl) {
+			return model.Fl(fx + t*(cx-fx)), model.Fl(fy + t*(cy-fy)), model.Fl(fr + t*(r-fr))
+		}
+		x0, y0, r0 := circle(tMin)
+		x1, y1, r1 := circle(tMax)
+		shading.ShadingType = model.ShadingRadial{BaseGradient: base, Coords: [6]model.Fl{x0, y0, r0, x1, y1, r1}}
+	case svgicon.Linear:
+		x1, y1, x2, y2 := dir[0], dir[1], dir[2], dir[3]
+		point := func(t float64) (x, y model.Fl) {
+			return model.Fl(x1 + t*(x2-x1)), model.Fl(y1 + t*(y2-y1))
+		}
+		sx, sy := point(tMin)
+		ex, ey := point(tMax)
+		shading.ShadingType = model.ShadingAxial{BaseGradient: base, Coords: [4]model.Fl{sx, sy, ex, ey}}
+	default: // should not happen, use the default linear direction
+		shading.ShadingType = model.ShadingAxial{BaseGradient: base, Coords: [4]model.Fl{0, 0, 1, 0}}
+	}
+	return shading, mat
+}
+
+// spreadRange returns the range of the gradient parameter to cover when painting
+// a path with bounding box `bbox`, where `mat` maps the gradient space to the path space:
+// [0, 1] for padded gradients, which rely on the extension of the shading,
+// or whole periods for reflected or repeated ones.
+func spreadRange(grad svgicon.Gradient, mat svgicon.Matrix2D, bbox fixed.Rectangle26_6) (tMin, tMax float64) {
+	if grad.Spread == svgicon.PadSpread {
+		return 0, 1
+	}
+	tMin, tMax = visibleRange(grad.Direction, mat.Invert(), bbox)
+	tMin, tMax = math.Floor(tMin), math.Ceil(tMax)
+	if tMax-tMin > maxSpreadPeriods {
+		tMax = tMin + maxSpreadPeriods
+	}
+	return tMin, tMax
+}
+
+// visibleRange returns the extrema of the gradient parameter on the corners of `bbox`,
+// mapped into the gradient space by `inv`.
+func visibleRange(direction interface{}, inv svgicon.Matrix2D, bbox fixed.Rectangle26_6) (tMin, tMax float64) {
+	tMin, tMax = math.Inf(1), math.Inf(-1)
+	for _, corner := range [4]fixed.Point26_6{
+		bbox.Min, bbox.Max, {X: bbox.Min.X, Y: bbox.Max.Y}, {X: bbox.Max.X, Y: bbox.Min.Y},
+	} {
+		px, py := inv.Transform(float64(corner.X)/64, float64(corner.Y)/64)
+		var t float64
+		switch dir := direction.(type) {
+		case svgicon.Linear:
+			dx, dy := dir[2]-dir[0], dir[3]-dir[1]
+			if d := dx*dx + dy*dy; d != 0 {
+				t = ((px-dir[0])*dx + (py-dir[1])*dy) / d
+			}
+		case svgicon.Radial:
+			t = radialParameter(dir, px, py)
+		}
+		tMin, tMax = math.Min(tMin, t), math.Max(tMax, t)
+	}
+	if _, isRadial := direction.(svgicon.Radial); isRadial {
+		tMin = 0 // the focal point may be inside the box, and radius must be positive
+	}
+	if math.IsNaN(tMin) || math.IsInf(tMin, 0) {
+		tMin = 0
+	}
+	if math.IsNaN(tMax) || math.IsInf(tMax, 0) || tMax < tMin {
+		tMax = tMin + 1
+	}
+	return tMin, tMax
+}
+
+// radialParameter returns the largest t such that (px, py) lies
+// on the circle centered at f + t(c-f) with radius fr + t(r-fr)
+func radialParameter(dir svgicon.Radial, px, py float64) float64 {
+	cx, cy, fx, fy, r, fr := dir[0], dir[1], dir[2], dir[3], dir[4], dir[5]
+	dx, dy, dr := cx-fx, cy-fy, r-fr
+	qx, qy := px-fx, py-fy
+	// solve a t^2 + b t + c = 0
+	a := dx*dx + dy*dy - dr*dr
+	b := -2 * (qx*dx + qy*dy + fr*dr)
+	c := qx*qx + qy*qy - fr*fr
+	if a == 0 {
+		if b == 0 {
+			return 0
+		}
+		return -c / b
+	}
+	delta := b*b - 4*a*c
+	if delta < 0 {
+		return 0
+	}
+	sq := math.Sqrt(delta)
+	return math.Max((-b+sq)/(2*a), (-b-sq)/(2*a))
+}
+
+func toRGB(c color.Color) []model.Fl {
+	if c == nil {
+		return []model.Fl{0, 0, 0}
+	}
+	nc := color.NRGBAModel.Convert(c).(color.NRGBA)
+	return []model.Fl{model.Fl(nc.R) / 255, model.Fl(nc.G) / 255, model.Fl(nc.B) / 255}
+}
+
+// stopsFunction returns a function on [0, 1] interpolating the
+// colors of the given stops.
+func stopsFunction(stops []svgicon.GradStop) model.FunctionDict {
+	stops = append([]svgicon.GradStop(nil), stops...)
+	sort.SliceStable(stops, func(i, j int) bool { return stops[i].Offset < stops[j].Offset })
+	if len(stops) == 0 { // as the raster backend
+		stops = []svgicon.GradStop{{StopColor: color.Black}}
+	}
+	// make sure the stops cover [0, 1]
+	if first := stops[0]; first.Offset > 0 {
+		first.Offset = 0
+		stops = append([]svgicon.GradStop{first}, stops...)
+	}
+	if last := stops[len(stops)-1]; last.Offset < 1 {
+		last.Offset = 1
+		stops = append(stops, last)
+	}
+
+	var (
+		functions []model.FunctionDict
+		bounds    []model.Fl
+	)
+	for i := 0; i < len(stops)-1; i++ {
+		start, end := math.Max(stops[i].Offset, 0), math.Min(stops[i+1].Offset, 1)
+		if end <= start {
+			continue // hard transition
+		}
+		if len(functions) != 0 {
+			bounds = append(bounds, model.Fl(start))
+		}
+		functions = append(functions, model.FunctionDict{
+			FunctionType: model.FunctionExpInterpolation{
+				C0: toRGB(stops[i].StopColor),
+				C1: toRGB(stops[i+1].StopColor),
+				N:  1,
+			},
+			Domain: []model.Range{{0, 1}},
+		})
+	}
+	switch len(functions) {
+	case 0: // all the stops are at the same offset: use the last color
+		c := toRGB(stops[len(stops)-1].StopColor)
+		return model.FunctionDict{
+			FunctionType: model.FunctionExpInterpolation{C0: c, C1: c, N: 1},
+			Domain:       []model.Range{{0, 1}},
+		}
+	case 1:
+		return functions[0]
+	default:
+		return model.FunctionDict{
+			FunctionType: model.FunctionStitching{
+				Functions: functions,
+				Bounds:    bounds,
+				Encode:    model.FunctionEncodeRepeat(len(functions)),
+			},
+			Domain: []model.Range{{0, 1}},
+		}
+	}
+}
+
+// spreadFunction returns a function on [tMin, tMax], repeating
+// or reflecting `base` on each period.
+func spreadFunction(base model.FunctionDict, spread svgicon.SpreadMethod, tMin, tMax int) model.FunctionDict {
+	if spread == svgicon.PadSpread || tMax-tMin <= 0 {
+		return base
+	}
+	var (
+		functions []model.FunctionDict
+		bounds    []model.Fl
+		encode    [][2]model.Fl
+	)
+	for k := tMin; k < tMax; k++ {
+		if k != tMin {
+			bounds = append(bounds, model.Fl(k))
+		}
+		functions = append(functions, base)
+		if spread == svgicon.ReflectSpread && k%2 != 0 {
+			encode = append(encode, [2]model.Fl{1, 0})
+		} else {
+			encode = append(encode, [2]model.Fl{0, 1})
+		}
+	}
+	return model.FunctionDict{
+		FunctionType: model.FunctionStitching{Functions: functions, Bounds: bounds, Encode: encode},
+		Domain:       []model.Range{{model.Fl(tMin), model.Fl(tMax)}},
+	}
+}
@@ -0,0 +1,176 @@
+package svgpdf
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/benoitkugler/oksvg/svgicon"
+	"github.com/benoitkugler/pdf/model"
+	"golang.org/x/image/math/fixed"
+)
+
+func TestSpreadRange(t *testing.T) {
+	bbox := fixed.Rectangle26_6{Max: fixed.P(100, 50)}
+	// bounding box units
+	mat := svgicon.Identity.Scale(100, 50)
+	for _, test := range []struct {
+		grad       svgicon.Gradient
+		tMin, tMax float64
+	}{
+		{svgicon.Gradient{Direction: svgicon.Linear{0, 0, 0.25, 0}}, 0, 1},
+		{svgicon.Gradient{Direction: svgicon.Linear{0, 0, 0.25, 0}, Spread: svgicon.RepeatSpread}, 0, 4},
+		{svgicon.Gradient{Direction: svgicon.Linear{0.5, 0, 0.75, 0}, Spread: svgicon.ReflectSpread}, -2, 2},
+		// the corners are at sqrt(2)/2 of the center, that is 1.41 radius
+		{svgicon.Gradient{Direction: svgicon.Radial{0.5, 0.5, 0.5, 0.5, 0.5, 0}, Spread: svgicon.RepeatSpread}, 0, 2},
+	} {
+		tMin, tMax := spreadRange(test.grad, mat, bbox)
+		if tMin != test.tMin || tMax != test.tMax {
+			t.Errorf("%v: expected [%g, %g], got [%g, %g]", test.grad.Direction, test.tMin, test.tMax, tMin, tMax)
+		}
+	}
+}
+
+func TestSpreadFunction(t *testing.T) {
+	base := model.FunctionDict{
+		FunctionType: model.FunctionExpInterpolation{C0: []model.Fl{1, 0, 0}, C1: []model.Fl{0, 0, 1}, N: 1},
+		Domain:       []model.Range{{0, 1}},
+	}
+	if fn := spreadFunction(base, svgicon.PadSpread, 0, 1); !reflect.DeepEqual(fn, base) {
+		t.Errorf("padded gradients should use the base function, got %v", fn)
+	}
+	for _, spread := range []svgicon.SpreadMethod{svgicon.RepeatSpread, svgicon.ReflectSpread} {
+		fn := spreadFunction(base, spread, -1, 3)
+		if fn.Domain[0] != (model.Range{-1, 3}) {
+			t.Errorf("unexpected domain %v", fn.Domain)
+		}
+		st, ok := fn.FunctionType.(model.FunctionStitching)
+		if !ok || len(st.Functions) != 4 {
+			t.Fatalf("expected a function with 4 periods, got %v", fn.FunctionType)
+		}
+		if len(st.Bounds) != 3 || st.Bounds[0] != 0 || st.Bounds[2] != 2 {
+			t.Errorf("unexpected bounds %v", st.Bounds)
+		}
+		for k, encode := range st.Encode {
+			// the odd periods are reversed when reflecting
+			reversed := encode == [2]model.Fl{1, 0}
+			if exp := spread == svgicon.ReflectSpread && k%2 == 0; reversed != exp {
+				t.Errorf("%v: unexpected encode %v for period %d", spread, encode, k-1)
+			}
+		}
+	}
+}
+
+func TestRepeatingGradient(t *testing.T) {
+	const src = `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 100 100">
+	<linearGradient id="g" x2="0.25" spreadMethod="%s">
+		<stop offset="0" stop-color="red"/><stop offset="1" stop-color="blue"/>
+	</linearGradient>
+	<rect width="100" height="50" fill="url(#g)"/>
+	</svg>`
+	for _, spread := range []string{"pad", "repeat", "reflect"} {
+		form := renderToStream(t, strings.Replace(src, "%s", spread, 1))
+		if len(form.Resources.Shading) != 1 {
+			t.Fatalf("expected one shading, got %d", len(form.Resources.Shading))
+		}
+		for _, sh := range form.Resources.Shading {
+			axial, ok := sh.ShadingType.(model.ShadingAxial)
+			if !ok {
+				t.Fatalf("expected axial shading, got %T", sh.ShadingType)
+			}
+			if !axial.Extend[0] || !axial.Extend[1] {
+				t.Errorf("expected extended shading")
+			}
+			fn := axial.Function[0]
+			if spread == "pad" {
+				if axial.Domain != [2]model.Fl{0, 1} {
+					t.Errorf("unexpected domain %v", axial.Domain)
+				}
+				continue
+			}
+			// in bounding box units, the gradient vector covers a quarter of the box
+			if axial.Domain != [2]model.Fl{0, 4} || axial.Coords != [4]model.Fl{0, 0, 1, 0} {
+				t.Errorf("unexpected domain %v or coords %v", axial.Domain, axial.Coords)
+			}
+			st, ok := fn.FunctionType.(model.FunctionStitching)
+			if !ok || len(st.Functions) != 4 {
+				t.Fatalf("expected a function with 4 periods, got %v", fn.FunctionType)
+			}
+			if reversed := st.Encode[1] == [2]model.Fl{1, 0}; reversed != (spread == "reflect") {
+				t.Errorf("unexpected encode %v for %s", st.Encode, spread)
+			}
+		}
+		if !bytes.Contains(form.Content, []byte(" sh")) {
+			t.Errorf("shading not painted: %s", form.Content)
+		}
+	}
+}
+
+func TestStopsFunction(t *testing.T) {
+	fn := stopsFunction([]svgicon.GradStop{
+		{StopColor: colorOf(0, 0, 255), Offset: 0.8},
+		{StopColor: colorOf(255, 0, 0), Offset: 0.2},
+		{StopColor: colorOf(0, 255, 0), Offset: 0.5},
+	})
+	st, ok := fn.FunctionType.(model.FunctionStitching)
+	if !ok {
+		t.Fatalf("expected stitching function, got %T", fn.FunctionType)
+	}
+	// stops are sorted and padded to cover [0, 1]
+	if len(st.Functions) != 4 || len(st.Bounds) != 3 || st.Bounds[0] != 0.2 || st.Bounds[2] != 0.8 {
+		t.Errorf("unexpected function %v", st)
+	}
+}
+
+func TestFillAndStroke(t *testing.T) {
+	form := renderToStream(t, `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 10 10">
+	<rect width="5" height="5" fill="red" stroke="blue"/>
+	</svg>`)
+	content := strings.Join(strings.Fields(string(form.Content)), " ")
+	// filling consumes the path, which is written again for the stroke
+	const path = "0 0 m 5 0 l 5 5 l 0 5 l h"
+	fill := strings.Index(content, "1 0 0 rg")
+	stroke := strings.Index(content, "0 0 1 RG")
+	if fill == -1 || stroke == -1 {
+		t.Fatalf("expected a red fill and a blue stroke: %s", content)
+	}
+	if exp := path + " f"; !strings.Contains(content[fill:], exp) {
+		t.Errorf("expected %s after the fill color: %s", exp, content)
+	}
+	if exp := path + " S"; !strings.Contains(content[stroke:], exp) {
+		t.Errorf("expected %s after the stroke color: %s", exp, content)
+	}
+}
+
+func colorOf(r, g, b uint8) svgicon.PlainColor { return svgicon.NewPlainColor(r, g, b, 0xff) }
+
+func TestGradientFillShadings(t *testing.T) {
+	form := renderToStream(t, `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 100 100">
+	<linearGradient id="l"><stop offset="0" stop-color="red"/><stop offset="1" stop-color="blue"/></linearGradient>
+	<radialGradient id="r"><stop offset="0" stop-color="red"/><stop offset="1" stop-color="blue"/></radialGradient>
+	<rect x="10" y="10" width="20" height="20" fill="url(#l)"/>
+	<circle cx="50" cy="50" r="10" fill="url(#r)"/>
+	</svg>`)
+	var axial, radial int
+	for _, sh := range form.Resources.Shading {
+		switch st := sh.ShadingType.(type) {
+		case model.ShadingAxial:
+			axial++
+			if st.Extend != [2]bool{true, true} {
+				t.Errorf("expected a padded gradient, got %v", st.Extend)
+			}
+		case model.ShadingRadial:
+			radial++
+			if st.Extend != [2]bool{true, true} {
+				t.Errorf("expected a padded gradient, got %v", st.Extend)
+			}
+		}
+	}
+	if axial != 1 || radial != 1 {
+		t.Errorf("expected one axial and one radial shadings, got %d and %d", axial, radial)
+	}
+	if !bytes.Contains(form.Content, []byte("sh")) {
+		t.Errorf("expected shading operations: %s", form.Content)
+	}
+}
@@ -1,10 +1,12 @@
 // Package svgpdf implements a PDF backend to render SVG images,
 // by wrapping github.com/benoitkugler/pdf
-// TODO: Some features are missing: MiterLimit and Gradient.
+// TODO: Some features are missing: stroking with gradients.
 package svgpdf
 
 import (
+	"fmt"
 	"io"
+	"math"
 
 	"github.com/benoitkugler/oksvg/svgicon"
 	"github.com/benoitkugler/pdf/contentstream"
@@ -14,43 +16,69 @@ import (
 
 // assert interface conformance
 var (
-	_ svgicon.Driver  = Renderer{}
-	_ svgicon.Filler  = (*filler)(nil)
-	_ svgicon.Stroker = (*stroker)(nil)
-	_ svgicon.Stroker = (*patherStroker)(nil)
+	_ svgicon.Driver      = Renderer{}
+	_ svgicon.ClipDriver  = Renderer{}
+	_ svgicon.BlendDriver = Renderer{}
+	_ svgicon.IconDriver  = Renderer{}
+	_ svgicon.ErrorDriver = Renderer{}
+	_ svgicon.Filler      = (*filler)(nil)
+	_ svgicon.Stroker     = (*patherStroker)(nil)
 )
 
 type Renderer struct {
 	pdf                 *contentstream.GraphicStream
-	fillOpacityStates   map[float64]*model.GraphicState
-	strokeOpacityStates map[float64]*model.GraphicState
+	fillOpacityStates   map[graphicStateKey]*model.GraphicState
+	strokeOpacityStates map[graphicStateKey]*model.GraphicState
+	clipped             *bool              // true if a graphic state has been saved for clipping
+	blend               *svgicon.BlendMode // current blend mode
+	err                 *error             // first unsupported feature met
+}
+
+// graphicStateKey identifies the cached graphic states
+type graphicStateKey struct {
+	opacity float64
+	blend   svgicon.BlendMode
+}
+
+// blendModeNames maps the blend modes to their PDF names
+var blendModeNames = [...]model.Name{
+	svgicon.NormalBlend:     "Normal",
+	svgicon.MultiplyBlend:   "Multiply",
+	svgicon.ScreenBlend:     "Screen",
+	svgicon.OverlayBlend:    "Overlay",
+	svgicon.DarkenBlend:     "Darken",
+	svgicon.LightenBlend:    "Lighten",
+	svgicon.ColorDodgeBlend: "ColorDodge",
+	svgicon.ColorBurnBlend:  "ColorBurn",
+	svgicon.HardLightBlend:  "HardLight",
+	svgicon.SoftLightBlend:  "SoftLight",
+	svgicon.DifferenceBlend: "Difference",
+	svgicon.ExclusionBlend:  "Exclusion",
 }
 
 // implements the common path commands,
-// shared by the filler and the stroker
+// shared by the filler and the stroker:
+// the path is recorded and written when painting
 type pather struct {
 	pdf         *contentstream.GraphicStream
+	path        svgicon.Path
 	boundingBox BoundingBox
+	blend       svgicon.BlendMode
 }
 
 // implements the filling operation
 type filler struct {
 	pather
 	useNonZeroWinding bool
-	fillOpacityStates map[float64]*model.GraphicState
+	fillOpacityStates map[graphicStateKey]*model.GraphicState
+	err               *error
 }
 
-// implements the stroking operation, while
-// also writing the path
+// implements the stroking operation
 type patherStroker struct {
 	pather
-	strokeOpacityStates map[float64]*model.GraphicState
-}
-
-// only stroke the current path, established by
-// the filler
-type stroker struct {
-	patherStroker
+	strokeOpacityStates map[graphicStateKey]*model.GraphicState
+	err                 *error
 }
 
 func saveApperanceToFile(ap *contentstream.GraphicStream, filename string) error {
@@ -63,25 +91,101 @@ func saveApperanceToFile(ap *contentstream.GraphicStream, filename string) error
 	return doc.WriteFile(filename, nil)
 }
 
+// PageSize is the size of a PDF page, in points, in portrait orientation.
+type PageSize struct {
+	Width, Height float64
+}
+
+// Common page sizes
+var (
+	A4     = PageSize{Width: 595.28, Height: 841.89}
+	Letter = PageSize{Width: 612, Height: 792}
+)
+
+// PageOptions controls the page layout used by RenderSVGIconToPDFOptions.
+// The zero value renders the icon on an A4 page, in portrait
+// orientation, without margins and with its intrinsic size.
+type PageOptions struct {
+	// Size is the size of the page. The zero value means A4.
+	Size PageSize
+	// Landscape swaps the width and the height of the page.
+	Landscape bool
+	// Margin is the space left on each side of the page, in points.
+	Margin float64
+	// Fit scales the icon to the area inside the margins, keeping
+	// its aspect ratio and centering it. Otherwise, the icon
+	// keeps the size of its view box, and is placed at the top left
+	// corner of this area.
+	Fit bool
+}
+
+// pageSize returns the effective width and height of the page.
+func (opts PageOptions) pageSize() (width, height float64) {
+	size := opts.Size
+	if size == (PageSize{}) {
+		size = A4
+	}
+	if opts.Landscape {
+		return size.Height, size.Width
+	}
+	return size.Width, size.Height
+}
+
+// iconRect returns the rectangle, in PDF coordinates, where an icon with
+// the given view box is drawn, on a page of size `width` x `height`.
+func (opts PageOptions) iconRect(viewBox svgicon.Bounds, width, height float64) svgicon.Bounds {
+	areaW, areaH := width-2*opts.Margin, height-2*opts.Margin
+	w, h := viewBox.W, viewBox.H
+	if opts.Fit && w > 0 && h > 0 {
+		scale := math.Min(areaW/w, areaH/h)
+		w, h = w*scale, h*scale
+		return svgicon.Bounds{X: opts.Margin + (areaW-w)/2, Y: opts.Margin + (areaH-h)/2, W: w, H: h}
+	}
+	// top left corner of the area
+	return svgicon.Bounds{X: opts.Margin, Y: height - opts.Margin - h, W: w, H: h}
+}
+
+// drawPage returns a page content drawing `icon` with the layout given by `opts`.
+func drawPage(icon *svgicon.SvgIcon, opts PageOptions) contentstream.GraphicStream {
+	width, height := opts.pageSize()
+	ap := contentstream.NewGraphicStream(model.Rectangle{Urx: model.Fl(width), Ury: model.Fl(height)})
+	DrawIconToPDF(&ap, icon, opts.iconRect(icon.ViewBox, width, height))
+	return ap
+}
+
+// DefaultPageOptions is the layout used by RenderSVGIconToPDF :
+// the icon is scaled to fit an A4 page, with half an inch margins.
+var DefaultPageOptions = PageOptions{Size: A4, Margin: 36, Fit: true}
+
 // RenderSVGIconToPDF reads the given icon and renders it
-// into the given file.
+// into the given file, using DefaultPageOptions.
 func RenderSVGIconToPDF(icon io.Reader, pdfName string) error {
+	return RenderSVGIconToPDFOptions(icon, pdfName, DefaultPageOptions)
+}
+
+// RenderSVGIconToPDFOptions reads the given icon and renders it
+// into the given file, on one page whose layout is given by `opts`.
+func RenderSVGIconToPDFOptions(icon io.Reader, pdfName string, opts PageOptions) error {
 	parsedIcon, err := svgicon.ReadIconStream(icon, svgicon.WarnErrorMode)
 	if err != nil {
 		return err
 	}
-	ap := contentstream.NewGraphicStream(model.Rectangle{Urx: 595.28, Ury: 841.89})
-	// pdf.TransformBegin()
-	// pdf.TransformScale(10000/parsedIcon.ViewBox.W, 10000/parsedIcon.ViewBox.H, 0, 0)
-	renderer := NewRenderer(&ap)
-	ap.Ops(
+	ap := drawPage(parsedIcon, opts)
+	return saveApperanceToFile(&ap, pdfName)
+}
+
+// DrawIconToPDF draws `icon` into `cs`, so that its view box fits
+// into `rect`, expressed in PDF coordinates (that is, with the origin
+// at the bottom left). The graphic state of `cs` is saved and restored,
+// and `icon` is not modified, so that it may be drawn concurrently.
+func DrawIconToPDF(cs *contentstream.GraphicStream, icon *svgicon.SvgIcon, rect svgicon.Bounds) {
+	// SVG coordinates go down: flip the y axis around the middle of rect
+	cs.Ops(
 		contentstream.OpSave{},
-		contentstream.OpConcat{Matrix: model.Matrix{1, 0, 0, -1, 0, 841.89}},
+		contentstream.OpConcat{Matrix: model.Matrix{1, 0, 0, -1, 0, model.Fl(2*rect.Y + rect.H)}},
 	)
-	parsedIcon.Draw(renderer, 1.0)
-	ap.Ops(contentstream.OpRestore{})
-
-	return saveApperanceToFile(&ap, pdfName)
+	icon.DrawScaled(NewRenderer(cs), rect.X, rect.Y, rect.W, rect.H, 1.0)
+	cs.Ops(contentstream.OpRestore{})
 }
 
 // NewRenderer return a renderer which will
@@ -89,94 +193,202 @@ func RenderSVGIconToPDF(icon io.Reader, pdfName string) error {
 func NewRenderer(cs *contentstream.GraphicStream) Renderer {
 	return Renderer{
 		pdf:                 cs,
-		fillOpacityStates:   make(map[float64]*model.GraphicState),
-		strokeOpacityStates: make(map[float64]*model.GraphicState),
+		fillOpacityStates:   make(map[graphicStateKey]*model.GraphicState),
+		strokeOpacityStates: make(map[graphicStateKey]*model.GraphicState),
+		clipped:             new(bool),
+		blend:               new(svgicon.BlendMode),
+		err:                 new(error),
 	}
 }
 
 func (r Renderer) SetupDrawers(willFill, willDraw bool) (f svgicon.Filler, s svgicon.Stroker) {
-	if willFill { //
-		f = &filler{pather: pather{pdf: r.pdf}, fillOpacityStates: r.fillOpacityStates}
-		if willDraw { // dont write the same path twice
-			s = &stroker{patherStroker: patherStroker{pather: pather{pdf: r.pdf}, strokeOpacityStates: r.strokeOpacityStates}}
-		} // else s = nil
-	} else {
-		if willDraw { // write the path
-			s = &patherStroker{pather: pather{pdf: r.pdf}, strokeOpacityStates: r.strokeOpacityStates}
-		}
+	// the path is written by both painters, since filling
+	// a path (in particular with a gradient) consumes it
+	if willFill {
+		f = &filler{pather: pather{pdf: r.pdf, blend: *r.blend}, fillOpacityStates: r.fillOpacityStates, err: r.err}
+	}
+	if willDraw {
+		s = &patherStroker{pather: pather{pdf: r.pdf, blend: *r.blend}, strokeOpacityStates: r.strokeOpacityStates, err: r.err}
 	}
 	return f, s
 }
 
+// Err implements svgicon.ErrorDriver, reporting the
+// first unsupported feature met while drawing.
+func (r Renderer) Err() error { return *r.err }
+
+// SetBlendMode implements svgicon.BlendDriver, using
+// the PDF blend modes.
+func (r Renderer) SetBlendMode(mode svgicon.BlendMode) { *r.blend = mode }
+
+// BeginIcon implements svgicon.IconDriver, by saving the
+// graphic state and clipping to the icon viewport.
+func (r Renderer) BeginIcon(viewBox svgicon.Bounds) {
+	r.pdf.Ops(contentstream.OpSave{})
+	if viewBox.W > 0 && viewBox.H > 0 {
+		r.pdf.Ops(
+			contentstream.OpRectangle{X: model.Fl(viewBox.X), Y: model.Fl(viewBox.Y), W: model.Fl(viewBox.W), H: model.Fl(viewBox.H)},
+			contentstream.OpClip{},
+			contentstream.OpEndPath{},
+		)
+	}
+}
+
+// EndIcon implements svgicon.IconDriver, by restoring the graphic state.
+func (r Renderer) EndIcon() { r.pdf.Ops(contentstream.OpRestore{}) }
+
+// SetClip implements svgicon.ClipDriver, by saving the graphic state
+// and intersecting the clipping path with `clips`. The state is restored
+// when the clip is changed.
+func (r Renderer) SetClip(clips []svgicon.Path) {
+	if *r.clipped {
+		r.pdf.Ops(contentstream.OpRestore{})
+		*r.clipped = false
+	}
+	if len(clips) == 0 {
+		return
+	}
+	r.pdf.Ops(contentstream.OpSave{})
+	for _, clip := range clips {
+		writePath(r.pdf, clip)
+		r.pdf.Ops(contentstream.OpClip{}, contentstream.OpEndPath{})
+	}
+	*r.clipped = true
+}
+
 func fixedTof(a fixed.Point26_6) (model.Fl, model.Fl) {
 	return model.Fl(a.X) / 64, model.Fl(a.Y) / 64
 }
 
 func fToFixed(x, y float64) fixed.Point26_6 {
-	return fixed.Point26_6{X: fixed.Int26_6(x * 64), Y: fixed.Int26_6(y * 64)}
+	return fixed.Point26_6{X: fixed.Int26_6(math.Round(x * 64)), Y: fixed.Int26_6(math.Round(y * 64))}
+}
+
+// writePath writes the path construction operators for `path`
+func writePath(pdf *contentstream.GraphicStream, path svgicon.Path) {
+	var current, start fixed.Point26_6 // needed to convert quadratic curves
+	for _, op := range path {
+		switch op := op.(type) {
+		case svgicon.OpMoveTo:
+			current, start = fixed.Point26_6(op), fixed.Point26_6(op)
+			x, y := fixedTof(current)
+			pdf.Ops(contentstream.OpMoveTo{X: x, Y: y})
+		case svgicon.OpLineTo:
+			current = fixed.Point26_6(op)
+			x, y := fixedTof(current)
+			pdf.Ops(contentstream.OpLineTo{X: x, Y: y})
+		case svgicon.OpQuadTo:
+			// there is no quadratic curve in PDF: use the equivalent cubic curve
+			x0, y0 := fixedTof(current)
+			qx, qy := fixedTof(op[0])
+			x, y := fixedTof(op[1])
+			pdf.Ops(contentstream.OpCubicTo{
+				X1: x0 + 2./3*(qx-x0), Y1: y0 + 2./3*(qy-y0),
+				X2: x + 2./3*(qx-x), Y2: y + 2./3*(qy-y),
+				X3: x, Y3: y,
+			})
+			current = op[1]
+		case svgicon.OpCubicTo:
+			cx0, cy0 := fixedTof(op[0])
+			cx1, cy1 := fixedTof(op[1])
+			x, y := fixedTof(op[2])
+			pdf.Ops(contentstream.OpCubicTo{X1: cx0, Y1: cy0, X2: cx1, Y2: cy1, X3: x, Y3: y})
+			current = op[2]
+		case svgicon.OpClose:
+			pdf.Ops(contentstream.OpClosePath{})
+			current = start
+		}
+	}
 }
 
 func (p *pather) Clear() {
+	p.path.Clear()
 	p.boundingBox = BoundingBox{}
 }
 
 func (p *pather) Start(a fixed.Point26_6) {
-	x, y := fixedTof(a)
-	p.pdf.Ops(contentstream.OpMoveTo{X: x, Y: y})
+	p.path.Start(a)
 	p.boundingBox.Start(a)
 }
 
 func (p *pather) Line(b fixed.Point26_6) {
-	x, y := fixedTof(b)
-	p.pdf.Ops(contentstream.OpLineTo{X: x, Y: y})
+	p.path.Line(b)
 	p.boundingBox.Line(b)
 }
 
 func (p *pather) QuadBezier(b fixed.Point26_6, c fixed.Point26_6) {
-	cx, cy := fixedTof(b)
-	x, y := fixedTof(c)
-	p.pdf.Ops(contentstream.OpCurveTo1{X2: cx, Y2: cy, X3: x, Y3: y})
+	p.path.QuadBezier(b, c)
 	p.boundingBox.QuadBezier(b, c)
 }
 
 func (p *pather) CubeBezier(b fixed.Point26_6, c fixed.Point26_6, d fixed.Point26_6) {
-	cx0, cy0 := fixedTof(b)
-	cx1, cy1 := fixedTof(c)
-	x, y := fixedTof(d)
-	p.pdf.Ops(contentstream.OpCubicTo{X1: cx0, Y1: cy0, X2: cx1, Y2: cy1, X3: x, Y3: y})
+	p.path.CubeBezier(b, c, d)
 	p.boundingBox.CubeBezier(b, c, d)
 }
 
 func (p *pather) Stop(closeLoop bool) {
-	if closeLoop {
-		p.pdf.Ops(contentstream.OpClosePath{})
-	}
+	p.path.Stop(closeLoop)
 }
 
-// TODO: support gradient
+// Draw fills the current path with a plain color or a gradient.
+// Other patterns are not supported, and reported by Err.
 func (f filler) Draw(color svgicon.Pattern, opacity float64) {
 	switch color := color.(type) {
 	case svgicon.PlainColor:
 		f.pdf.SetColorFill(color)
-		opacity *= float64(color.A) / 255.
-		// cache the opacity states
-		gs, ok := f.fillOpacityStates[opacity]
-		if !ok {
-			gs = &model.GraphicState{Ca: model.ObjFloat(opacity), BM: []model.Name{"Normal"}}
-			f.fillOpacityStates[opacity] = gs
+		f.setOpacity(opacity * float64(color.A) / 255.)
+		writePath(f.pdf, f.path)
+		if f.useNonZeroWinding {
+			f.pdf.Ops(contentstream.OpFill{})
+		} else {
+			f.pdf.Ops(contentstream.OpEOFill{})
 		}
-		name := f.pdf.AddExtGState(gs)
-		f.pdf.Ops(contentstream.OpSetExtGState{Dict: name})
 	case svgicon.Gradient:
-		// mat := color.ApplyPathExtent(f.boundingBox.BBox)
+		f.fillGradient(color, opacity)
+	default:
+		if *f.err == nil {
+			*f.err = fmt.Errorf("filling with %T is not supported", color)
+		}
+	}
+}
 
+// setOpacity sets the fill opacity and the current blend mode
+func (f filler) setOpacity(opacity float64) {
+	// cache the opacity states
+	key := graphicStateKey{opacity: opacity, blend: f.blend}
+	gs, ok := f.fillOpacityStates[key]
+	if !ok {
+		gs = &model.GraphicState{Ca: model.ObjFloat(opacity), BM: []model.Name{blendModeNames[f.blend]}}
+		f.fillOpacityStates[key] = gs
 	}
+	name := f.pdf.AddExtGState(gs)
+	f.pdf.Ops(contentstream.OpSetExtGState{Dict: name})
+}
 
+// fillGradient paints the shading built from `grad`,
+// clipped by the current path.
+func (f filler) fillGradient(grad svgicon.Gradient, opacity float64) {
+	shading, mat := gradientShading(grad, f.boundingBox.BBox)
+	if mat.A*mat.D-mat.B*mat.C == 0 { // empty path
+		return
+	}
+	f.pdf.Ops(contentstream.OpSave{})
+	writePath(f.pdf, f.path)
 	if f.useNonZeroWinding {
-		f.pdf.Ops(contentstream.OpFill{})
+		f.pdf.Ops(contentstream.OpClip{})
 	} else {
-		f.pdf.Ops(contentstream.OpEOFill{})
+		f.pdf.Ops(contentstream.OpEOClip{})
 	}
+	f.pdf.Ops(contentstream.OpEndPath{})
+	f.setOpacity(opacity)
+	f.pdf.Ops(
+		contentstream.OpConcat{Matrix: model.Matrix{
+			model.Fl(mat.A), model.Fl(mat.B), model.Fl(mat.C),
+			model.Fl(mat.D), model.Fl(mat.E), model.Fl(mat.F),
+		}},
+		contentstream.OpShFill{Shading: f.pdf.AddShading(shading)},
+		contentstream.OpRestore{},
+	)
 }
 
 func (f *filler) SetWinding(useNonZeroWinding bool) {
@@ -214,6 +426,7 @@ func (f *patherStroker) SetStrokeOptions(options svgicon.StrokeOptions) {
 		contentstream.OpSetLineWidth{W: model.Fl(options.LineWidth) / 64},
 		contentstream.OpSetLineCap{Style: capStyle},
 		contentstream.OpSetLineJoin{Style: joinStyle},
+		// both SVG and PDF use the ratio between the miter length and the line width
 		contentstream.OpSetMiterLimit{Limit: model.Fl(options.Join.MiterLimit) / 64},
 	)
 }
@@ -225,27 +438,19 @@ func (f patherStroker) Draw(color svgicon.Pattern, opacity float64) {
 		f.pdf.SetColorStroke(color)
 		opacity *= float64(color.A) / 255.
 		// cache the opacity states
-		gs, ok := f.strokeOpacityStates[opacity]
+		key := graphicStateKey{opacity: opacity, blend: f.blend}
+		gs, ok := f.strokeOpacityStates[key]
 		if !ok {
-			gs = &model.GraphicState{CA: model.ObjFloat(opacity), BM: []model.Name{"Normal"}}
-			f.strokeOpacityStates[opacity] = gs
+			gs = &model.GraphicState{CA: model.ObjFloat(opacity), BM: []model.Name{blendModeNames[f.blend]}}
+			f.strokeOpacityStates[key] = gs
 		}
 		name := f.pdf.AddExtGState(gs)
 		f.pdf.Ops(contentstream.OpSetExtGState{Dict: name})
+	default:
+		if *f.err == nil {
+			*f.err = fmt.Errorf("stroking with %T is not supported", color)
+		}
 	}
+	writePath(f.pdf, f.path)
 	f.pdf.Ops(contentstream.OpStroke{})
 }
-
-// the stroker doesnt write the path again
-
-func (p stroker) Clear() {}
-
-func (p stroker) Start(a fixed.Point26_6) {}
-
-func (p stroker) Line(b fixed.Point26_6) {}
-
-func (p stroker) QuadBezier(b fixed.Point26_6, c fixed.Point26_6) {}
-
-func (p stroker) CubeBezier(b fixed.Point26_6, c fixed.Point26_6, d fixed.Point26_6) {}
-
-func (p stroker) Stop(closeLoop bool) {}
@@ -2,10 +2,20 @@ package svgpdf
 
 import (
 	"fmt"
+	"image"
+	"image/color"
+	"math"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"testing"
+
+	"github.com/benoitkugler/oksvg/internal/testutil"
+	"github.com/benoitkugler/oksvg/svgicon"
+	"github.com/benoitkugler/oksvg/svgraster"
+	"github.com/benoitkugler/pdf/contentstream"
+	"github.com/benoitkugler/pdf/model"
 )
 
 func renderIcon(t *testing.T, filename string) {
@@ -23,6 +33,7 @@ func renderIcon(t *testing.T, filename string) {
 }
 
 func TestLandscapeIcons(t *testing.T) {
+	testutil.SkipWithoutCharset(t)
 	for _, p := range [...]string{
 		"beach", "cape", "iceberg", "island",
 		"mountains", "sea", "trees", "village",
@@ -32,6 +43,7 @@ func TestLandscapeIcons(t *testing.T) {
 }
 
 func TestSportsIcons(t *testing.T) {
+	testutil.SkipWithoutCharset(t)
 	for _, p := range [...]string{
 		"archery", "fencing", "rugby_sevens",
 		"artistic_gymnastics", "football", "sailing",
@@ -54,6 +66,7 @@ func TestSportsIcons(t *testing.T) {
 }
 
 func TestTestIcons(t *testing.T) {
+	testutil.SkipWithoutCharset(t)
 	for _, p := range [...]string{
 		"astronaut", "jupiter", "lander", "school-bus", "telescope", "content-cut-light", "defs",
 		"24px",
@@ -77,3 +90,262 @@ func TestStrokeIcons(t *testing.T) {
 		renderIcon(t, "testdata/"+p)
 	}
 }
+
+func TestBlendMode(t *testing.T) {
+	const src = `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 10 10">
+	<rect width="10" height="10" fill="yellow"/>
+	<rect width="10" height="10" fill="cyan" style="mix-blend-mode:multiply"/>
+	</svg>`
+	icon, err := svgicon.ReadIconStream(strings.NewReader(src), svgicon.StrictErrorMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ap := contentstream.NewGraphicStream(model.Rectangle{Urx: 10, Ury: 10})
+	icon.Draw(NewRenderer(&ap), 1)
+
+	modes := map[model.Name]bool{}
+	for _, gs := range ap.ToXFormObject(false).Resources.ExtGState {
+		for _, bm := range gs.BM {
+			modes[bm] = true
+		}
+	}
+	if !modes["Normal"] || !modes["Multiply"] {
+		t.Errorf("expected Normal and Multiply blend modes, got %v", modes)
+	}
+}
+
+func renderToStream(t *testing.T, src string) *model.XObjectForm {
+	icon, err := svgicon.ReadIconStream(strings.NewReader(src), svgicon.StrictErrorMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ap := contentstream.NewGraphicStream(model.Rectangle{Urx: 100, Ury: 100})
+	icon.Draw(NewRenderer(&ap), 1)
+	return ap.ToXFormObject(false)
+}
+
+func TestViewportClip(t *testing.T) {
+	const src = `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 10 20">
+	<rect x="-5" width="30" height="30" fill="red"/>
+	</svg>`
+	content := string(renderToStream(t, src).Content)
+	if !strings.HasPrefix(strings.Join(strings.Fields(content), " "), "q 0 0 10 20 re W n") || !strings.HasSuffix(strings.TrimSpace(content), "Q") {
+		t.Errorf("expected the icon to be clipped to its viewport, got %s", content)
+	}
+}
+
+func TestDrawIconToPDF(t *testing.T) {
+	const src = `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 10 10">
+	<rect width="10" height="10" fill="red"/>
+	</svg>`
+	icon, err := svgicon.ReadIconStream(strings.NewReader(src), svgicon.StrictErrorMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ap := contentstream.NewGraphicStream(model.Rectangle{Urx: 500, Ury: 500})
+	DrawIconToPDF(&ap, icon, svgicon.Bounds{X: 100, Y: 200, W: 50, H: 50})
+
+	content := strings.Join(strings.Fields(string(ap.ToXFormObject(false).Content)), " ")
+	// the y axis is flipped inside the rectangle
+	for _, exp := range []string{"1 0 0 -1 0 450 cm", "100 200 m 150 200 l 150 250 l 100 250 l h"} {
+		if !strings.Contains(content, exp) {
+			t.Errorf("expected %s in %s", exp, content)
+		}
+	}
+	if icon.Transform != svgicon.Identity {
+		t.Errorf("the icon transform should not be modified")
+	}
+
+	// the icon may be shared between goroutines
+	var wg sync.WaitGroup
+	contents := make([]string, 4)
+	for i := range contents {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			ap := contentstream.NewGraphicStream(model.Rectangle{Urx: 500, Ury: 500})
+			DrawIconToPDF(&ap, icon, svgicon.Bounds{X: float64(10 * i), Y: 200, W: 50, H: 50})
+			contents[i] = string(ap.ToXFormObject(false).Content)
+		}(i)
+	}
+	wg.Wait()
+	for i, content := range contents {
+		if exp := fmt.Sprintf("%d 200 m", 10*i); !strings.Contains(content, exp) {
+			t.Errorf("expected %s in %s", exp, content)
+		}
+	}
+}
+
+func TestAlphaMatchesRaster(t *testing.T) {
+	const src = `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 10 10">
+	<rect width="10" height="10" fill="rgb(51 102 153 / 0.5)" fill-opacity="0.8"/>
+	</svg>`
+	icon, err := svgicon.ReadIconStream(strings.NewReader(src), svgicon.StrictErrorMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img := image.NewRGBA(image.Rect(0, 0, 10, 10))
+	icon.Draw(svgraster.NewDriverInto(img, img.Bounds()), 1)
+	rasterColor := color.NRGBAModel.Convert(img.At(5, 5)).(color.NRGBA)
+
+	form := renderToStream(t, src)
+	var rgb [3]float64
+	for _, line := range strings.Split(string(form.Content), "\n") {
+		if strings.HasSuffix(line, " rg") {
+			fmt.Sscan(line, &rgb[0], &rgb[1], &rgb[2])
+		}
+	}
+	if len(form.Resources.ExtGState) != 1 {
+		t.Fatalf("expected one graphic state, got %d", len(form.Resources.ExtGState))
+	}
+	var alpha float64
+	for _, gs := range form.Resources.ExtGState {
+		alpha = float64(gs.Ca.(model.ObjFloat))
+	}
+
+	// both backends use non premultiplied colors, and combine
+	// the alpha of the color with the opacity
+	pdfColor := [4]float64{rgb[0] * 0xff, rgb[1] * 0xff, rgb[2] * 0xff, alpha * 0xff}
+	for i, v := range [4]uint8{rasterColor.R, rasterColor.G, rasterColor.B, rasterColor.A} {
+		if math.Abs(float64(v)-pdfColor[i]) > 2 {
+			t.Errorf("raster color %v and PDF color %v differ", rasterColor, pdfColor)
+			break
+		}
+	}
+	if rasterColor.A < 0x66-1 || rasterColor.A > 0x66+1 {
+		t.Errorf("expected an alpha of 0.4, got %v", rasterColor)
+	}
+}
+
+func TestUnsupportedPatternError(t *testing.T) {
+	const src = `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 10 10">
+	<linearGradient id="g"><stop offset="0" stop-color="red"/><stop offset="1" stop-color="blue"/></linearGradient>
+	<rect width="10" height="10" fill="url(#g)"/>
+	<rect width="5" height="5" stroke="url(#g)" fill="none"/>
+	</svg>`
+	icon, err := svgicon.ReadIconStream(strings.NewReader(src), svgicon.StrictErrorMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ap := contentstream.NewGraphicStream(model.Rectangle{Urx: 10, Ury: 10})
+	if err = icon.DrawWithError(NewRenderer(&ap), 1); err == nil || !strings.Contains(err.Error(), "stroking") {
+		t.Errorf("expected an error for the gradient stroke, got %v", err)
+	}
+
+	icon.SVGPaths = icon.SVGPaths[:1] // gradient fills are supported
+	ap = contentstream.NewGraphicStream(model.Rectangle{Urx: 10, Ury: 10})
+	if err = icon.DrawWithError(NewRenderer(&ap), 1); err != nil {
+		t.Error(err)
+	}
+
+	// other patterns are reported
+	r := NewRenderer(&ap)
+	f, _ := r.SetupDrawers(true, false)
+	f.Draw(nil, 1)
+	if err = r.Err(); err == nil || !strings.Contains(err.Error(), "filling") {
+		t.Errorf("expected an error for an unsupported fill, got %v", err)
+	}
+}
+
+func TestPageOptions(t *testing.T) {
+	icon := svgicon.SvgIcon{ViewBox: svgicon.Bounds{W: 100, H: 50}}
+	for _, test := range []struct {
+		opts          PageOptions
+		width, height float64
+		rect          svgicon.Bounds
+	}{
+		{PageOptions{}, 595.28, 841.89, svgicon.Bounds{X: 0, Y: 791.89, W: 100, H: 50}},
+		{PageOptions{Size: Letter, Margin: 36}, 612, 792, svgicon.Bounds{X: 36, Y: 706, W: 100, H: 50}},
+		{PageOptions{Size: Letter, Landscape: true}, 792, 612, svgicon.Bounds{X: 0, Y: 562, W: 100, H: 50}},
+		{PageOptions{Size: Letter, Margin: 56, Fit: true}, 612, 792, svgicon.Bounds{X: 56, Y: 271, W: 500, H: 250}},
+		{PageOptions{Size: Letter, Landscape: true, Margin: 6, Fit: true}, 792, 612, svgicon.Bounds{X: 6, Y: 111, W: 780, H: 390}},
+	} {
+		width, height := test.opts.pageSize()
+		if width != test.width || height != test.height {
+			t.Errorf("%v: expected a %gx%g page, got %gx%g", test.opts, test.width, test.height, width, height)
+		}
+		rect := test.opts.iconRect(icon.ViewBox, width, height)
+		if math.Abs(rect.X-test.rect.X) > 1e-9 || math.Abs(rect.Y-test.rect.Y) > 1e-9 ||
+			math.Abs(rect.W-test.rect.W) > 1e-9 || math.Abs(rect.H-test.rect.H) > 1e-9 {
+			t.Errorf("%v: expected %v, got %v", test.opts, test.rect, rect)
+		}
+	}
+}
+
+func TestRenderPageOptions(t *testing.T) {
+	testutil.SkipWithoutCharset(t)
+	for name, opts := range map[string]PageOptions{
+		"letter":    {Size: Letter, Margin: 36, Fit: true},
+		"landscape": {Landscape: true, Margin: 20, Fit: true},
+	} {
+		f, err := os.Open("../svgicon/testdata/landscapeIcons/beach.svg")
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = RenderSVGIconToPDFOptions(f, fmt.Sprintf("testdata_out/beach_%s.pdf", name), opts)
+		f.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		icon, err := svgicon.ReadIcon("../svgicon/testdata/landscapeIcons/beach.svg", svgicon.WarnErrorMode)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ap := drawPage(icon, opts)
+		bbox := ap.ToXFormObject(false).BBox
+		width, height := opts.pageSize()
+		if bbox.Urx != model.Fl(width) || bbox.Ury != model.Fl(height) {
+			t.Errorf("%s: unexpected page %v", name, bbox)
+		}
+		if opts.Landscape && bbox.Urx <= bbox.Ury {
+			t.Errorf("%s: expected a landscape page, got %v", name, bbox)
+		}
+	}
+}
+
+// pathExtent returns the extent of the points of the
+// paths written in `content`, in the user space.
+func pathExtent(content string) (minX, minY, maxX, maxY float64) {
+	minX, minY, maxX, maxY = math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)
+	fields := strings.Fields(content)
+	for i, op := range fields {
+		if (op != "m" && op != "l") || i < 2 {
+			continue
+		}
+		var x, y float64
+		if _, err := fmt.Sscan(fields[i-2], &x); err != nil {
+			continue
+		}
+		if _, err := fmt.Sscan(fields[i-1], &y); err != nil {
+			continue
+		}
+		minX, minY = math.Min(minX, x), math.Min(minY, y)
+		maxX, maxY = math.Max(maxX, x), math.Max(maxY, y)
+	}
+	return
+}
+
+func TestRenderSmallIcon(t *testing.T) {
+	const src = `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 24 24">
+	<rect width="24" height="24" fill="red"/>
+	</svg>`
+	icon, err := svgicon.ReadIconStream(strings.NewReader(src), svgicon.StrictErrorMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ap := drawPage(icon, DefaultPageOptions)
+	minX, minY, maxX, maxY := pathExtent(string(ap.ToXFormObject(false).Content))
+	width, height := DefaultPageOptions.pageSize()
+	// the icon spans the page width, inside the margins
+	if minX != 36 || math.Abs(maxX-(width-36)) > 0.1 {
+		t.Errorf("expected the icon to fill the page width, got [%g, %g]", minX, maxX)
+	}
+	if maxY-minY < height/2 {
+		t.Errorf("expected the icon to fill a reasonable portion of the page, got [%g, %g]", minY, maxY)
+	}
+
+	if err = RenderSVGIconToPDF(strings.NewReader(src), "testdata_out/small_icon.pdf"); err != nil {
+		t.Fatal(err)
+	}
+}
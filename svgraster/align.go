@@ -0,0 +1,45 @@
+package svgraster
+
+import (
+	"image"
+
+	"github.com/benoitkugler/oksvg/svgicon"
+)
+
+// alignedStroke implements the inner and outer stroke alignments : the path is stroked
+// with a double line width, and the stroke is masked by the interior of the path
+// (or its exterior).
+type alignedStroke struct {
+	alignment     svgicon.StrokeAlignment
+	path          svgicon.Path // the stroked path, recorded if alignment is not centered
+	width, height int
+}
+
+func (a *alignedStroke) record(op svgicon.Operation) {
+	if a.alignment != svgicon.CenterAlignment {
+		a.path = append(a.path, op)
+	}
+}
+
+// restrict returns the intersection of `clip` and the
+// side of the path where the stroke is drawn.
+func (a *alignedStroke) restrict(clip *clipMask) *clipMask {
+	mask := rasterizeMask(a.path, a.width, a.height)
+	if a.alignment == svgicon.OuterAlignment {
+		for i, v := range mask.Pix {
+			mask.Pix[i] = 0xff - v
+		}
+	}
+	if clip.mask != nil {
+		intersectMasks(mask, clip.mask)
+	}
+	return &clipMask{mask: mask}
+}
+
+// intersectMasks stores the intersection of `dst` and `src`,
+// which must have the same size, into `dst`
+func intersectMasks(dst, src *image.Alpha) {
+	for i, a := range src.Pix {
+		dst.Pix[i] = uint8(uint32(dst.Pix[i]) * uint32(a) / 0xff)
+	}
+}
@@ -0,0 +1,162 @@
+package svgraster
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+
+	"github.com/benoitkugler/oksvg/svgicon"
+	"github.com/srwiley/rasterx"
+)
+
+// blender stores the current blend mode, and the destination
+// image, which is read to blend the painted colors with the backdrop
+type blender struct {
+	mode svgicon.BlendMode
+	dst  draw.Image // nil if the scanner does not expose its destination
+}
+
+func newBlender(scanner rasterx.Scanner) *blender {
+	if sc, ok := scanner.(*rasterx.ScannerGV); ok {
+		return &blender{dst: sc.Dest}
+	}
+	return &blender{}
+}
+
+// apply returns a color function which blends `clr` with the
+// destination, so that painting it over the destination gives
+// the blended result. `clr` is returned if no blending is required.
+func (b *blender) apply(clr interface{}) interface{} {
+	if b.mode == svgicon.NormalBlend || b.dst == nil {
+		return clr
+	}
+	// the coordinates given to the color functions are
+	// relative to the destination bounds
+	origin, dst, mode := b.dst.Bounds().Min, b.dst, b.mode
+	at := func(c color.Color, x, y int) color.Color {
+		return blendColors(mode, c, dst.At(x+origin.X, y+origin.Y))
+	}
+	switch clr := clr.(type) {
+	case color.Color:
+		return rasterx.ColorFunc(func(x, y int) color.Color { return at(clr, x, y) })
+	case rasterx.ColorFunc:
+		return rasterx.ColorFunc(func(x, y int) color.Color { return at(clr(x, y), x, y) })
+	}
+	return clr
+}
+
+// blendColors returns the (premultiplied) color to composite over `backdrop`,
+// which is, per channel :
+//
+//	as * (1 - ab) * Cs + as * ab * B(Cb, Cs)
+func blendColors(mode svgicon.BlendMode, source, backdrop color.Color) color.Color {
+	sr, sg, sb, sa := source.RGBA()
+	if sa == 0 {
+		return color.RGBA64{}
+	}
+	br, bg, bb, ba := backdrop.RGBA()
+	as, ab := float64(sa)/0xffff, float64(ba)/0xffff
+	channel := func(s, b uint32) uint16 {
+		cs := float64(s) / float64(sa) // un-premultiply
+		var cb float64
+		if ba != 0 {
+			cb = float64(b) / float64(ba)
+		}
+		v := as*(1-ab)*cs + as*ab*blendChannel(mode, cb, cs)
+		return uint16(math.Round(math.Min(v, as) * 0xffff))
+	}
+	return color.RGBA64{R: channel(sr, br), G: channel(sg, bg), B: channel(sb, bb), A: uint16(sa)}
+}
+
+// blendChannel implements the separable blend functions,
+// for non premultiplied values in [0, 1]
+func blendChannel(mode svgicon.BlendMode, cb, cs float64) float64 {
+	switch mode {
+	case svgicon.MultiplyBlend:
+		return cb * cs
+	case svgicon.ScreenBlend:
+		return cb + cs - cb*cs
+	case svgicon.OverlayBlend:
+		return blendChannel(svgicon.HardLightBlend, cs, cb)
+	case svgicon.DarkenBlend:
+		return math.Min(cb, cs)
+	case svgicon.LightenBlend:
+		return math.Max(cb, cs)
+	case svgicon.ColorDodgeBlend:
+		if cb == 0 {
+			return 0
+		} else if cs == 1 {
+			return 1
+		}
+		return math.Min(1, cb/(1-cs))
+	case svgicon.ColorBurnBlend:
+		if cb == 1 {
+			return 1
+		} else if cs == 0 {
+			return 0
+		}
+		return 1 - math.Min(1, (1-cb)/cs)
+	case svgicon.HardLightBlend:
+		if cs <= 0.5 {
+			return cb * 2 * cs
+		}
+		return blendChannel(svgicon.ScreenBlend, cb, 2*cs-1)
+	case svgicon.SoftLightBlend:
+		if cs <= 0.5 {
+			return cb - (1-2*cs)*cb*(1-cb)
+		}
+		d := math.Sqrt(cb)
+		if cb <= 0.25 {
+			d = ((16*cb-12)*cb + 4) * cb
+		}
+		return cb + (2*cs-1)*(d-cb)
+	case svgicon.DifferenceBlend:
+		return math.Abs(cb - cs)
+	case svgicon.ExclusionBlend:
+		return cb + cs - 2*cb*cs
+	default:
+		return cs
+	}
+}
+
+// layers stores the destinations replaced by
+// the isolated groups layers
+type layers struct {
+	scanner *rasterx.ScannerGV // nil if the scanner does not expose its destination
+	saved   []draw.Image
+}
+
+func newLayers(scanner rasterx.Scanner) *layers {
+	sc, _ := scanner.(*rasterx.ScannerGV)
+	return &layers{scanner: sc}
+}
+
+// BeginLayer implements svgicon.LayerDriver, by redirecting the
+// painting (and the backdrop used for blending) to a transparent image.
+// Isolated groups are only supported when the scanner is a *rasterx.ScannerGV.
+func (rd Driver) BeginLayer() {
+	ls := rd.layers
+	if ls.scanner == nil {
+		return
+	}
+	ls.saved = append(ls.saved, ls.scanner.Dest)
+	layer := image.NewRGBA(ls.scanner.Dest.Bounds())
+	ls.scanner.Dest = layer
+	rd.blend.dst = layer
+}
+
+// EndLayer implements svgicon.LayerDriver, by compositing the
+// current layer onto the previous destination.
+func (rd Driver) EndLayer() {
+	ls := rd.layers
+	if len(ls.saved) == 0 {
+		return
+	}
+	layer := ls.scanner.Dest
+	dst := ls.saved[len(ls.saved)-1]
+	ls.saved = ls.saved[:len(ls.saved)-1]
+	draw.Draw(dst, dst.Bounds(), layer, layer.Bounds().Min, draw.Over)
+	ls.scanner.Dest = dst
+	rd.blend.dst = dst
+}
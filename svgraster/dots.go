@@ -0,0 +1,102 @@
+package svgraster
+
+import (
+	"github.com/benoitkugler/oksvg/svgicon"
+	"github.com/srwiley/rasterx"
+	"golang.org/x/image/math/fixed"
+)
+
+// subpathState tracks the current subpath, to detect
+// zero-length subpaths, which rasterx does not stroke.
+// According to the SVG specification, they must be rendered
+// as a dot for round caps, and a square for square caps.
+type subpathState struct {
+	lineCap   svgicon.CapMode
+	lineWidth fixed.Int26_6
+
+	start      fixed.Point26_6
+	started    bool // a subpath has been started
+	hasSegment bool // the subpath has a segment or is closed
+	zeroLength bool // all the points of the subpath are equal to start
+}
+
+func (st *subpathState) add(points ...fixed.Point26_6) {
+	st.hasSegment = true
+	for _, p := range points {
+		if p != st.start {
+			st.zeroLength = false
+		}
+	}
+}
+
+// Start implements svgicon.Drawer, tracking zero-length subpaths.
+func (s stroker) Start(a fixed.Point26_6) {
+	s.endSubpath(false)
+	*s.dots = subpathState{lineCap: s.dots.lineCap, lineWidth: s.dots.lineWidth, start: a, started: true, zeroLength: true}
+	s.align.record(svgicon.OpMoveTo(a))
+	s.Dasher.Start(a)
+}
+
+// Line implements svgicon.Drawer, tracking zero-length subpaths.
+func (s stroker) Line(b fixed.Point26_6) {
+	s.dots.add(b)
+	s.align.record(svgicon.OpLineTo(b))
+	s.Dasher.Line(b)
+}
+
+// QuadBezier implements svgicon.Drawer, tracking zero-length subpaths.
+func (s stroker) QuadBezier(b, c fixed.Point26_6) {
+	s.dots.add(b, c)
+	s.align.record(svgicon.OpQuadTo{b, c})
+	s.Dasher.QuadBezier(b, c)
+}
+
+// CubeBezier implements svgicon.Drawer, tracking zero-length subpaths.
+func (s stroker) CubeBezier(b, c, d fixed.Point26_6) {
+	s.dots.add(b, c, d)
+	s.align.record(svgicon.OpCubicTo{b, c, d})
+	s.Dasher.CubeBezier(b, c, d)
+}
+
+// Stop implements svgicon.Drawer, adding the caps of a zero-length subpath.
+func (s stroker) Stop(closeLoop bool) {
+	s.Dasher.Stop(closeLoop)
+	s.endSubpath(closeLoop)
+	if closeLoop {
+		s.align.record(svgicon.OpClose{})
+	}
+}
+
+// endSubpath adds the cap geometry for the current subpath, if it
+// has a zero length, directly to the filled path.
+func (s stroker) endSubpath(closed bool) {
+	st := s.dots
+	if !st.started {
+		return
+	}
+	st.started = false
+	if !(st.hasSegment || closed) || !st.zeroLength || st.lineWidth <= 0 {
+		return
+	}
+	x, y := float64(st.start.X)/64, float64(st.start.Y)/64
+	u := float64(st.lineWidth) / 128 // half of the line width
+	switch st.lineCap {
+	case svgicon.RoundCap:
+		addCircle(x, y, u, &s.Dasher.Filler)
+	case svgicon.SquareCap:
+		rasterx.AddRect(x-u, y-u, x+u, y+u, 0, &s.Dasher.Filler)
+	}
+}
+
+// addCircle adds a circle approximated by four cubic
+// Bézier curves to `p`
+func addCircle(cx, cy, r float64, p rasterx.Adder) {
+	k := r * 0.5522847498 // control point distance
+	pt := func(x, y float64) fixed.Point26_6 { return rasterx.ToFixedP(cx+x, cy+y) }
+	p.Start(pt(r, 0))
+	p.CubeBezier(pt(r, k), pt(k, r), pt(0, r))
+	p.CubeBezier(pt(-k, r), pt(-r, k), pt(-r, 0))
+	p.CubeBezier(pt(-r, -k), pt(-k, -r), pt(0, -r))
+	p.CubeBezier(pt(k, -r), pt(r, -k), pt(r, 0))
+	p.Stop(true)
+}
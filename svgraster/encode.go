@@ -0,0 +1,87 @@
+package svgraster
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/benoitkugler/oksvg/svgicon"
+	"github.com/srwiley/rasterx"
+)
+
+// FormatFromFilename returns the image format matching the extension
+// of `filename` ("png", "jpeg" or "gif"), or an empty string if
+// the extension is not supported by EncodeIcon.
+func FormatFromFilename(filename string) string {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".png":
+		return "png"
+	case ".jpg", ".jpeg":
+		return "jpeg"
+	case ".gif":
+		return "gif"
+	default:
+		return ""
+	}
+}
+
+// EncodeIcon rasterizes the SVG `icon` into an image of size `width` x `height`
+// and writes it to `w`, encoded with `format`, which is one of "png", "jpeg" (or "jpg") and "gif".
+// If `width` or `height` is not positive, the size of the icon view box is used instead.
+// Since JPEG does not support transparency, the image is flattened onto a white
+// background: see EncodeIconOnBackground to use another color.
+func EncodeIcon(icon io.Reader, w io.Writer, format string, width, height int) error {
+	return EncodeIconOnBackground(icon, w, format, width, height, color.White)
+}
+
+// EncodeIconOnBackground is the same as EncodeIcon, but flattens
+// JPEG images onto `background`. It is ignored for the other formats.
+func EncodeIconOnBackground(icon io.Reader, w io.Writer, format string, width, height int, background color.Color) error {
+	format = strings.ToLower(format)
+	switch format {
+	case "png", "jpeg", "jpg", "gif":
+	default:
+		return fmt.Errorf("unsupported image format %s", format)
+	}
+
+	parsedIcon, err := svgicon.ReadIconStream(icon, svgicon.WarnErrorMode)
+	if err != nil {
+		return err
+	}
+	if width <= 0 || height <= 0 {
+		iconWidth, iconHeight, err := iconSize(parsedIcon)
+		if err != nil {
+			return err
+		}
+		if width <= 0 {
+			width = iconWidth
+		}
+		if height <= 0 {
+			height = iconHeight
+		}
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	if format == "jpeg" || format == "jpg" {
+		draw.Draw(img, img.Bounds(), image.NewUniform(background), image.Point{}, draw.Src)
+	}
+	parsedIcon.SetTarget(0, 0, float64(width), float64(height))
+	scanner := rasterx.NewScannerGV(width, height, img, img.Bounds())
+	parsedIcon.Draw(NewDriver(width, height, scanner), 1.0)
+
+	switch format {
+	case "png":
+		return png.Encode(w, img)
+	case "gif":
+		return gif.Encode(w, img, nil)
+	default:
+		return jpeg.Encode(w, img, nil)
+	}
+}
@@ -0,0 +1,298 @@
+package svgraster
+
+import (
+	"image"
+	"math"
+	"sort"
+
+	"github.com/benoitkugler/oksvg/svgicon"
+	"golang.org/x/image/math/fixed"
+)
+
+// evenOddFill implements the even-odd fill rule, which is not supported
+// by rasterx.ScannerGV : the path is recorded, and its even-odd interior
+// is used as a mask when filling its bounding box.
+// It is shared by the fillers of a Driver, so that its buffers are reused.
+type evenOddFill struct {
+	enabled       bool
+	path          svgicon.Path
+	width, height int
+	pix           []uint8 // backing buffer of the mask
+}
+
+// reset prepares the fill for a new path, keeping the buffers.
+func (e *evenOddFill) reset(width, height int) {
+	*e = evenOddFill{path: e.path[:0], pix: e.pix, width: width, height: height}
+}
+
+func (e *evenOddFill) record(op svgicon.Operation) {
+	if e.enabled {
+		e.path = append(e.path, op)
+	}
+}
+
+// mask returns the even-odd interior of the recorded path,
+// restricted to `clip`. The mask only covers the bounding box of the path,
+// and is only valid until the next call.
+func (e *evenOddFill) mask(clip *clipMask) *clipMask {
+	min, max := e.bounds()
+	rect := image.Rect(min.X.Floor(), min.Y.Floor(), max.X.Ceil(), max.Y.Ceil()).Intersect(image.Rect(0, 0, e.width, e.height))
+	if n := rect.Dx() * rect.Dy(); cap(e.pix) < n {
+		e.pix = make([]uint8, n)
+	} else {
+		e.pix = e.pix[:n]
+		for i := range e.pix {
+			e.pix[i] = 0
+		}
+	}
+	mask := &image.Alpha{Pix: e.pix, Stride: rect.Dx(), Rect: rect}
+	rasterizeEvenOdd(e.path, mask)
+	if clip.mask != nil {
+		for y := rect.Min.Y; y < rect.Max.Y; y++ {
+			line := mask.Pix[mask.PixOffset(rect.Min.X, y):mask.PixOffset(rect.Max.X, y)]
+			clipLine := clip.mask.Pix[clip.mask.PixOffset(rect.Min.X, y):]
+			for i, a := range line {
+				line[i] = uint8(uint32(a) * uint32(clipLine[i]) / 0xff)
+			}
+		}
+	}
+	return &clipMask{mask: mask}
+}
+
+// evenOddSamples is the number of sub-scanlines
+// sampled for each row of pixels
+const evenOddSamples = 16
+
+// segment is a line of a flattened path
+type segment struct {
+	x0, y0, x1, y1 float64
+}
+
+// flatten approximates the (implicitly closed) subpaths of `path` by lines.
+func flatten(path svgicon.Path) []segment {
+	var (
+		out            []segment
+		x, y, sx, sy   float64
+		started        bool
+		toFloat        = func(p fixed.Point26_6) (float64, float64) { return float64(p.X) / 64, float64(p.Y) / 64 }
+		lineTo, closeP func(nx, ny float64)
+	)
+	lineTo = func(nx, ny float64) {
+		if ny != y { // horizontal lines never cross a scanline
+			out = append(out, segment{x, y, nx, ny})
+		}
+		x, y = nx, ny
+	}
+	closeP = func(nx, ny float64) {
+		if started {
+			lineTo(sx, sy)
+		}
+		x, y, sx, sy, started = nx, ny, nx, ny, true
+	}
+	// curveTo subdivides the curve given by its point function,
+	// with a number of lines depending on the length of the control polygon
+	curveTo := func(controlLength float64, at func(t float64) (float64, float64)) {
+		n := int(math.Ceil(math.Sqrt(controlLength)))*2 + 1
+		if n > 100 {
+			n = 100
+		}
+		for i := 1; i <= n; i++ {
+			lineTo(at(float64(i) / float64(n)))
+		}
+	}
+	for _, op := range path {
+		switch op := op.(type) {
+		case svgicon.OpMoveTo:
+			closeP(toFloat(fixed.Point26_6(op)))
+		case svgicon.OpLineTo:
+			lineTo(toFloat(fixed.Point26_6(op)))
+		case svgicon.OpQuadTo:
+			x0, y0 := x, y
+			x1, y1 := toFloat(op[0])
+			x2, y2 := toFloat(op[1])
+			curveTo(math.Hypot(x1-x0, y1-y0)+math.Hypot(x2-x1, y2-y1), func(t float64) (float64, float64) {
+				u := 1 - t
+				return u*u*x0 + 2*u*t*x1 + t*t*x2, u*u*y0 + 2*u*t*y1 + t*t*y2
+			})
+		case svgicon.OpCubicTo:
+			x0, y0 := x, y
+			x1, y1 := toFloat(op[0])
+			x2, y2 := toFloat(op[1])
+			x3, y3 := toFloat(op[2])
+			curveTo(math.Hypot(x1-x0, y1-y0)+math.Hypot(x2-x1, y2-y1)+math.Hypot(x3-x2, y3-y2), func(t float64) (float64, float64) {
+				u := 1 - t
+				return u*u*u*x0 + 3*u*u*t*x1 + 3*u*t*t*x2 + t*t*t*x3, u*u*u*y0 + 3*u*u*t*y1 + 3*u*t*t*y2 + t*t*t*y3
+			})
+		case svgicon.OpClose:
+			closeP(sx, sy)
+		}
+	}
+	closeP(x, y)
+	return out
+}
+
+// rasterizeEvenOdd writes into `mask` the coverage of the even-odd interior
+// of `path`, restricted to mask.Rect. `mask` is expected to be cleared.
+// Each row of pixels is sampled by several horizontal scanlines: the
+// parity of the crossings gives the inside spans, whose horizontal
+// coverage is exact.
+func rasterizeEvenOdd(path svgicon.Path, mask *image.Alpha) {
+	segments := flatten(path)
+	if len(segments) == 0 || mask.Rect.Empty() {
+		return
+	}
+	// the segments are sorted by their top, so that
+	// only the ones crossing the current row are considered
+	top := func(seg segment) float64 { return math.Min(seg.y0, seg.y1) }
+	bottom := func(seg segment) float64 { return math.Max(seg.y0, seg.y1) }
+	sort.Slice(segments, func(i, j int) bool { return top(segments[i]) < top(segments[j]) })
+	maxY := math.Inf(-1)
+	for _, seg := range segments {
+		maxY = math.Max(maxY, bottom(seg))
+	}
+	bounds := mask.Rect
+	firstRow := int(math.Max(math.Floor(top(segments[0])), float64(bounds.Min.Y)))
+	lastRow := int(math.Min(math.Ceil(maxY), float64(bounds.Max.Y)))
+
+	// the coverage is indexed from bounds.Min.X
+	width := bounds.Dx()
+	coverage := make([]float64, width)
+	var crossings []float64
+	addSpan := func(a, b float64) {
+		a, b = math.Max(a-float64(bounds.Min.X), 0), math.Min(b-float64(bounds.Min.X), float64(width))
+		if a >= b {
+			return
+		}
+		ia, ib := int(a), int(b)
+		if ia == ib {
+			coverage[ia] += b - a
+			return
+		}
+		coverage[ia] += float64(ia+1) - a
+		for i := ia + 1; i < ib; i++ {
+			coverage[i]++
+		}
+		if ib < width {
+			coverage[ib] += b - float64(ib)
+		}
+	}
+	var (
+		active []segment
+		next   int // index of the first segment not yet active
+	)
+	for row := firstRow; row < lastRow; row++ {
+		for i := range coverage {
+			coverage[i] = 0
+		}
+		rowTop, rowBottom := float64(row), float64(row+1)
+		kept := active[:0]
+		for _, seg := range active {
+			if bottom(seg) > rowTop {
+				kept = append(kept, seg)
+			}
+		}
+		active = kept
+		for ; next < len(segments) && top(segments[next]) < rowBottom; next++ {
+			if bottom(segments[next]) > rowTop {
+				active = append(active, segments[next])
+			}
+		}
+		for s := 0; s < evenOddSamples; s++ {
+			y := rowTop + (float64(s)+0.5)/evenOddSamples
+			crossings = crossings[:0]
+			for _, seg := range active {
+				if (seg.y0 <= y) == (seg.y1 <= y) { // half open interval
+					continue
+				}
+				crossings = append(crossings, seg.x0+(y-seg.y0)*(seg.x1-seg.x0)/(seg.y1-seg.y0))
+			}
+			sort.Float64s(crossings)
+			for i := 0; i+1 < len(crossings); i += 2 {
+				addSpan(crossings[i], crossings[i+1])
+			}
+		}
+		line := mask.Pix[mask.PixOffset(bounds.Min.X, row):mask.PixOffset(bounds.Max.X, row)]
+		for i, c := range coverage {
+			line[i] = uint8(math.Min(math.Round(c*0xff/evenOddSamples), 0xff))
+		}
+	}
+}
+
+// bounds returns the bounding box of the control points of the recorded path.
+func (e *evenOddFill) bounds() (min, max fixed.Point26_6) {
+	first := true
+	add := func(p fixed.Point26_6) {
+		if first {
+			min, max, first = p, p, false
+			return
+		}
+		if p.X < min.X {
+			min.X = p.X
+		}
+		if p.Y < min.Y {
+			min.Y = p.Y
+		}
+		if p.X > max.X {
+			max.X = p.X
+		}
+		if p.Y > max.Y {
+			max.Y = p.Y
+		}
+	}
+	for _, op := range e.path {
+		switch op := op.(type) {
+		case svgicon.OpMoveTo:
+			add(fixed.Point26_6(op))
+		case svgicon.OpLineTo:
+			add(fixed.Point26_6(op))
+		case svgicon.OpQuadTo:
+			add(op[0])
+			add(op[1])
+		case svgicon.OpCubicTo:
+			add(op[0])
+			add(op[1])
+			add(op[2])
+		}
+	}
+	return min, max
+}
+
+// SetWinding implements svgicon.Filler, enabling the
+// even-odd rule when `useNonZeroWinding` is false.
+func (f filler) SetWinding(useNonZeroWinding bool) {
+	f.evenOdd.enabled = !useNonZeroWinding
+	f.evenOdd.path = f.evenOdd.path[:0]
+	f.Filler.SetWinding(useNonZeroWinding)
+}
+
+// Start implements svgicon.Drawer, recording the path for the even-odd rule.
+func (f filler) Start(a fixed.Point26_6) {
+	f.evenOdd.record(svgicon.OpMoveTo(a))
+	f.Filler.Start(a)
+}
+
+// Line implements svgicon.Drawer, recording the path for the even-odd rule.
+func (f filler) Line(b fixed.Point26_6) {
+	f.evenOdd.record(svgicon.OpLineTo(b))
+	f.Filler.Line(b)
+}
+
+// QuadBezier implements svgicon.Drawer, recording the path for the even-odd rule.
+func (f filler) QuadBezier(b, c fixed.Point26_6) {
+	f.evenOdd.record(svgicon.OpQuadTo{b, c})
+	f.Filler.QuadBezier(b, c)
+}
+
+// CubeBezier implements svgicon.Drawer, recording the path for the even-odd rule.
+func (f filler) CubeBezier(b, c, d fixed.Point26_6) {
+	f.evenOdd.record(svgicon.OpCubicTo{b, c, d})
+	f.Filler.CubeBezier(b, c, d)
+}
+
+// Stop implements svgicon.Drawer, recording the path for the even-odd rule.
+func (f filler) Stop(closeLoop bool) {
+	if closeLoop {
+		f.evenOdd.record(svgicon.OpClose{})
+	}
+	f.Filler.Stop(closeLoop)
+}
@@ -0,0 +1,176 @@
+package svgraster
+
+import (
+	"image"
+	"image/draw"
+	"math"
+
+	"github.com/benoitkugler/oksvg/svgicon"
+	"github.com/srwiley/rasterx"
+)
+
+// filterLayer redirects the painting of the filtered
+// paths to an offscreen image
+type filterLayer struct {
+	scanner *rasterx.ScannerGV // nil if the scanner does not expose its destination
+	dst     draw.Image         // the original destination, when filtering
+	layer   *image.RGBA        // nil when not filtering
+	filter  svgicon.Filter
+}
+
+func newFilterLayer(scanner rasterx.Scanner) *filterLayer {
+	sc, _ := scanner.(*rasterx.ScannerGV)
+	return &filterLayer{scanner: sc}
+}
+
+// BeginFilter implements svgicon.FilterDriver, by redirecting
+// the painting to a transparent offscreen image.
+// Filters are ignored for scanners other than *rasterx.ScannerGV.
+func (rd Driver) BeginFilter(filter svgicon.Filter) {
+	fl := rd.filter
+	if fl.scanner == nil {
+		return
+	}
+	fl.dst, fl.filter = fl.scanner.Dest, filter
+	fl.layer = image.NewRGBA(fl.dst.Bounds())
+	fl.scanner.Dest = fl.layer
+}
+
+// EndFilter implements svgicon.FilterDriver, by applying the
+// filter to the offscreen image and compositing the result
+// onto the original destination.
+func (rd Driver) EndFilter() {
+	fl := rd.filter
+	if fl.layer == nil {
+		return
+	}
+	out := applyFilter(fl.filter, fl.layer)
+	draw.Draw(fl.dst, fl.dst.Bounds(), out, out.Bounds().Min, draw.Over)
+	fl.scanner.Dest = fl.dst
+	fl.dst, fl.layer = nil, nil
+}
+
+// applyFilter evaluates the primitives of `filter`, using
+// `source` as SourceGraphic, and returns the result of the last one.
+func applyFilter(filter svgicon.Filter, source *image.RGBA) *image.RGBA {
+	results := map[string]*image.RGBA{"SourceGraphic": source}
+	last := source
+	input := func(name string) *image.RGBA {
+		if name == "" {
+			return last
+		}
+		if name == "SourceAlpha" {
+			if _, has := results[name]; !has {
+				results[name] = sourceAlpha(source)
+			}
+		}
+		if img, has := results[name]; has {
+			return img
+		}
+		// an unknown reference is an error in SVG: use a transparent image
+		return image.NewRGBA(source.Bounds())
+	}
+	for _, pr := range filter.Primitives {
+		var result string
+		switch pr := pr.(type) {
+		case svgicon.FeGaussianBlur:
+			last = gaussianBlur(input(pr.In), pr.StdDeviationX, pr.StdDeviationY)
+			result = pr.Result
+		case svgicon.FeOffset:
+			last = offset(input(pr.In), int(math.Round(pr.Dx)), int(math.Round(pr.Dy)))
+			result = pr.Result
+		case svgicon.FeMerge:
+			out := image.NewRGBA(source.Bounds())
+			for _, node := range pr.Nodes {
+				draw.Draw(out, out.Bounds(), input(node), out.Bounds().Min, draw.Over)
+			}
+			last = out
+			result = pr.Result
+		}
+		if result != "" {
+			results[result] = last
+		}
+	}
+	return last
+}
+
+// sourceAlpha returns a black image with the alpha channel of `img`
+func sourceAlpha(img *image.RGBA) *image.RGBA {
+	out := image.NewRGBA(img.Bounds())
+	for i := 3; i < len(img.Pix); i += 4 {
+		out.Pix[i] = img.Pix[i]
+	}
+	return out
+}
+
+// offset returns a copy of `img` translated by (dx, dy)
+func offset(img *image.RGBA, dx, dy int) *image.RGBA {
+	out := image.NewRGBA(img.Bounds())
+	draw.Draw(out, out.Bounds(), img, out.Bounds().Min.Sub(image.Pt(dx, dy)), draw.Src)
+	return out
+}
+
+// gaussianBlur approximates a gaussian blur with three
+// successive box blurs, as suggested by the SVG specification.
+func gaussianBlur(img *image.RGBA, stdDevX, stdDevY float64) *image.RGBA {
+	w, h := img.Rect.Dx(), img.Rect.Dy()
+	out := image.NewRGBA(img.Bounds())
+	copy(out.Pix, img.Pix)
+	tmp := make([]uint8, len(out.Pix))
+	if r := boxRadius(stdDevX); r > 0 {
+		for y := 0; y < h; y++ {
+			for pass := 0; pass < 3; pass++ {
+				blurLine(out.Pix, tmp, y*out.Stride, 4, w, r)
+				copyLine(tmp, out.Pix, y*out.Stride, 4, w)
+			}
+		}
+	}
+	if r := boxRadius(stdDevY); r > 0 {
+		for x := 0; x < w; x++ {
+			for pass := 0; pass < 3; pass++ {
+				blurLine(out.Pix, tmp, 4*x, out.Stride, h, r)
+				copyLine(tmp, out.Pix, 4*x, out.Stride, h)
+			}
+		}
+	}
+	return out
+}
+
+// boxRadius returns the radius of the box blur approximating
+// a gaussian blur with standard deviation `stdDev`
+func boxRadius(stdDev float64) int {
+	if stdDev <= 0 {
+		return 0
+	}
+	d := math.Floor(stdDev*3*math.Sqrt(2*math.Pi)/4 + 0.5)
+	return int(d) / 2
+}
+
+// blurLine applies a box blur of radius `r` to the `n` pixels of `src`
+// starting at `start` and separated by `step` bytes, writing the result into `dst`.
+// Pixels outside the line are transparent.
+func blurLine(src, dst []uint8, start, step, n, r int) {
+	size := uint32(2*r + 1)
+	for c := 0; c < 4; c++ {
+		var sum uint32
+		for i := 0; i < r && i < n; i++ {
+			sum += uint32(src[start+i*step+c])
+		}
+		for i := 0; i < n; i++ {
+			if j := i + r; j < n {
+				sum += uint32(src[start+j*step+c])
+			}
+			dst[start+i*step+c] = uint8(sum / size)
+			if j := i - r; j >= 0 {
+				sum -= uint32(src[start+j*step+c])
+			}
+		}
+	}
+}
+
+// copyLine copies the pixels of a line from `src` to `dst`
+func copyLine(src, dst []uint8, start, step, n int) {
+	for i := 0; i < n; i++ {
+		copy(dst[start+i*step:start+i*step+4], src[start+i*step:start+i*step+4])
+	}
+}
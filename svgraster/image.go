@@ -0,0 +1,50 @@
+package svgraster
+
+import (
+	"image/color"
+	"math"
+
+	"github.com/benoitkugler/oksvg/svgicon"
+	"github.com/srwiley/rasterx"
+)
+
+// DrawImage implements svgicon.ImageDriver, by filling the rectangle
+// covered by the image with its pixels (using the nearest neighbor).
+func (rd Driver) DrawImage(img svgicon.Image, m svgicon.Matrix2D, opacity float64) {
+	b, src := img.Bounds, img.Image
+	srcBounds := src.Bounds()
+	if srcBounds.Empty() || b.W <= 0 || b.H <= 0 {
+		return
+	}
+	// mapping from the image pixels to the destination
+	toDst := m.Translate(b.X, b.Y).Scale(b.W/float64(srcBounds.Dx()), b.H/float64(srcBounds.Dy()))
+	toSrc := toDst.Invert()
+	colorAt := func(x, y int) color.Color {
+		sx, sy := toSrc.Transform(float64(x)+0.5, float64(y)+0.5)
+		px := clampInt(int(math.Floor(sx)), 0, srcBounds.Dx()-1) + srcBounds.Min.X
+		py := clampInt(int(math.Floor(sy)), 0, srcBounds.Dy()-1) + srcBounds.Min.Y
+		return applyOpacity(src.At(px, py), opacity)
+	}
+
+	f := &rd.dasher.Filler
+	f.Clear()
+	f.SetWinding(true)
+	w, h := float64(srcBounds.Dx()), float64(srcBounds.Dy())
+	f.Start(rasterx.ToFixedP(toDst.Transform(0, 0)))
+	f.Line(rasterx.ToFixedP(toDst.Transform(w, 0)))
+	f.Line(rasterx.ToFixedP(toDst.Transform(w, h)))
+	f.Line(rasterx.ToFixedP(toDst.Transform(0, h)))
+	f.Stop(true)
+	f.SetColor(rd.clip.apply(rd.blend.apply(rasterx.ColorFunc(colorAt))))
+	f.Draw()
+}
+
+func clampInt(v, min, max int) int {
+	if v < min {
+		return min
+	}
+	if v > max {
+		return max
+	}
+	return v
+}
@@ -3,48 +3,203 @@
 package svgraster
 
 import (
+	"fmt"
 	"image"
+	"image/color"
+	"image/draw"
 	"io"
 
 	"github.com/benoitkugler/oksvg/svgicon"
 	"github.com/srwiley/rasterx"
+	"golang.org/x/image/font/sfnt"
+	"golang.org/x/image/vector"
 )
 
 // assert interface conformance
 var (
-	_ svgicon.Driver  = Driver{}
-	_ svgicon.Filler  = filler{}
-	_ svgicon.Stroker = stroker{}
+	_ svgicon.Driver       = Driver{}
+	_ svgicon.ClipDriver   = Driver{}
+	_ svgicon.BlendDriver  = Driver{}
+	_ svgicon.FilterDriver = Driver{}
+	_ svgicon.LayerDriver  = Driver{}
+	_ svgicon.TextDriver   = Driver{}
+	_ svgicon.ImageDriver  = Driver{}
+	_ svgicon.Filler       = filler{}
+	_ svgicon.Stroker      = stroker{}
 )
 
 type Driver struct {
-	dasher *rasterx.Dasher
+	dasher        *rasterx.Dasher
+	width, height int
+	clip          *clipMask
+	blend         *blender
+	filter        *filterLayer
+	layers        *layers
+	evenOdd       *evenOddFill // shared by the fillers
+	font          *sfnt.Font   // used to draw texts, nil for the bundled one
 }
 
 type filler struct {
 	*rasterx.Filler
+	clip    *clipMask
+	blend   *blender
+	evenOdd *evenOddFill
 }
 
 type stroker struct {
 	*rasterx.Dasher
+	clip  *clipMask
+	blend *blender
+	dots  *subpathState
+	align *alignedStroke
+}
+
+// clipMask stores the current clipping region,
+// as an alpha mask
+type clipMask struct {
+	mask *image.Alpha // nil for no clipping
 }
 
 // NewDriver returns a renderer with default values,
 // which will raster into `scanner`.
 func NewDriver(width, height int, scanner rasterx.Scanner) Driver {
-	return Driver{dasher: rasterx.NewDasher(width, height, scanner)}
+	return Driver{
+		dasher:  rasterx.NewDasher(width, height, scanner),
+		width:   width,
+		height:  height,
+		clip:    new(clipMask),
+		blend:   newBlender(scanner),
+		filter:  newFilterLayer(scanner),
+		layers:  newLayers(scanner),
+		evenOdd: new(evenOddFill),
+	}
+}
+
+// NewDriverInto returns a renderer drawing into the sub-rectangle
+// `bounds` of `dst`, which should be included in dst.Bounds().
+// Paths are clipped to `bounds`, whose top-left corner is the
+// origin of the drawing : see svgicon.SvgIcon.SetTarget to fit an icon
+// into the rectangle.
+func NewDriverInto(dst *image.RGBA, bounds image.Rectangle) Driver {
+	sub := dst.SubImage(bounds).(*image.RGBA)
+	w, h := bounds.Dx(), bounds.Dy()
+	scanner := rasterx.NewScannerGV(w, h, sub, sub.Bounds())
+	return NewDriver(w, h, scanner)
+}
+
+// NewRendererImage returns a renderer drawing into `img`, which may
+// be any draw.Image, such as an *image.NRGBA or an *image.RGBA64,
+// avoiding the precision loss of the 8-bit premultiplied *image.RGBA.
+// As for NewDriverInto, the top-left corner of img.Bounds() is the origin
+// of the drawing.
+// Note that the intermediate layers used by blend modes, groups
+// and filters are still *image.RGBA.
+func NewRendererImage(img draw.Image) Driver {
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	scanner := rasterx.NewScannerGV(w, h, img, bounds)
+	return NewDriver(w, h, scanner)
+}
+
+// Reset binds the driver to the new destination `dst` (which may
+// have a different size), and clears its state, so that
+// the internal buffers are reused to render another icon.
+// The content of `dst` is not modified.
+// Reset panics if the driver has not been created with a *rasterx.ScannerGV,
+// as NewDriverInto does.
+func (rd *Driver) Reset(dst *image.RGBA) {
+	scanner, ok := rd.dasher.Scanner.(*rasterx.ScannerGV)
+	if !ok {
+		panic("svgraster: Reset requires a *rasterx.ScannerGV scanner")
+	}
+	scanner.Dest, scanner.Targ = dst, dst.Bounds()
+	rd.width, rd.height = dst.Bounds().Dx(), dst.Bounds().Dy()
+	rd.dasher.SetBounds(rd.width, rd.height) // buffers are reused if possible
+
+	rd.clip.mask = nil
+	*rd.blend = blender{dst: dst}
+	*rd.filter = filterLayer{scanner: scanner}
+	*rd.layers = layers{scanner: scanner, saved: rd.layers.saved[:0]}
 }
 
 func (rd Driver) SetupDrawers(willFill, willStroke bool) (f svgicon.Filler, s svgicon.Stroker) {
 	if willFill {
-		f = filler{Filler: &rd.dasher.Filler}
+		rd.evenOdd.reset(rd.width, rd.height)
+		f = filler{Filler: &rd.dasher.Filler, clip: rd.clip, blend: rd.blend, evenOdd: rd.evenOdd}
 	}
 	if willStroke {
-		s = stroker{Dasher: rd.dasher}
+		s = stroker{Dasher: rd.dasher, clip: rd.clip, blend: rd.blend, dots: new(subpathState),
+			align: &alignedStroke{width: rd.width, height: rd.height}}
 	}
 	return f, s
 }
 
+// SetBlendMode implements svgicon.BlendDriver. Blending
+// is only supported when the scanner is a *rasterx.ScannerGV,
+// since it requires reading the destination image.
+func (rd Driver) SetBlendMode(mode svgicon.BlendMode) { rd.blend.mode = mode }
+
+// SetClip implements svgicon.ClipDriver, by rasterizing
+// the clip paths into an alpha mask.
+func (rd Driver) SetClip(clips []svgicon.Path) {
+	rd.clip.mask = nil
+	for _, clip := range clips {
+		mask := rasterizeMask(clip, rd.width, rd.height)
+		if rd.clip.mask == nil {
+			rd.clip.mask = mask
+			continue
+		}
+		// intersect with the previous clips
+		intersectMasks(rd.clip.mask, mask)
+	}
+}
+
+func rasterizeMask(path svgicon.Path, width, height int) *image.Alpha {
+	z := vector.NewRasterizer(width, height)
+	for _, op := range path {
+		switch op := op.(type) {
+		case svgicon.OpMoveTo:
+			z.MoveTo(float32(op.X)/64, float32(op.Y)/64)
+		case svgicon.OpLineTo:
+			z.LineTo(float32(op.X)/64, float32(op.Y)/64)
+		case svgicon.OpQuadTo:
+			z.QuadTo(float32(op[0].X)/64, float32(op[0].Y)/64, float32(op[1].X)/64, float32(op[1].Y)/64)
+		case svgicon.OpCubicTo:
+			z.CubeTo(float32(op[0].X)/64, float32(op[0].Y)/64, float32(op[1].X)/64, float32(op[1].Y)/64,
+				float32(op[2].X)/64, float32(op[2].Y)/64)
+		case svgicon.OpClose:
+			z.ClosePath()
+		}
+	}
+	mask := image.NewAlpha(image.Rect(0, 0, width, height))
+	z.Draw(mask, mask.Bounds(), image.Opaque, image.Point{})
+	return mask
+}
+
+// apply returns a color function restricted to the clipping region,
+// or `clr` if there is no clipping
+func (cm *clipMask) apply(clr interface{}) interface{} {
+	mask := cm.mask
+	if mask == nil {
+		return clr
+	}
+	at := func(c color.Color, x, y int) color.Color {
+		a := uint32(mask.AlphaAt(x, y).A)
+		r, g, b, ca := c.RGBA()
+		return color.RGBA64{
+			R: uint16(r * a / 0xff), G: uint16(g * a / 0xff),
+			B: uint16(b * a / 0xff), A: uint16(ca * a / 0xff),
+		}
+	}
+	switch clr := clr.(type) {
+	case color.Color:
+		return rasterx.ColorFunc(func(x, y int) color.Color { return at(clr, x, y) })
+	case rasterx.ColorFunc:
+		return rasterx.ColorFunc(func(x, y int) color.Color { return at(clr(x, y), x, y) })
+	}
+	return clr
+}
+
 // RasterSVGIconToImage uses a default scanner rasterx.ScannerGV instance to renderer the
 // icon into an image and return it.
 func RasterSVGIconToImage(icon io.Reader) (*image.RGBA, error) {
@@ -52,7 +207,10 @@ func RasterSVGIconToImage(icon io.Reader) (*image.RGBA, error) {
 	if err != nil {
 		return nil, err
 	}
-	w, h := int(parsedIcon.ViewBox.W), int(parsedIcon.ViewBox.H)
+	w, h, err := iconSize(parsedIcon)
+	if err != nil {
+		return nil, err
+	}
 	img := image.NewRGBA(image.Rect(0, 0, w, h))
 
 	scanner := rasterx.NewScannerGV(w, h, img, img.Bounds())
@@ -61,6 +219,18 @@ func RasterSVGIconToImage(icon io.Reader) (*image.RGBA, error) {
 	return img, nil
 }
 
+// iconSize returns the size of the view box of `icon`, in pixels,
+// or an error if it is empty. When the view box is missing, the parser
+// uses the absolute width and height attributes instead.
+func iconSize(icon *svgicon.SvgIcon) (width, height int, err error) {
+	width, height = int(icon.ViewBox.W), int(icon.ViewBox.H)
+	if width <= 0 || height <= 0 {
+		return 0, 0, fmt.Errorf("the size of the icon can't be determined (view box: %v, width: %q, height: %q)",
+			icon.ViewBox, icon.Width, icon.Height)
+	}
+	return width, height, nil
+}
+
 func toRasterxGradient(grad svgicon.Gradient) rasterx.Gradient {
 	var (
 		points   [5]float64
@@ -71,12 +241,12 @@ func toRasterxGradient(grad svgicon.Gradient) rasterx.Gradient {
 		points[0], points[1], points[2], points[3] = dir[0], dir[1], dir[2], dir[3]
 		isRadial = false
 	case svgicon.Radial:
-		points[0], points[1], points[2], points[3], points[4], _ = dir[0], dir[1], dir[2], dir[3], dir[4], dir[5] // in rasterx fr is ignored
+		points[0], points[1], points[2], points[3], points[4], _ = dir[0], dir[1], dir[2], dir[3], dir[4], dir[5] // in rasterx fr is ignored, see gradientColorFunction
 		isRadial = true
 	}
 	stops := make([]rasterx.GradStop, len(grad.Stops))
-	for i := range grad.Stops {
-		stops[i] = rasterx.GradStop(grad.Stops[i])
+	for i, stop := range straightStops(grad.Stops) {
+		stops[i] = rasterx.GradStop(stop)
 	}
 	return rasterx.Gradient{
 		Points:   points,
@@ -89,25 +259,64 @@ func toRasterxGradient(grad svgicon.Gradient) rasterx.Gradient {
 	}
 }
 
+// applyOpacity returns `c` as a non premultiplied color, with its
+// alpha multiplied by `opacity`.
+// rasterx.ApplyOpacity is not used since it ignores the alpha of `c`,
+// and expects a color whose RGBA() values are not premultiplied.
+func applyOpacity(c color.Color, opacity float64) color.NRGBA {
+	nc := color.NRGBAModel.Convert(c).(color.NRGBA)
+	nc.A = uint8(float64(nc.A)*opacity + 0.5)
+	return nc
+}
+
+// straightStops returns a copy of `stops` where the alpha of each
+// stop color is moved to its opacity, as expected by rasterx.
+func straightStops(stops []svgicon.GradStop) []svgicon.GradStop {
+	out := make([]svgicon.GradStop, len(stops))
+	for i, stop := range stops {
+		nc := color.NRGBAModel.Convert(stop.StopColor).(color.NRGBA)
+		stop.Opacity *= float64(nc.A) / 0xff
+		nc.A = 0xff
+		stop.StopColor = nc
+		out[i] = stop
+	}
+	return out
+}
+
 // resolve gradient color
-func setColorFromPattern(color svgicon.Pattern, opacity float64, scanner rasterx.Scanner) {
+func setColorFromPattern(color svgicon.Pattern, opacity float64, scanner rasterx.Scanner, clip *clipMask, blend *blender) {
 	switch color := color.(type) {
 	case svgicon.PlainColor:
-		scanner.SetColor(rasterx.ApplyOpacity(color, opacity))
+		scanner.SetColor(clip.apply(blend.apply(applyOpacity(color, opacity))))
 	case svgicon.Gradient:
 		_ = color.ApplyPathExtent(scanner.GetPathExtent())
-		rasterxGradient := toRasterxGradient(color)
-		scanner.SetColor(rasterxGradient.GetColorFunction(opacity))
+		scanner.SetColor(clip.apply(blend.apply(gradientColorFunction(color, opacity))))
 	}
 }
 
 func (f filler) Draw(color svgicon.Pattern, opacity float64) {
-	setColorFromPattern(color, opacity, f.Scanner)
+	if !f.evenOdd.enabled {
+		setColorFromPattern(color, opacity, f.Scanner, f.clip, f.blend)
+		f.Filler.Draw()
+		return
+	}
+	// the color is set before replacing the path, since
+	// gradients depend on its extent
+	setColorFromPattern(color, opacity, f.Scanner, f.evenOdd.mask(f.clip), f.blend)
+	// fill the bounding box (enlarged to avoid antialiasing
+	// its edges), masked by the even-odd interior
+	min, max := f.evenOdd.bounds()
+	f.Filler.Clear()
+	rasterx.AddRect(float64(min.X)/64-1, float64(min.Y)/64-1, float64(max.X)/64+1, float64(max.Y)/64+1, 0, f.Filler)
 	f.Filler.Draw()
 }
 
 func (s stroker) Draw(color svgicon.Pattern, opacity float64) {
-	setColorFromPattern(color, opacity, s.Scanner)
+	clip := s.clip
+	if s.align.alignment != svgicon.CenterAlignment {
+		clip = s.align.restrict(clip)
+	}
+	setColorFromPattern(color, opacity, s.Scanner, clip, s.blend)
 	s.Dasher.Draw()
 }
 
@@ -138,6 +347,12 @@ var (
 )
 
 func (s stroker) SetStrokeOptions(options svgicon.StrokeOptions) {
+	s.align.alignment = options.Alignment
+	if options.Alignment != svgicon.CenterAlignment {
+		// the half of the stroke on the wrong side is masked
+		options.LineWidth *= 2
+	}
+	s.dots.lineCap, s.dots.lineWidth = options.Join.TrailLineCap, options.LineWidth
 	s.SetStroke(
 		options.LineWidth, options.Join.MiterLimit, capToFunc[options.Join.LeadLineCap],
 		capToFunc[options.Join.TrailLineCap], gapToFunc[options.Join.LineGap],
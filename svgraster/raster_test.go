@@ -2,13 +2,24 @@ package svgraster
 
 import (
 	"bytes"
+	"encoding/base64"
 	"fmt"
 	"image"
+	"image/color"
+	_ "image/gif"
+	_ "image/jpeg"
 	"image/png"
+	"io"
+	"math"
 	"os"
 	"path/filepath"
 	"strings"
 	"testing"
+
+	"github.com/benoitkugler/oksvg/internal/testutil"
+	"github.com/benoitkugler/oksvg/svgicon"
+	"github.com/srwiley/rasterx"
+	"golang.org/x/image/math/fixed"
 )
 
 func toPngBytes(m image.Image) ([]byte, error) {
@@ -66,6 +77,7 @@ func renderIcon(t *testing.T, filename string) {
 }
 
 func TestLandscapeIcons(t *testing.T) {
+	testutil.SkipWithoutCharset(t)
 	for _, p := range [...]string{
 		"beach", "cape", "iceberg", "island",
 		"mountains", "sea", "trees", "village",
@@ -75,6 +87,7 @@ func TestLandscapeIcons(t *testing.T) {
 }
 
 func TestSportsIcons(t *testing.T) {
+	testutil.SkipWithoutCharset(t)
 	for _, p := range [...]string{
 		"archery", "fencing", "rugby_sevens",
 		"artistic_gymnastics", "football", "sailing",
@@ -97,6 +110,7 @@ func TestSportsIcons(t *testing.T) {
 }
 
 func TestTestIcons(t *testing.T) {
+	testutil.SkipWithoutCharset(t)
 	for _, p := range [...]string{
 		"astronaut", "jupiter", "lander", "school-bus", "telescope", "content-cut-light",
 		// "defs",
@@ -166,3 +180,1023 @@ func TestTransparentColor(t *testing.T) {
 		t.Fatalf("can't saved rasterized image: %s", err)
 	}
 }
+
+func TestSymbolClip(t *testing.T) {
+	const src = `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 100 100">
+	<symbol id="s" viewBox="0 0 10 10">
+		<rect x="-5" y="-5" width="20" height="20" fill="red"/>
+	</symbol>
+	<use href="#s" x="10" y="20" width="30" height="30"/>
+	</svg>`
+	img, err := RasterSVGIconToImage(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c := img.RGBAAt(25, 35); c.R != 0xff || c.A != 0xff {
+		t.Errorf("expected red inside the symbol viewport, got %v", c)
+	}
+	if c := img.RGBAAt(5, 10); c.A != 0 {
+		t.Errorf("expected overflowing content to be clipped, got %v", c)
+	}
+}
+
+func TestScaledDash(t *testing.T) {
+	render := func(src string) *image.RGBA {
+		icon, err := svgicon.ReadIconStream(strings.NewReader(src), svgicon.StrictErrorMode)
+		if err != nil {
+			t.Fatal(err)
+		}
+		icon.SetTarget(0, 0, 100, 100)
+		img := image.NewRGBA(image.Rect(0, 0, 100, 100))
+		scanner := rasterx.NewScannerGV(100, 100, img, img.Bounds())
+		icon.Draw(NewDriver(100, 100, scanner), 1)
+		return img
+	}
+	// the same dashed line, once scaled by the icon transform,
+	// which also applies to the line width
+	scaled := render(`<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 50 50">
+	<line x1="5" y1="25" x2="45" y2="25" stroke="black" stroke-width="2" stroke-dasharray="5 5" stroke-dashoffset="2"/>
+	</svg>`)
+	native := render(`<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 100 100">
+	<line x1="10" y1="50" x2="90" y2="50" stroke="black" stroke-width="4" stroke-dasharray="10 10" stroke-dashoffset="4"/>
+	</svg>`)
+	if !bytes.Equal(scaled.Pix, native.Pix) {
+		t.Error("dashes should be scaled by the icon transform")
+	}
+}
+
+func TestDriverInto(t *testing.T) {
+	const src = `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 10 10">
+	<rect x="-5" y="-5" width="20" height="20" fill="%s"/>
+	</svg>`
+	dst := image.NewRGBA(image.Rect(0, 0, 100, 50))
+	for i, col := range []string{"red", "blue"} {
+		icon, err := svgicon.ReadIconStream(strings.NewReader(fmt.Sprintf(src, col)), svgicon.StrictErrorMode)
+		if err != nil {
+			t.Fatal(err)
+		}
+		bounds := image.Rect(10+50*i, 10, 40+50*i, 40)
+		icon.SetTarget(0, 0, float64(bounds.Dx()), float64(bounds.Dy()))
+		icon.Draw(NewDriverInto(dst, bounds), 1)
+	}
+	for _, test := range []struct {
+		x, y int
+		exp  color.RGBA
+	}{
+		{25, 25, color.RGBA{0xff, 0, 0, 0xff}},
+		{75, 25, color.RGBA{0, 0, 0xff, 0xff}},
+		{11, 11, color.RGBA{0xff, 0, 0, 0xff}},
+		{5, 5, color.RGBA{}},   // clipped
+		{45, 25, color.RGBA{}}, // between the icons
+		{75, 45, color.RGBA{}}, // clipped
+	} {
+		if got := dst.RGBAAt(test.x, test.y); got != test.exp {
+			t.Errorf("at (%d, %d): expected %v, got %v", test.x, test.y, test.exp, got)
+		}
+	}
+}
+
+func TestBlendMultiply(t *testing.T) {
+	const src = `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 20 10">
+	<rect width="20" height="10" fill="yellow"/>
+	<rect width="10" height="10" fill="rgb(0, 255, 128)" style="mix-blend-mode: multiply"/>
+	<rect x="10" width="10" height="10" fill="rgb(0, 255, 128)"/>
+	</svg>`
+	img, err := RasterSVGIconToImage(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c, exp := img.RGBAAt(5, 5), (color.RGBA{0, 0xff, 0, 0xff}); c != exp {
+		t.Errorf("expected multiplied color %v, got %v", exp, c)
+	}
+	if c, exp := img.RGBAAt(15, 5), (color.RGBA{0, 0xff, 0x80, 0xff}); c != exp {
+		t.Errorf("expected normal blending %v, got %v", exp, c)
+	}
+}
+
+func TestEncodeIcon(t *testing.T) {
+	const src = `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 20 10">
+	<rect width="10" height="10" fill="red"/>
+	</svg>`
+	for _, format := range []string{"png", "jpeg", "gif"} {
+		var buf bytes.Buffer
+		if err := EncodeIconOnBackground(strings.NewReader(src), &buf, format, 40, 0, color.NRGBA{0, 0, 255, 255}); err != nil {
+			t.Fatal(err)
+		}
+		img, decodedFormat, err := image.Decode(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if decodedFormat != format {
+			t.Errorf("expected format %s, got %s", format, decodedFormat)
+		}
+		if img.Bounds() != image.Rect(0, 0, 40, 10) {
+			t.Errorf("unexpected size %v", img.Bounds())
+		}
+		r, _, b, _ := img.At(5, 5).RGBA()
+		if r < 0xe000 || b > 0x2000 {
+			t.Errorf("%s: expected red inside the rectangle, got %v", format, img.At(5, 5))
+		}
+		// only JPEG is flattened onto the background
+		_, _, b, a := img.At(35, 5).RGBA()
+		if isJPEG := format == "jpeg"; isJPEG != (b > 0xe000 && a == 0xffff) {
+			t.Errorf("%s: unexpected background color %v", format, img.At(35, 5))
+		}
+	}
+
+	if err := EncodeIcon(strings.NewReader(src), io.Discard, "bmp", 0, 0); err == nil {
+		t.Error("expected an error for an unsupported format")
+	}
+}
+
+func TestFormatFromFilename(t *testing.T) {
+	for name, format := range map[string]string{
+		"icon.png": "png", "icon.JPG": "jpeg", "dir/icon.jpeg": "jpeg", "icon.gif": "gif", "icon.bmp": "",
+	} {
+		if got := FormatFromFilename(name); got != format {
+			t.Errorf("%s: expected %s, got %s", name, format, got)
+		}
+	}
+}
+
+func TestStrokeOpenPath(t *testing.T) {
+	const src = `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 100 100">
+	<polyline points="10,10 10,90 90,90" fill="none" stroke="black" stroke-width="4"/>
+	</svg>`
+	img, err := RasterSVGIconToImage(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, _, a := img.At(10, 50).RGBA(); a == 0 {
+		t.Error("expected the first segment to be stroked")
+	}
+	// the diagonal from (90, 90) to (10, 10) would close the path
+	if _, _, _, a := img.At(50, 50).RGBA(); a != 0 {
+		t.Error("the open path should not be closed when stroked")
+	}
+}
+
+func TestMiterLimit(t *testing.T) {
+	// the legs form an angle θ such that the miter ratio 1/sin(θ/2) is 3:
+	// with a line width of 10, the tip of the miter is at (50, 95),
+	// and the bevel at about (50, 81.7)
+	const src = `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 100 100">
+	<polyline points="28.787,20 50,80 71.213,20" fill="none" stroke="black"
+		stroke-width="10" stroke-linejoin="miter" %s/>
+	</svg>`
+	for _, test := range []struct {
+		attr    string
+		isMiter bool
+	}{
+		{"", true}, // default limit is 4
+		{`stroke-miterlimit="4"`, true},
+		{`stroke-miterlimit="3.2"`, true},
+		{`stroke-miterlimit="2.8"`, false},
+		{`stroke-miterlimit="2"`, false},
+	} {
+		img, err := RasterSVGIconToImage(strings.NewReader(strings.Replace(src, "%s", test.attr, 1)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, _, _, a := img.At(50, 90).RGBA()
+		if isMiter := a != 0; isMiter != test.isMiter {
+			t.Errorf("%s: expected miter %v, got %v", test.attr, test.isMiter, isMiter)
+		}
+	}
+}
+
+func TestMerge(t *testing.T) {
+	base, err := svgicon.ReadIconStream(strings.NewReader(`<svg viewBox="0 0 100 100">
+	<rect width="100" height="100" fill="red"/></svg>`), svgicon.StrictErrorMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	badge, err := svgicon.ReadIconStream(strings.NewReader(`<svg viewBox="0 0 10 10">
+	<circle cx="5" cy="5" r="5" fill="blue"/></svg>`), svgicon.StrictErrorMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	base.Merge(badge, svgicon.Bounds{X: 50, Y: 50, W: 50, H: 50})
+
+	img := image.NewRGBA(image.Rect(0, 0, 100, 100))
+	base.Draw(NewDriver(100, 100, rasterx.NewScannerGV(100, 100, img, img.Bounds())), 1)
+	if c := img.RGBAAt(20, 20); c != (color.RGBA{255, 0, 0, 255}) {
+		t.Errorf("expected red background, got %v", c)
+	}
+	if c := img.RGBAAt(75, 75); c != (color.RGBA{0, 0, 255, 255}) {
+		t.Errorf("expected blue badge, got %v", c)
+	}
+	if err := saveToPngFile("testdata_out/merge.png", img); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDropShadow(t *testing.T) {
+	const src = `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 40 40">
+	<defs>
+		<filter id="shadow">
+			<feGaussianBlur in="SourceAlpha" stdDeviation="1"/>
+			<feOffset dx="10" dy="10" result="offsetBlur"/>
+			<feMerge>
+				<feMergeNode in="offsetBlur"/>
+				<feMergeNode in="SourceGraphic"/>
+			</feMerge>
+		</filter>
+		<filter id="unsupported">
+			<feFlood flood-color="blue"/>
+		</filter>
+	</defs>
+	<rect x="10" y="10" width="10" height="10" fill="red" filter="url(#shadow)"/>
+	<rect x="0" y="30" width="5" height="5" fill="red" filter="url(#unsupported)"/>
+	</svg>`
+	img, err := RasterSVGIconToImage(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c, exp := img.RGBAAt(15, 15), (color.RGBA{0xff, 0, 0, 0xff}); c != exp {
+		t.Errorf("expected source graphic %v, got %v", exp, c)
+	}
+	if c := img.RGBAAt(25, 25); c.R != 0 || c.G != 0 || c.B != 0 || c.A < 0xf0 {
+		t.Errorf("expected black shadow, got %v", c)
+	}
+	if c := img.RGBAAt(30, 25); c.A == 0 || c.A == 0xff {
+		t.Errorf("expected blurred shadow edge, got %v", c)
+	}
+	if c := img.RGBAAt(35, 5); c.A != 0 {
+		t.Errorf("expected transparent pixel, got %v", c)
+	}
+	// unsupported filters are ignored
+	if c, exp := img.RGBAAt(2, 32), (color.RGBA{0xff, 0, 0, 0xff}); c != exp {
+		t.Errorf("expected unfiltered path %v, got %v", exp, c)
+	}
+}
+
+func TestZeroLengthSubpath(t *testing.T) {
+	const src = `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 40 10">
+	<path d="M 5 5 L 5 5" stroke="red" stroke-width="6" stroke-linecap="round"/>
+	<path d="M 15 5 Z" stroke="red" stroke-width="6" stroke-linecap="square"/>
+	<path d="M 25 5 L 25 5" stroke="red" stroke-width="6" stroke-linecap="butt"/>
+	<path d="M 35 5" stroke="red" stroke-width="6" stroke-linecap="round"/>
+	</svg>`
+	img, err := RasterSVGIconToImage(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	red := color.RGBA{0xff, 0, 0, 0xff}
+	for _, test := range []struct {
+		x, y    int
+		painted bool
+	}{
+		{5, 5, true},   // round dot center
+		{1, 1, false},  // outside the round dot
+		{15, 5, true},  // square center
+		{12, 2, true},  // square corner
+		{25, 5, false}, // butt caps render nothing
+		{35, 5, false}, // a lone move does not render
+	} {
+		c := img.RGBAAt(test.x, test.y)
+		if test.painted && c != red {
+			t.Errorf("expected red at (%d, %d), got %v", test.x, test.y, c)
+		} else if !test.painted && c.A != 0 {
+			t.Errorf("expected transparent at (%d, %d), got %v", test.x, test.y, c)
+		}
+	}
+}
+
+func TestIsolatedBlend(t *testing.T) {
+	const src = `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 20 10">
+	<rect width="20" height="10" fill="yellow"/>
+	<g style="isolation: isolate">
+		<rect width="10" height="10" fill="rgb(0, 255, 128)" style="mix-blend-mode: multiply"/>
+	</g>
+	<g>
+		<rect x="10" width="10" height="10" fill="rgb(0, 255, 128)" style="mix-blend-mode: multiply"/>
+	</g>
+	</svg>`
+	img, err := RasterSVGIconToImage(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the isolated group does not blend with the background
+	if c, exp := img.RGBAAt(5, 5), (color.RGBA{0, 0xff, 0x80, 0xff}); c != exp {
+		t.Errorf("expected isolated color %v, got %v", exp, c)
+	}
+	if c, exp := img.RGBAAt(15, 5), (color.RGBA{0, 0xff, 0, 0xff}); c != exp {
+		t.Errorf("expected multiplied color %v, got %v", exp, c)
+	}
+}
+
+func TestDefaultFill(t *testing.T) {
+	const src = `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 20 10">
+	<rect width="10" height="10"/>
+	<rect x="10" width="10" height="10" fill="red"/>
+	</svg>`
+	for _, theme := range []color.RGBA{{0, 0, 0xff, 0xff}, {0, 0x80, 0, 0xff}} {
+		icon, err := svgicon.ReadIconStreamOptions(strings.NewReader(src), svgicon.ParseOptions{DefaultFill: theme})
+		if err != nil {
+			t.Fatal(err)
+		}
+		img := image.NewRGBA(image.Rect(0, 0, 20, 10))
+		icon.Draw(NewDriverInto(img, img.Bounds()), 1)
+		if c := img.RGBAAt(5, 5); c != theme {
+			t.Errorf("expected theme color %v, got %v", theme, c)
+		}
+		if c, exp := img.RGBAAt(15, 5), (color.RGBA{0xff, 0, 0, 0xff}); c != exp {
+			t.Errorf("expected explicit fill %v, got %v", exp, c)
+		}
+	}
+}
+
+func TestClipPathShapes(t *testing.T) {
+	red, transparent := color.RGBA{0xff, 0, 0, 0xff}, color.RGBA{}
+	for _, test := range []struct {
+		clip    string
+		inside  []image.Point
+		outside []image.Point
+	}{
+		{"inset(5px)", []image.Point{{15, 15}, {6, 6}, {33, 33}}, []image.Point{{2, 2}, {37, 20}, {20, 38}}},
+		{"inset(10% 25% 0 0)", []image.Point{{20, 20}, {1, 38}}, []image.Point{{20, 2}, {35, 20}}},
+		{"inset(0 round 10px)", []image.Point{{20, 20}, {10, 1}}, []image.Point{{1, 1}, {38, 38}}},
+		{"circle()", []image.Point{{20, 20}, {20, 2}}, []image.Point{{2, 2}, {38, 38}}},
+		{"circle(10px at 10px 10px)", []image.Point{{10, 10}, {4, 10}}, []image.Point{{20, 20}, {1, 1}}},
+		{"circle(farthest-side at left top) border-box", []image.Point{{2, 2}, {30, 20}}, []image.Point{{38, 38}}},
+		{"polygon(0 0, 100% 0, 0 100%)", []image.Point{{5, 5}, {30, 2}}, []image.Point{{35, 35}, {25, 25}}},
+	} {
+		src := fmt.Sprintf(`<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 40 40">
+		<rect x="10" y="10" width="40" height="40" transform="translate(-10 -10)" fill="red" clip-path="%s"/>
+		</svg>`, test.clip)
+		icon, err := svgicon.ReadIconStream(strings.NewReader(src), svgicon.StrictErrorMode)
+		if err != nil {
+			t.Fatal(err)
+		}
+		img := image.NewRGBA(image.Rect(0, 0, 40, 40))
+		icon.Draw(NewDriverInto(img, img.Bounds()), 1)
+		for _, pt := range test.inside {
+			if c := img.RGBAAt(pt.X, pt.Y); c != red {
+				t.Errorf("%s: expected %v at %v, got %v", test.clip, red, pt, c)
+			}
+		}
+		for _, pt := range test.outside {
+			if c := img.RGBAAt(pt.X, pt.Y); c != transparent {
+				t.Errorf("%s: expected %v at %v, got %v", test.clip, transparent, pt, c)
+			}
+		}
+	}
+}
+
+func TestFocalRadialGradient(t *testing.T) {
+	const src = `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 100 100">
+	<radialGradient id="g" %s fx="%s" fy="%s" fr="%s">
+		<stop offset="0" stop-color="red"/>
+		<stop offset="1" stop-color="blue"/>
+	</radialGradient>
+	<rect width="100" height="100" fill="url(#g)"/>
+	</svg>`
+	red, blue := color.RGBA{0xff, 0, 0, 0xff}, color.RGBA{0, 0, 0xff, 0xff}
+	for _, test := range []struct {
+		attrs, fx, fy, fr string
+	}{
+		{`gradientUnits="userSpaceOnUse" cx="50" cy="50" r="50"`, "25", "40", "10"},
+		{`cx="0.5" cy="0.5" r="0.5"`, "0.25", "0.4", "0.1"},
+	} {
+		icon, err := svgicon.ReadIconStream(strings.NewReader(fmt.Sprintf(src, test.attrs, test.fx, test.fy, test.fr)), svgicon.StrictErrorMode)
+		if err != nil {
+			t.Fatal(err)
+		}
+		img := image.NewRGBA(image.Rect(0, 0, 100, 100))
+		icon.Draw(NewDriverInto(img, img.Bounds()), 1)
+		// inside the focal circle
+		for _, pt := range []image.Point{{25, 40}, {18, 40}, {25, 47}} {
+			if c := img.RGBAAt(pt.X, pt.Y); c != red {
+				t.Errorf("expected %v at %v, got %v", red, pt, c)
+			}
+		}
+		// outside the end circle
+		if c := img.RGBAAt(98, 98); c != blue {
+			t.Errorf("expected %v, got %v", blue, c)
+		}
+		// the gradient is not symmetric around the center
+		left, right := img.RGBAAt(10, 50), img.RGBAAt(89, 50)
+		if left.R <= right.R {
+			t.Errorf("expected a redder left side, got %v and %v", left, right)
+		}
+	}
+}
+
+func TestDefaultRadialGradient(t *testing.T) {
+	const src = `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 100 100">
+	<radialGradient id="g">
+		<stop offset="0" stop-color="red"/>
+		<stop offset="1" stop-color="blue"/>
+	</radialGradient>
+	<rect width="100" height="100" fill="url(#g)"/>
+	</svg>`
+	icon, err := svgicon.ReadIconStream(strings.NewReader(src), svgicon.StrictErrorMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img := image.NewRGBA(image.Rect(0, 0, 100, 100))
+	icon.Draw(NewDriverInto(img, img.Bounds()), 1)
+	// fr defaults to 0%, so the gradient starts at the center
+	if c := img.RGBAAt(50, 50); c.A != 0xff || c.R <= c.B {
+		t.Errorf("expected an opaque red center, got %v", c)
+	}
+	if c := img.RGBAAt(99, 99); c != (color.RGBA{0, 0, 0xff, 0xff}) {
+		t.Errorf("expected a blue corner, got %v", c)
+	}
+}
+
+// similarColors returns true if the channels of `c1` and `c2`
+// differ by at most `tol`
+func similarColors(c1, c2 color.RGBA, tol int) bool {
+	for _, d := range [4]int{int(c1.R) - int(c2.R), int(c1.G) - int(c2.G), int(c1.B) - int(c2.B), int(c1.A) - int(c2.A)} {
+		if d > tol || d < -tol {
+			return false
+		}
+	}
+	return true
+}
+
+func TestGradientSpreadAndUnits(t *testing.T) {
+	const src = `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 100 10">
+	<linearGradient id="g" %s>
+		<stop offset="0" stop-color="red"/>
+		<stop offset="1" stop-color="blue"/>
+	</linearGradient>
+	<rect x="%s" width="%s" height="10" fill="url(#g)"/>
+	</svg>`
+	render := func(attrs, x, width string) *image.RGBA {
+		icon, err := svgicon.ReadIconStream(strings.NewReader(fmt.Sprintf(src, attrs, x, width)), svgicon.StrictErrorMode)
+		if err != nil {
+			t.Fatal(err)
+		}
+		img := image.NewRGBA(image.Rect(0, 0, 100, 10))
+		icon.Draw(NewDriverInto(img, img.Bounds()), 1)
+		return img
+	}
+	// the samples are taken at the pixel centers, so that
+	// symmetric pixels are only approximately equal
+	const tol = 8
+	// the gradient vector covers [0, 50], so that x = 90 is at t = 1.8,
+	// which is t = 0.2 (x = 10) when reflected and t = 0.8 (x = 40) when repeated
+	for _, test := range []struct {
+		spread string
+		same   int
+	}{
+		{"pad", 60},
+		{"reflect", 10},
+		{"repeat", 40},
+	} {
+		img := render(fmt.Sprintf(`x2="0.5" spreadMethod=%q`, test.spread), "0", "100")
+		if got, exp := img.RGBAAt(90, 5), img.RGBAAt(test.same, 5); !similarColors(got, exp, tol) {
+			t.Errorf("spread %s: expected %v, got %v", test.spread, exp, got)
+		}
+	}
+
+	// in user space, the gradient does not depend on the bounding box of the shape
+	img := render(`gradientUnits="userSpaceOnUse" x1="0" x2="100"`, "50", "50")
+	ref := render(`gradientUnits="userSpaceOnUse" x1="0" x2="100"`, "0", "100")
+	for _, x := range []int{55, 75, 95} {
+		if got, exp := img.RGBAAt(x, 5), ref.RGBAAt(x, 5); !similarColors(got, exp, tol) {
+			t.Errorf("at %d: expected %v, got %v", x, exp, got)
+		}
+	}
+	if c := img.RGBAAt(55, 5); c.R < 0x60 || c.B < 0x60 {
+		t.Errorf("expected a mix of red and blue, got %v", c)
+	}
+}
+
+func TestRenderTile(t *testing.T) {
+	const src = `<svg xmlns="http://www.w3.org/2000/svg" viewBox="10 10 100 100">
+	<circle cx="60" cy="60" r="30" fill="red"/>
+	<path d="M10 60 H 110" stroke="blue" stroke-width="3"/>
+	<rect x="100" y="100" width="10" height="10" fill="green"/>
+	</svg>`
+	icon, err := svgicon.ReadIconStream(strings.NewReader(src), svgicon.StrictErrorMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const size, zoom = 50, 2.
+
+	// reference rendering of the whole icon
+	full := image.NewRGBA(image.Rect(0, 0, 200, 200))
+	icon.SetTarget(0, 0, 200, 200)
+	icon.Draw(NewDriverInto(full, full.Bounds()), 1)
+
+	left, right := RenderTile(icon, 1, 2, size, zoom), RenderTile(icon, 2, 2, size, zoom)
+	// the coverage is accumulated using float32 coordinates, so that
+	// the antialiased edges of a translated curve may slightly differ:
+	// only these partially covered pixels are compared with a tolerance
+	similar := func(c, exp color.RGBA) bool {
+		if exp.A == 0 || exp.A == 0xff {
+			return c == exp
+		}
+		return similarColors(c, exp, 4)
+	}
+	// adjacent tiles must match the reference rendering, in particular at their boundary
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			if c, exp := left.RGBAAt(x, y), full.RGBAAt(size+x, 2*size+y); !similar(c, exp) {
+				t.Fatalf("left tile: expected %v at (%d, %d), got %v", exp, x, y, c)
+			}
+			if c, exp := right.RGBAAt(x, y), full.RGBAAt(2*size+x, 2*size+y); !similar(c, exp) {
+				t.Fatalf("right tile: expected %v at (%d, %d), got %v", exp, x, y, c)
+			}
+		}
+	}
+	if c := right.RGBAAt(5, 10); c != (color.RGBA{0xff, 0, 0, 0xff}) {
+		t.Errorf("expected the circle in the right tile, got %v", c)
+	}
+	if c := RenderTile(icon, 3, 3, size, zoom).RGBAAt(size-1, size-1); c != (color.RGBA{0, 0x80, 0, 0xff}) {
+		t.Errorf("unexpected color %v in the corner tile", c)
+	}
+}
+
+func TestDriverReset(t *testing.T) {
+	icons := [2]string{
+		`<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 10 10"><circle cx="5" cy="5" r="4" fill="red" stroke="blue"/></svg>`,
+		`<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 20 10"><rect x="2" y="2" width="16" height="6" fill="green" opacity="0.5"/></svg>`,
+	}
+	var reused *Driver
+	for _, src := range icons {
+		icon, err := svgicon.ReadIconStream(strings.NewReader(src), svgicon.StrictErrorMode)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w, h := int(icon.ViewBox.W)*4, int(icon.ViewBox.H)*4
+		icon.SetTarget(0, 0, float64(w), float64(h))
+
+		exp := image.NewRGBA(image.Rect(0, 0, w, h))
+		icon.Draw(NewDriverInto(exp, exp.Bounds()), 1)
+
+		got := image.NewRGBA(image.Rect(0, 0, w, h))
+		if reused == nil {
+			d := NewDriverInto(got, got.Bounds())
+			reused = &d
+		} else {
+			reused.Reset(got)
+		}
+		icon.Draw(reused, 1)
+		if !bytes.Equal(got.Pix, exp.Pix) {
+			t.Errorf("rendering with a reused driver differs for %s", src)
+		}
+	}
+}
+
+const benchIcon = `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 24 24">
+	<path d="M12 2L2 7l10 5 10-5-10-5zM2 17l10 5 10-5M2 12l10 5 10-5" fill="none" stroke="black" stroke-width="2"/>
+	</svg>`
+
+func BenchmarkRenderIcons(b *testing.B) {
+	icon, err := svgicon.ReadIconStream(strings.NewReader(benchIcon), svgicon.StrictErrorMode)
+	if err != nil {
+		b.Fatal(err)
+	}
+	icon.SetTarget(0, 0, 48, 48)
+	b.Run("new driver", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			for j := 0; j < 1000; j++ {
+				img := image.NewRGBA(image.Rect(0, 0, 48, 48))
+				icon.Draw(NewDriverInto(img, img.Bounds()), 1)
+			}
+		}
+	})
+	b.Run("reused driver", func(b *testing.B) {
+		img := image.NewRGBA(image.Rect(0, 0, 48, 48))
+		d := NewDriverInto(img, img.Bounds())
+		for i := 0; i < b.N; i++ {
+			for j := 0; j < 1000; j++ {
+				img := image.NewRGBA(image.Rect(0, 0, 48, 48))
+				d.Reset(img)
+				icon.Draw(d, 1)
+			}
+		}
+	})
+}
+
+func TestGradientCurrentColor(t *testing.T) {
+	const src = `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 20 10" color="%s">
+	<linearGradient id="g">
+		<stop offset="0" stop-color="currentColor"/>
+		<stop offset="1" stop-color="currentColor"/>
+	</linearGradient>
+	<linearGradient id="h" color="lime">
+		<stop offset="0" stop-color="currentColor"/>
+		<stop offset="1" stop-color="currentColor"/>
+	</linearGradient>
+	<rect width="10" height="10" fill="url(#g)"/>
+	<rect x="10" width="10" height="10" fill="url(#h)"/>
+	</svg>`
+	for _, test := range []struct {
+		color string
+		exp   color.RGBA
+	}{
+		{"red", color.RGBA{0xff, 0, 0, 0xff}},
+		{"#0000ff", color.RGBA{0, 0, 0xff, 0xff}},
+	} {
+		icon, err := svgicon.ReadIconStream(strings.NewReader(fmt.Sprintf(src, test.color)), svgicon.StrictErrorMode)
+		if err != nil {
+			t.Fatal(err)
+		}
+		img := image.NewRGBA(image.Rect(0, 0, 20, 10))
+		icon.Draw(NewDriverInto(img, img.Bounds()), 1)
+		if c := img.RGBAAt(5, 5); c != test.exp {
+			t.Errorf("expected %v, got %v", test.exp, c)
+		}
+		// the color property of the gradient takes precedence
+		if c, exp := img.RGBAAt(15, 5), (color.RGBA{0, 0xff, 0, 0xff}); c != exp {
+			t.Errorf("expected %v, got %v", exp, c)
+		}
+	}
+}
+
+func TestRasterPercentageSize(t *testing.T) {
+	img, err := RasterSVGIconToImage(strings.NewReader(`<svg xmlns="http://www.w3.org/2000/svg" width="100%" height="100%" viewBox="0 0 30 20">
+	<rect width="30" height="20" fill="red"/></svg>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if img.Bounds() != image.Rect(0, 0, 30, 20) {
+		t.Errorf("unexpected size %v", img.Bounds())
+	}
+
+	// no size at all
+	const noSize = `<svg xmlns="http://www.w3.org/2000/svg" width="100%" height="100%"><rect width="30" height="20"/></svg>`
+	if _, err = RasterSVGIconToImage(strings.NewReader(noSize)); err == nil {
+		t.Error("expected an error for an icon without size")
+	}
+	if err = EncodeIcon(strings.NewReader(noSize), io.Discard, "png", 0, 0); err == nil {
+		t.Error("expected an error for an icon without size")
+	}
+	// an explicit size is enough
+	if err = EncodeIcon(strings.NewReader(noSize), io.Discard, "png", 30, 20); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestFillOpenShapes(t *testing.T) {
+	const src = `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 30 10">
+	<polyline points="0,0 10,0 10,10" fill="red"/>
+	<path d="M10 0 H 20 V 10" fill="red"/>
+	<line x1="20" y1="0" x2="30" y2="10" fill="red" stroke="none"/>
+	<polyline points="20,10 30,0" fill="red" stroke="blue"/>
+	</svg>`
+	icon, err := svgicon.ReadIconStream(strings.NewReader(src), svgicon.StrictErrorMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(icon.SVGPaths) != 4 {
+		t.Fatalf("expected 4 paths, got %d", len(icon.SVGPaths))
+	}
+	if icon.SVGPaths[2].Style.FillerColor != nil {
+		t.Error("a line should never be filled")
+	}
+	img := image.NewRGBA(image.Rect(0, 0, 30, 10))
+	icon.Draw(NewDriverInto(img, img.Bounds()), 1)
+	red := color.RGBA{0xff, 0, 0, 0xff}
+	// open shapes are filled as if closed
+	for _, pt := range []image.Point{{8, 2}, {18, 2}} {
+		if c := img.RGBAAt(pt.X, pt.Y); c != red {
+			t.Errorf("expected %v at %v, got %v", red, pt, c)
+		}
+	}
+	for _, pt := range []image.Point{{2, 8}, {12, 8}, {22, 2}, {28, 8}} {
+		if c := img.RGBAAt(pt.X, pt.Y); c != (color.RGBA{}) {
+			t.Errorf("expected no fill at %v, got %v", pt, c)
+		}
+	}
+	// a two points polyline is stroked
+	if c := img.RGBAAt(25, 5); c.B == 0 {
+		t.Errorf("expected a stroke at (25, 5), got %v", c)
+	}
+}
+
+func TestPathLengthProgressRing(t *testing.T) {
+	const src = `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 40 40">
+	<circle cx="20" cy="20" r="15" pathLength="100" fill="none" stroke="blue" stroke-width="4" stroke-dasharray="25 75"/>
+	</svg>`
+	icon, err := svgicon.ReadIconStream(strings.NewReader(src), svgicon.StrictErrorMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the dashes are expressed in user units
+	circumference := 2 * math.Pi * 15
+	dash := icon.SVGPaths[0].Style.Dash.Dash
+	if len(dash) != 2 || math.Abs(dash[0]-circumference/4) > 0.05 || math.Abs(dash[1]-3*circumference/4) > 0.05 {
+		t.Fatalf("unexpected dash array %v", dash)
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, 40, 40))
+	icon.Draw(NewDriverInto(img, img.Bounds()), 1)
+	// the dash starts at 3 o'clock, and goes clockwise for a quarter of the circle
+	if c := img.RGBAAt(30, 30); c.B == 0 {
+		t.Errorf("expected a stroke at (30, 30), got %v", c)
+	}
+	for _, pt := range []image.Point{{30, 9}, {9, 9}, {9, 30}, {5, 20}} {
+		if c := img.RGBAAt(pt.X, pt.Y); c != (color.RGBA{}) {
+			t.Errorf("expected no stroke at %v, got %v", pt, c)
+		}
+	}
+}
+
+func TestRendererImage(t *testing.T) {
+	const src = `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 10 10">
+	<rect width="10" height="10" fill="red" fill-opacity="0.5"/>
+	</svg>`
+	icon, err := svgicon.ReadIconStream(strings.NewReader(src), svgicon.StrictErrorMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nrgba := image.NewNRGBA(image.Rect(0, 0, 10, 10))
+	icon.Draw(NewRendererImage(nrgba), 1)
+	// straight alpha: the color channels are not scaled by the opacity
+	if c := nrgba.NRGBAAt(5, 5); c.R != 0xff || c.G != 0 || c.B != 0 || c.A < 0x7f || c.A > 0x80 {
+		t.Errorf("unexpected NRGBA color %v", c)
+	}
+
+	rgba64 := image.NewRGBA64(image.Rect(10, 10, 20, 20))
+	icon.Draw(NewRendererImage(rgba64), 1)
+	if c := rgba64.RGBA64At(15, 15); c.R != c.A || c.A < 0x7f00 || c.A > 0x8100 {
+		t.Errorf("unexpected RGBA64 color %v", c)
+	}
+}
+
+func TestText(t *testing.T) {
+	const src = `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 100 100">
+	<text x="%s" y="60" font-size="40" text-anchor="%s" fill="red">HI</text>
+	</svg>`
+	// returns the horizontal extent of the painted pixels
+	render := func(x, anchor string) (minX, maxX int) {
+		icon, err := svgicon.ReadIconStream(strings.NewReader(fmt.Sprintf(src, x, anchor)), svgicon.StrictErrorMode)
+		if err != nil {
+			t.Fatal(err)
+		}
+		img := image.NewRGBA(image.Rect(0, 0, 100, 100))
+		icon.Draw(NewDriverInto(img, img.Bounds()), 1)
+		minX, maxX = 100, -1
+		for y := 0; y < 100; y++ {
+			for x := 0; x < 100; x++ {
+				c := img.RGBAAt(x, y)
+				if c.A == 0 {
+					continue
+				}
+				if y < 25 || y > 61 {
+					t.Fatalf("unexpected painted pixel at (%d, %d)", x, y)
+				}
+				if c.A == 0xff && c != (color.RGBA{0xff, 0, 0, 0xff}) {
+					t.Fatalf("unexpected color %v", c)
+				}
+				if x < minX {
+					minX = x
+				}
+				if x > maxX {
+					maxX = x
+				}
+			}
+		}
+		return minX, maxX
+	}
+	minX, maxX := render("10", "start")
+	if minX < 10 || minX > 16 || maxX < 50 {
+		t.Errorf("unexpected text extent [%d, %d]", minX, maxX)
+	}
+	width := maxX - minX
+	if minX, maxX = render("90", "end"); maxX > 90 || maxX < 84 || maxX-minX != width {
+		t.Errorf("unexpected text extent [%d, %d] for an end anchor", minX, maxX)
+	}
+	if minX, maxX = render("50", "middle"); minX+maxX < 96 || minX+maxX > 104 {
+		t.Errorf("unexpected text extent [%d, %d] for a middle anchor", minX, maxX)
+	}
+}
+
+func TestTextSpacing(t *testing.T) {
+	// returns the horizontal extent of the control points
+	extent := func(text svgicon.Text) (minX, maxX float64) {
+		minX, maxX = math.Inf(1), math.Inf(-1)
+		for _, op := range (Driver{}).TextPath(text) {
+			var points []fixed.Point26_6
+			switch op := op.(type) {
+			case svgicon.OpMoveTo:
+				points = []fixed.Point26_6{fixed.Point26_6(op)}
+			case svgicon.OpLineTo:
+				points = []fixed.Point26_6{fixed.Point26_6(op)}
+			case svgicon.OpQuadTo:
+				points = op[:]
+			case svgicon.OpCubicTo:
+				points = op[:]
+			}
+			for _, p := range points {
+				minX, maxX = math.Min(minX, float64(p.X)/64), math.Max(maxX, float64(p.X)/64)
+			}
+		}
+		return minX, maxX
+	}
+
+	for _, test := range []struct {
+		content       string
+		letter, word  float64
+		expectedExtra float64 // between the first and the last glyph
+	}{
+		{"AB", 10, 0, 10},
+		{"ABC", 10, 0, 20},
+		{"A B", 0, 10, 10},
+		{"A B", 5, 10, 20},
+	} {
+		text := svgicon.Text{X: 50, Y: 50, Content: test.content, FontSize: 40}
+		minX, maxX := extent(text)
+		text.LetterSpacing, text.WordSpacing = test.letter, test.word
+		spacedMinX, spacedMaxX := extent(text)
+		if extra := (spacedMaxX - spacedMinX) - (maxX - minX); math.Abs(extra-test.expectedExtra) > 0.1 || spacedMinX != minX {
+			t.Errorf("%q: expected an extra width of %g, got %g", test.content, test.expectedExtra, extra)
+		}
+
+		// the spacings count toward the width used by the anchor
+		text.Anchor = svgicon.AnchorEnd
+		endMinX, _ := extent(text)
+		text.LetterSpacing, text.WordSpacing = 0, 0
+		plainEndMinX, _ := extent(text)
+		expectedShift := test.expectedExtra + test.letter // the spacing follows the last character as well
+		if shift := plainEndMinX - endMinX; math.Abs(shift-expectedShift) > 0.1 {
+			t.Errorf("%q: expected an anchor shift of %g, got %g", test.content, expectedShift, shift)
+		}
+	}
+}
+
+func TestConvertTextToPaths(t *testing.T) {
+	const src = `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 100 100">
+	<rect width="10" height="10"/>
+	<text x="50" y="60" font-size="30" text-anchor="middle" fill="red" stroke="blue" letter-spacing="2">Go<tspan x="10" y="90">text</tspan></text>
+	</svg>`
+	render := func(icon *svgicon.SvgIcon) *image.RGBA {
+		img := image.NewRGBA(image.Rect(0, 0, 100, 100))
+		icon.Draw(NewDriverInto(img, img.Bounds()), 1)
+		return img
+	}
+	icon, err := svgicon.ReadIconStream(strings.NewReader(src), svgicon.StrictErrorMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := render(icon)
+
+	if err = svgicon.ConvertTextToPaths(icon, bundledFont()); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(render(icon).Pix, expected.Pix) {
+		t.Error("the converted texts are not drawn as the original ones")
+	}
+}
+
+func TestStrokeAlignment(t *testing.T) {
+	const src = `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 100 100">
+	<rect x="30" y="30" width="40" height="40" fill="none" stroke="red" stroke-width="10" stroke-alignment="%s"/>
+	</svg>`
+	for _, test := range []struct {
+		alignment string
+		painted   [4]bool // at x = 22, 27, 32, 37
+	}{
+		{"center", [4]bool{false, true, true, false}},
+		{"inner", [4]bool{false, false, true, true}},
+		{"outer", [4]bool{true, true, false, false}},
+	} {
+		icon, err := svgicon.ReadIconStream(strings.NewReader(fmt.Sprintf(src, test.alignment)), svgicon.StrictErrorMode)
+		if err != nil {
+			t.Fatal(err)
+		}
+		img := image.NewRGBA(image.Rect(0, 0, 100, 100))
+		icon.Draw(NewDriverInto(img, img.Bounds()), 1)
+		for i, x := range [4]int{22, 27, 32, 37} {
+			c := img.RGBAAt(x, 50)
+			if painted := c.A != 0; painted != test.painted[i] {
+				t.Errorf("%s alignment: unexpected color %v at x = %d", test.alignment, c, x)
+			}
+		}
+		// the opposite side
+		if c := img.RGBAAt(100-22, 50); (c.A != 0) != test.painted[0] {
+			t.Errorf("%s alignment: unexpected color %v on the right side", test.alignment, c)
+		}
+	}
+}
+
+func TestDrawImage(t *testing.T) {
+	// a 2x1 image, red then blue
+	src := image.NewNRGBA(image.Rect(0, 0, 2, 1))
+	src.Set(0, 0, color.NRGBA{0xff, 0, 0, 0xff})
+	src.Set(1, 0, color.NRGBA{0, 0, 0xff, 0xff})
+	data, err := toPngBytes(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	icon, err := svgicon.ReadIconStream(strings.NewReader(fmt.Sprintf(`<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 100 100">
+	<image href="data:image/png;base64,%s" x="10" y="20" width="80" height="40" transform="rotate(90 50 50)" opacity="0.5"/>
+	</svg>`, base64.StdEncoding.EncodeToString(data))), svgicon.StrictErrorMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img := image.NewRGBA(image.Rect(0, 0, 100, 100))
+	icon.Draw(NewDriverInto(img, img.Bounds()), 1)
+	// the image covers [40, 80] x [10, 90] once rotated
+	for _, test := range []struct {
+		x, y int
+		exp  color.RGBA
+	}{
+		{60, 20, color.RGBA{0x80, 0, 0, 0x80}},
+		{60, 80, color.RGBA{0, 0, 0x80, 0x80}},
+		{30, 50, color.RGBA{}},
+		{60, 5, color.RGBA{}},
+	} {
+		if got := img.RGBAAt(test.x, test.y); got != test.exp {
+			t.Errorf("at (%d, %d): expected %v, got %v", test.x, test.y, test.exp, got)
+		}
+	}
+}
+
+func TestCompoundPathWinding(t *testing.T) {
+	// two squares, the inner one drawn clockwise (same direction)
+	// or counter-clockwise (opposite direction)
+	const (
+		outer       = "M 10 10 H 90 V 90 H 10 Z"
+		innerSame   = "M 30 30 H 70 V 70 H 30 Z"
+		innerOppose = "M 30 30 V 70 H 70 V 30 Z"
+	)
+	for _, test := range []struct {
+		d       string
+		nonZero bool
+		hole    bool
+	}{
+		{outer + innerSame, false, true},
+		{outer + innerOppose, false, true},
+		{outer + innerSame, true, false},
+		{outer + innerOppose, true, true},
+	} {
+		icon, err := svgicon.ReadIconStream(strings.NewReader(fmt.Sprintf(`<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 100 100">
+		<path d="%s"/></svg>`, test.d)), svgicon.StrictErrorMode)
+		if err != nil {
+			t.Fatal(err)
+		}
+		icon.SVGPaths[0].Style.UseNonZeroWinding = test.nonZero
+		img := image.NewRGBA(image.Rect(0, 0, 100, 100))
+		icon.Draw(NewDriverInto(img, img.Bounds()), 1)
+		if c := img.RGBAAt(20, 20); c.A != 0xff {
+			t.Errorf("%s (non-zero: %v): expected the ring to be filled, got %v", test.d, test.nonZero, c)
+		}
+		if c := img.RGBAAt(50, 50); (c.A == 0) != test.hole {
+			t.Errorf("%s (non-zero: %v): unexpected color %v at the center", test.d, test.nonZero, c)
+		}
+	}
+
+	// a single self-intersecting subpath: the center of the star
+	// is wound twice
+	for _, rule := range []string{"evenodd", "nonzero"} {
+		icon, err := svgicon.ReadIconStream(strings.NewReader(fmt.Sprintf(`<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 100 100">
+		<path d="M50,0 21,90 98,35 2,35 79,90z" fill-rule="%s"/></svg>`, rule)), svgicon.StrictErrorMode)
+		if err != nil {
+			t.Fatal(err)
+		}
+		img := image.NewRGBA(image.Rect(0, 0, 100, 100))
+		icon.Draw(NewDriverInto(img, img.Bounds()), 1)
+		if c := img.RGBAAt(50, 10); c.A != 0xff {
+			t.Errorf("%s: expected the branch of the star to be filled, got %v", rule, c)
+		}
+		if c := img.RGBAAt(50, 50); (c.A == 0) != (rule == "evenodd") {
+			t.Errorf("%s: unexpected color %v at the center of the star", rule, c)
+		}
+	}
+}
+
+func TestEvenOddMask(t *testing.T) {
+	icon, err := svgicon.ReadIconStream(strings.NewReader(`<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 100 100">
+	<path d="M10,10 H90 V90 H10Z M30,30 V70 H70 V30Z" fill-rule="evenodd" clip-path="inset(0 0 50% 0)"/>
+	<path d="M-20,80 H20 V120 H-20Z M-10,90 V110 H10 V90Z" fill-rule="evenodd" fill="blue"/>
+	</svg>`), svgicon.StrictErrorMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img := image.NewRGBA(image.Rect(0, 0, 100, 100))
+	rd := NewDriverInto(img, img.Bounds())
+	icon.Draw(rd, 1)
+	for _, test := range []struct {
+		x, y   int
+		filled bool
+	}{
+		{20, 20, true},
+		{50, 40, false}, // hole
+		{20, 60, false}, // clipped
+		{15, 85, true},
+		{5, 95, false}, // hole, on the border of the image
+	} {
+		if c := img.RGBAAt(test.x, test.y); (c.A == 0xff) != test.filled || (c.A != 0 && c.A != 0xff) {
+			t.Errorf("at (%d, %d): unexpected color %v", test.x, test.y, c)
+		}
+	}
+
+	// the mask covers the bounding box of the path, in the image,
+	// and its buffer is reused between the paths
+	if cap(rd.evenOdd.pix) != 80*80 {
+		t.Errorf("expected the mask buffer of the first path to be reused, got a capacity of %d", cap(rd.evenOdd.pix))
+	}
+	e := evenOddFill{width: 100, height: 100, path: icon.SVGPaths[1].Path}
+	if r := e.mask(new(clipMask)).mask.Rect; r != image.Rect(0, 80, 20, 100) {
+		t.Errorf("unexpected mask bounds %v", r)
+	}
+}
@@ -0,0 +1,44 @@
+package svgraster
+
+import (
+	"sync"
+
+	"github.com/benoitkugler/oksvg/svgicon"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/sfnt"
+)
+
+var (
+	defaultFontOnce sync.Once
+	defaultFont     *sfnt.Font
+)
+
+// bundledFont returns the Go Regular font, used
+// when no font is provided.
+func bundledFont() *sfnt.Font {
+	defaultFontOnce.Do(func() {
+		var err error
+		defaultFont, err = sfnt.Parse(goregular.TTF)
+		if err != nil { // should not happen
+			panic(err)
+		}
+	})
+	return defaultFont
+}
+
+// SetFont sets the font used to draw the texts,
+// whatever their font-family property.
+// By default, or if `f` is nil, the bundled Go Regular font is used.
+func (rd *Driver) SetFont(f *sfnt.Font) { rd.font = f }
+
+// TextPath implements svgicon.TextDriver, laying out
+// the text on one line, with kerning and the letter and word spacings
+// (see svgicon.Text.Outlines). The glyphs which can't be loaded are skipped.
+func (rd Driver) TextPath(text svgicon.Text) svgicon.Path {
+	f := rd.font
+	if f == nil {
+		f = bundledFont()
+	}
+	path, _ := text.Outlines(f)
+	return path
+}
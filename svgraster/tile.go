@@ -0,0 +1,21 @@
+package svgraster
+
+import (
+	"image"
+
+	"github.com/benoitkugler/oksvg/svgicon"
+)
+
+// RenderTile rasterizes the square tile at position (`tileX`, `tileY`) of the icon,
+// scaled by `zoom` (pixels per user unit), in a tiling whose origin is the top-left
+// corner of the icon view box. The paths outside the tile are skipped.
+// The icon Transform is ignored and `icon` is not modified, so that
+// several tiles of the same icon may be rendered concurrently.
+func RenderTile(icon *svgicon.SvgIcon, tileX, tileY, tileSize int, zoom float64) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, tileSize, tileSize))
+	tile := *icon // shallow copy, so that Transform may be changed
+	tile.Transform = svgicon.Identity.Translate(-float64(tileX*tileSize), -float64(tileY*tileSize)).
+		Scale(zoom, zoom).Translate(-icon.ViewBox.X, -icon.ViewBox.Y)
+	tile.DrawClipped(NewDriverInto(img, img.Bounds()), svgicon.Bounds{W: float64(tileSize), H: float64(tileSize)}, 1)
+	return img
+}